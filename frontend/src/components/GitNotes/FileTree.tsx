@@ -88,7 +88,7 @@ const FileTree = ({ onFileSelect, isConnected }: FileTreeProps) => {
   const loadRepositoryStructure = async () => {
     setLoading(true);
     try {
-      const repoStructureJson = await GitNotesService.GetRepositoryStructure();
+      const repoStructureJson = await GitNotesService.GetRepositoryStructure('');
       const repoStructure: FileNodeData = JSON.parse(repoStructureJson);
       
       setOriginalData(repoStructure);
@@ -49,7 +49,7 @@ const MainLayout = () => {
           // Backend says we're connected, but verify we can access files
           try {
             // Try to get repository structure to verify true connection
-            await GitNotesService.GetRepositoryStructure();
+            await GitNotesService.GetRepositoryStructure('');
             // Successfully retrieved structure, we're truly connected
             updateSyncStatus({ isConnected: true });
             setIsSettingsVisible(false);
@@ -83,10 +83,10 @@ const MainLayout = () => {
     if (settings.repoURL && settings.localPath) {
       try {
         setReconnecting(true);
-        await GitNotesService.ConnectRepository(settings.repoURL, settings.localPath, '');
+        await GitNotesService.ConnectRepository(settings.repoURL, settings.localPath, '', '', '');
         // Verify connection was successful by checking repository structure
         try {
-          await GitNotesService.GetRepositoryStructure();
+          await GitNotesService.GetRepositoryStructure('');
           updateSyncStatus({ isConnected: true });
           setIsSettingsVisible(false);
         } catch (error) {
@@ -108,7 +108,7 @@ const MarkdownEditor = ({ filePath, isConnected, vimModeEnabled }: MarkdownEdito
     if (isConnected) {
       const statusInterval = setInterval(async () => {
         try {
-          const status = await GitNotesService.GetSyncStatus();
+          const status = await GitNotesService.GetSyncStatus('');
           setSyncStatus(status);
         } catch (error) {
           console.error('Error getting sync status:', error);
@@ -127,7 +127,7 @@ const MarkdownEditor = ({ filePath, isConnected, vimModeEnabled }: MarkdownEdito
     setError(null);
     
     try {
-      const fileContent = await GitNotesService.GetFileContent(filePath);
+      const fileContent = await GitNotesService.GetFileContent('', filePath);
       setContent(fileContent);
       lastSavedContent.current = fileContent;
     } catch (error) {
@@ -147,7 +147,7 @@ const MarkdownEditor = ({ filePath, isConnected, vimModeEnabled }: MarkdownEdito
     
     setSaving(true);
     try {
-      await GitNotesService.WriteFileContent(filePath, content);
+      await GitNotesService.WriteFileContent('', filePath, content);
       lastSavedContent.current = content;
       message.success('File saved successfully');
     } catch (error) {
@@ -173,7 +173,7 @@ const MarkdownEditor = ({ filePath, isConnected, vimModeEnabled }: MarkdownEdito
 
   const handleSyncRequest = async () => {
     try {
-      await GitNotesService.TriggerManualSync();
+      await GitNotesService.TriggerManualSync('');
       message.success('Sync requested');
     } catch (error) {
       message.error(`Sync failed: ${error}`);
@@ -1,5 +1,5 @@
 import { useState, useEffect } from 'react';
-import { Form, Input, Button, message, Select, theme, Space } from 'antd';
+import { Form, Input, Button, message, Select, theme, Space, Modal } from 'antd';
 import { GithubOutlined, RedoOutlined } from '@ant-design/icons';
 import { GitNotesService } from "../../../bindings/changeme/services";
 
@@ -19,11 +19,17 @@ interface SettingsFormData {
 
 type ValidateStatus = "" | "success" | "warning" | "error" | "validating" | undefined;
 
+// The encrypted file credential backend has to be unlocked before a token can be stored
+const isCredentialStoreLocked = (error: unknown) => String(error).includes('credential store is locked');
+
 const RepositorySettings = ({ onConnected, isConnected }: RepositorySettingsProps) => {
   const [form] = Form.useForm<SettingsFormData>();
   const [connecting, setConnecting] = useState(false);
   const [validateStatus, setValidateStatus] = useState<ValidateStatus>("");
   const [forceEdit, setForceEdit] = useState(false);
+  const [pendingConnect, setPendingConnect] = useState<SettingsFormData | null>(null);
+  const [passphrase, setPassphrase] = useState('');
+  const [unlocking, setUnlocking] = useState(false);
   const { token } = theme.useToken();
 
   useEffect(() => {
@@ -76,7 +82,7 @@ const RepositorySettings = ({ onConnected, isConnected }: RepositorySettingsProp
       }
       
       // Then connect to the repository
-      await GitNotesService.ConnectRepository(values.repoURL, values.localPath, values.token);
+      await GitNotesService.ConnectRepository(values.repoURL, values.localPath, values.token, '', '');
       
       // Save settings including token
       const settingsToSave = {
@@ -89,19 +95,51 @@ const RepositorySettings = ({ onConnected, isConnected }: RepositorySettingsProp
       
       // Start auto-sync if interval is specified
       if (values.syncInterval > 0) {
-        await GitNotesService.StartAutomaticSync(values.syncInterval);
+        await GitNotesService.StartAutomaticSync('', values.syncInterval);
       }
       
       message.success('Successfully connected to repository');
       setForceEdit(false); // Turn off edit mode on successful connection
       onConnected();
     } catch (error) {
-      message.error(`Failed to connect: ${error}`);
+      if (isCredentialStoreLocked(error)) {
+        // Ask for the passphrase and connect again once the store is unlocked
+        setPendingConnect(values);
+      } else {
+        message.error(`Failed to connect: ${error}`);
+      }
     } finally {
       setConnecting(false);
     }
   };
 
+  const handleUnlock = async () => {
+    if (!pendingConnect) {
+      return;
+    }
+
+    setUnlocking(true);
+    try {
+      await GitNotesService.UnlockCredentialStore(passphrase);
+    } catch (error) {
+      message.error(`Failed to unlock the credential store: ${error}`);
+      setUnlocking(false);
+      return;
+    }
+
+    const values = pendingConnect;
+    setUnlocking(false);
+    setPendingConnect(null);
+    setPassphrase('');
+    await handleConnect(values);
+  };
+
+  const handleCancelUnlock = () => {
+    setPendingConnect(null);
+    setPassphrase('');
+    message.warning('The token was not saved because the credential store is locked');
+  };
+
   const handleForceEdit = () => {
     setForceEdit(true);
   };
@@ -243,6 +281,25 @@ const RepositorySettings = ({ onConnected, isConnected }: RepositorySettingsProp
         </Form>
       )}
       
+      <Modal
+        title="Unlock Credential Store"
+        open={pendingConnect !== null}
+        onOk={handleUnlock}
+        onCancel={handleCancelUnlock}
+        okText="Unlock"
+        confirmLoading={unlocking}
+        destroyOnClose
+      >
+        <p>Tokens are kept in an encrypted file. Enter its passphrase to store the token.</p>
+        <Input.Password
+          placeholder="Passphrase"
+          value={passphrase}
+          onChange={(e) => setPassphrase(e.target.value)}
+          onPressEnter={handleUnlock}
+          autoFocus
+        />
+      </Modal>
+
       {(!isConnected && !forceEdit) && (
         <Button 
           type="primary" 
@@ -7,7 +7,7 @@ import './SyncButton.css';
 
 const { Text, Title } = Typography;
 
-type ConflictStrategy = 'manual' | 'ours' | 'theirs';
+type ConflictStrategy = 'manual' | 'ours' | 'theirs' | 'copy';
 
 interface SyncButtonProps {
   isConnected: boolean;
@@ -41,7 +41,7 @@ const SyncButton: React.FC<SyncButtonProps> = ({
 
   const handleAbortSync = async () => {
     try {
-      await GitNotesService.AbortSyncWithConflicts();
+      await GitNotesService.AbortSyncWithConflicts('');
       message.info('Sync aborted. Conflicts have been reset.');
       clearConflicts();
       setIsModalVisible(false);
@@ -58,13 +58,13 @@ const SyncButton: React.FC<SyncButtonProps> = ({
     setIsResolvingConflict(true);
     try {
       // First set the strategy in the backend
-      await GitNotesService.SetConflictResolutionStrategy(selectedStrategy);
+      await GitNotesService.SetConflictResolutionStrategy('', selectedStrategy);
       
       // Update the strategy in the app store
       setConflictStrategy(selectedStrategy);
       
       // Resolve conflicts with the set strategy
-      await GitNotesService.ResolveConflictsWithStrategy(selectedStrategy);
+      await GitNotesService.ResolveConflictsWithStrategy('', selectedStrategy);
       
       // Always consider successful if no error was thrown
       message.success(`Conflicts resolved using "${selectedStrategy}" strategy`);
@@ -93,10 +93,10 @@ const SyncButton: React.FC<SyncButtonProps> = ({
     }
     
     try {
-      await GitNotesService.TriggerManualSync();
+      await GitNotesService.TriggerManualSync('');
       
       // Check if there are conflicts after sync
-      const detailsJson = await GitNotesService.GetConflictDetails();
+      const detailsJson = await GitNotesService.GetConflictDetails('');
       const details = JSON.parse(detailsJson);
       
       if (details.hasConflicts) {
@@ -214,6 +214,9 @@ const SyncButton: React.FC<SyncButtonProps> = ({
                 <Radio value="theirs">
                   <Text strong>Keep Theirs</Text> - Use remote changes over local changes
                 </Radio>
+                <Radio value="copy">
+                  <Text strong>Conflicted Copy</Text> - Use remote changes and save local changes as a copy
+                </Radio>
               </Space>
             </Radio.Group>
 
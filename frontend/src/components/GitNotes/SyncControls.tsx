@@ -26,7 +26,7 @@ const SyncControls: React.FC<SyncControlsProps> = ({
     
     const checkAutoSyncStatus = async () => {
       try {
-        const autoSyncActive = await GitNotesService.IsAutoSyncActive();
+        const autoSyncActive = await GitNotesService.IsAutoSyncActive('');
         setIsAutoSyncActive(autoSyncActive);
         updateSyncStatus({ isAutoSyncActive: autoSyncActive });
       } catch (error) {
@@ -48,7 +48,7 @@ const SyncControls: React.FC<SyncControlsProps> = ({
       
       try {
         // Get detailed sync status
-        const statusJson = await GitNotesService.GetSyncStatus();
+        const statusJson = await GitNotesService.GetSyncStatus('');
         const status = JSON.parse(statusJson);
         
         // Check for active sync or conflicts
@@ -67,7 +67,7 @@ const SyncControls: React.FC<SyncControlsProps> = ({
         // Check for conflicts
         if (status.status === 'Conflict detected') {
           try {
-            const detailsJson = await GitNotesService.GetConflictDetails();
+            const detailsJson = await GitNotesService.GetConflictDetails('');
             const details = JSON.parse(detailsJson);
             if (details.hasConflicts) {
               updateConflictInfo({
@@ -116,11 +116,11 @@ const SyncControls: React.FC<SyncControlsProps> = ({
     try {
       if (checked) {
         // Start auto-sync with 5-minute interval (300 seconds)
-        await GitNotesService.StartAutomaticSync(300);
+        await GitNotesService.StartAutomaticSync('', 300);
         message.success('Automatic synchronization enabled');
       } else {
         // Stop auto-sync
-        await GitNotesService.StopAutomaticSync();
+        await GitNotesService.StopAutomaticSync('');
         message.info('Automatic synchronization disabled');
       }
       
@@ -31,7 +31,7 @@ const SyncStatusDisplay: React.FC<SyncStatusDisplayProps> = ({
     
     const fetchStatus = async () => {
       try {
-        const statusJson = await GitNotesService.GetSyncStatus();
+        const statusJson = await GitNotesService.GetSyncStatus('');
         const status = JSON.parse(statusJson);
         setSyncStatus(status.status || 'Idle');
         setStatusDetail(status.message || '');
@@ -6,7 +6,7 @@ interface AppSettings {
   localPath: string;
   autoSyncInterval: number; // in seconds
   isDarkMode: boolean;
-  conflictStrategy: 'manual' | 'ours' | 'theirs' | 'both';
+  conflictStrategy: 'manual' | 'ours' | 'theirs' | 'both' | 'copy';
 }
 
 interface FileState {
@@ -46,7 +46,7 @@ interface AppState {
   syncStatus: SyncStatus;
   updateSyncStatus: (status: Partial<SyncStatus>) => void;
   updateConflictInfo: (conflicts: ConflictInfo | null) => void;
-  setConflictStrategy: (strategy: 'manual' | 'ours' | 'theirs' | 'both') => void;
+  setConflictStrategy: (strategy: 'manual' | 'ours' | 'theirs' | 'both' | 'copy') => void;
   clearConflicts: () => void;
 }
 
@@ -35,12 +35,14 @@ func main() {
 	// 'Assets' configures the asset server with the 'FS' variable pointing to the frontend files.
 	// 'Bind' is a list of Go struct instances. The frontend has access to the methods of these instances.
 	// 'Mac' options tailor the application when running an macOS.
+	gitNotesService := services.NewGitNotesService()
+
 	app := application.New(application.Options{
 		Name:        "GitNotes",
 		Description: "A GitHub-based notes manager with auto-sync",
 		Services: []application.Service{
 			application.NewService(&GreetService{}),
-			application.NewService(services.NewGitNotesService()),
+			application.NewService(gitNotesService),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.BundledAssetFileServer(assets),
@@ -52,6 +54,11 @@ func main() {
 		},
 	})
 
+	// Push sync status, conflict and progress events to the frontend
+	gitNotesService.SetEventEmitter(services.EventEmitterFunc(func(name string, data interface{}) {
+		app.EmitEvent(name, data)
+	}))
+
 	// Create a new window with the necessary options.
 	// 'Title' is the title of the window.
 	// 'Mac' options tailor the window when running on macOS.
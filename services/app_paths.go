@@ -0,0 +1,42 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configDir returns the GitNotes configuration directory (~/.gitnotes)
+func configDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, ".gitnotes"), nil
+}
+
+// repoDataDir returns the directory holding GitNotes data for a repository
+// (history, caches). It lives outside the repository so nothing in it is committed.
+func repoDataDir(repoPath string) (string, error) {
+	baseDir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	absRepoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving repository path: %w", err)
+	}
+
+	// Key the directory by a hash of the path so different clones never collide
+	sum := sha256.Sum256([]byte(absRepoPath))
+	dataDir := filepath.Join(baseDir, "repos", hex.EncodeToString(sum[:])[:16])
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return "", fmt.Errorf("error creating repository data directory: %w", err)
+	}
+
+	return dataDir, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempWriteMarker is part of the names of temp files written before a rename
+const tempWriteMarker = ".gitnotes-tmp-"
+
+// tempWriteExcludePattern matches the temp files in .git/info/exclude, so one left by
+// a crash is never committed
+const tempWriteExcludePattern = ".*" + tempWriteMarker + "*"
+
+// staleTempWriteAge is how old a temp file must be before the sweep removes it, so a
+// write still in progress keeps its file
+const staleTempWriteAge = time.Minute
+
+// ErrWriteConflict is returned when a note changed on disk since it was read
+var ErrWriteConflict = errors.New("file changed since it was read")
+
+// WriteConflictError reports a conditional write refused because the file on disk no
+// longer has the expected content. It carries the current content so the editor can
+// merge or offer a choice.
+type WriteConflictError struct {
+	Path           string `json:"path"`
+	ExpectedHash   string `json:"expectedHash"`
+	CurrentHash    string `json:"currentHash,omitempty"` // Empty when the file doesn't exist
+	CurrentContent string `json:"currentContent"`
+	Deleted        bool   `json:"deleted"` // The file was deleted since it was read
+}
+
+// Error returns a string representation of the error
+func (e *WriteConflictError) Error() string {
+	if e.Deleted {
+		return "file was deleted since it was read: " + e.Path
+	}
+	return ErrWriteConflict.Error() + ": " + e.Path
+}
+
+// Unwrap returns ErrWriteConflict
+func (e *WriteConflictError) Unwrap() error {
+	return ErrWriteConflict
+}
+
+// contentHash returns the hex SHA-256 of file content, the version tag of conditional writes
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// atomicWriteFile replaces a file so readers and crashes see the old or the new content,
+// never a partial write. The content is written and synced to a temp file in the same
+// directory, which is then renamed over the file. An existing file keeps its mode, and
+// a symlink is written through so it stays a link.
+func atomicWriteFile(filePath string, content []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = target
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(filePath)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+tempWriteMarker+"*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+
+	// Remove the temp file unless it was renamed into place
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, filePath); err != nil {
+		return err
+	}
+	renamed = true
+
+	// Persist the rename itself. Directories can't be synced on every platform, so
+	// failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// isTempWriteFile reports whether a path is the temp file of a write in progress
+func isTempWriteFile(filePath string) bool {
+	return strings.Contains(filepath.Base(filePath), tempWriteMarker)
+}
+
+// sweepTempWrites removes the temp files that interrupted writes left in the vault at
+// root and returns how many were removed
+func sweepTempWrites(root string) (int, error) {
+	removed := 0
+	cutoff := time.Now().Add(-staleTempWriteAge)
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(entry.Name(), ".") || !isTempWriteFile(filePath) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(filePath); err == nil {
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// addGitExclude adds a pattern to .git/info/exclude of the repository at root, unless
+// it's already listed, so the vault's .gitignore doesn't need to change
+func addGitExclude(root, pattern string) error {
+	excludePath := filepath.Join(root, ".git", "info", "exclude")
+
+	existing, err := os.ReadFile(excludePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		existing = append(existing, '\n')
+	}
+	existing = append(existing, []byte(pattern+"\n")...)
+
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return err
+	}
+	return atomicWriteFile(excludePath, existing, 0644)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAtomicWriteThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "notes", "real.md")
+	writeTestFile(t, target, "# Old\n")
+	link := filepath.Join(dir, "link.md")
+	if err := os.Symlink(filepath.Join("notes", "real.md"), link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	if err := atomicWriteFile(link, []byte("# New\n"), 0644); err != nil {
+		t.Fatalf("write through link: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("link was replaced by a regular file")
+	}
+	if got := readTestFile(t, target); got != "# New\n" {
+		t.Fatalf("target = %q, want the new content", got)
+	}
+
+	// The temp file was created beside the target and renamed away
+	entries, err := os.ReadDir(filepath.Join(dir, "notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("notes directory holds %d entries, want only the target", len(entries))
+	}
+}
+
+func TestSweepTempWrites(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "notes", ".note.md"+tempWriteMarker+"123")
+	fresh := filepath.Join(dir, ".other.md"+tempWriteMarker+"456")
+	inGit := filepath.Join(dir, ".git", ".config"+tempWriteMarker+"789")
+	note := filepath.Join(dir, "notes", "note.md")
+	for _, filePath := range []string{stale, fresh, inGit, note} {
+		writeTestFile(t, filePath, "content")
+	}
+	old := time.Now().Add(-2 * staleTempWriteAge)
+	for _, filePath := range []string{stale, inGit, note} {
+		if err := os.Chtimes(filePath, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := sweepTempWrites(dir)
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d files, want 1", removed)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale temp file was kept")
+	}
+	for _, filePath := range []string{fresh, inGit, note} {
+		if _, err := os.Stat(filePath); err != nil {
+			t.Errorf("%s was removed: %v", filePath, err)
+		}
+	}
+}
+
+func TestTempWritesExcludedFromGit(t *testing.T) {
+	requireGit(t)
+	dir := initTestRepo(t, map[string]string{"note.md": "# Note\n"})
+
+	for i := 0; i < 2; i++ {
+		if err := addGitExclude(dir, tempWriteExcludePattern); err != nil {
+			t.Fatalf("exclude temp files: %v", err)
+		}
+	}
+	exclude := readTestFile(t, filepath.Join(dir, ".git", "info", "exclude"))
+	if got := countLines(exclude, tempWriteExcludePattern); got != 1 {
+		t.Fatalf("pattern listed %d times, want once:\n%s", got, exclude)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "notes", ".note.md"+tempWriteMarker+"123"), "partial")
+	writeTestFile(t, filepath.Join(dir, "new.md"), "# New\n")
+	if status := mustRunGit(t, dir, "status", "--porcelain", "--untracked-files=all"); status != "?? new.md" {
+		t.Fatalf("status = %q, want only the new note", status)
+	}
+}
+
+// countLines returns how many lines of text equal line
+func countLines(text, line string) int {
+	count := 0
+	for _, l := range strings.Split(text, "\n") {
+		if l == line {
+			count++
+		}
+	}
+	return count
+}
+
+func TestWriteFileContentIfMatch(t *testing.T) {
+	original := "# Note\n"
+	changed := "# Note\n\nchanged elsewhere\n"
+
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T, notePath string) // Changes the note after it was read
+		expectedHash string
+		wantConflict *WriteConflictError // nil when the write succeeds
+	}{
+		{name: "unchanged", expectedHash: contentHash([]byte(original))},
+		{
+			name:         "changed since read",
+			setup:        func(t *testing.T, notePath string) { writeTestFile(t, notePath, changed) },
+			expectedHash: contentHash([]byte(original)),
+			wantConflict: &WriteConflictError{
+				ExpectedHash:   contentHash([]byte(original)),
+				CurrentHash:    contentHash([]byte(changed)),
+				CurrentContent: changed,
+			},
+		},
+		{
+			name: "deleted since read",
+			setup: func(t *testing.T, notePath string) {
+				if err := os.Remove(notePath); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expectedHash: contentHash([]byte(original)),
+			wantConflict: &WriteConflictError{ExpectedHash: contentHash([]byte(original)), Deleted: true},
+		},
+		{
+			name: "new note",
+			setup: func(t *testing.T, notePath string) {
+				if err := os.Remove(notePath); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:         "new note created elsewhere",
+			wantConflict: &WriteConflictError{CurrentHash: contentHash([]byte(original)), CurrentContent: original},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			notePath := filepath.Join(root, "note.md")
+			writeTestFile(t, notePath, original)
+			if tt.setup != nil {
+				tt.setup(t, notePath)
+			}
+			before, _ := os.ReadFile(notePath)
+			fileService := NewFileService(&RepositoryService{localRepoPath: root, isConnected: true})
+
+			hash, err := fileService.WriteFileContentIfMatch("note.md", "# Mine\n", tt.expectedHash)
+			if tt.wantConflict == nil {
+				if err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				if hash != contentHash([]byte("# Mine\n")) || readTestFile(t, notePath) != "# Mine\n" {
+					t.Fatalf("write returned hash %s and left %q, want the new content", hash, readTestFile(t, notePath))
+				}
+				return
+			}
+
+			var conflict *WriteConflictError
+			if !errors.As(err, &conflict) || !errors.Is(err, ErrWriteConflict) {
+				t.Fatalf("write error = %v, want a WriteConflictError", err)
+			}
+			tt.wantConflict.Path = notePath
+			if *conflict != *tt.wantConflict {
+				t.Fatalf("conflict = %+v, want %+v", *conflict, *tt.wantConflict)
+			}
+			after, _ := os.ReadFile(notePath)
+			if string(after) != string(before) {
+				t.Fatalf("note = %q after the refused write, want %q", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// AuthMode identifies the authentication mechanism used for a remote
+type AuthMode string
+
+// AuthMode constants define the supported authentication mechanisms
+const (
+	AuthModeNone     AuthMode = "none"      // No credentials (public HTTPS or local remotes)
+	AuthModeToken    AuthMode = "token"     // HTTPS with a Personal Access Token
+	AuthModeSSHKey   AuthMode = "ssh-key"   // SSH with a private key file
+	AuthModeSSHAgent AuthMode = "ssh-agent" // SSH with keys held by ssh-agent
+)
+
+// AuthProvider resolves the authentication method to use for a remote URL
+type AuthProvider interface {
+	// AuthMethod returns the go-git auth method for the remote URL and the mode it uses.
+	// A nil AuthMethod means the operation should be attempted without credentials.
+	AuthMethod(repoURL string) (transport.AuthMethod, AuthMode, error)
+}
+
+// SSHAuthOptions configures SSH authentication
+type SSHAuthOptions struct {
+	KeyPath        string // Private key file; empty means try the default keys in ~/.ssh
+	KnownHostsPath string // known_hosts file; empty means the default known_hosts locations
+	UseAgent       bool   // Prefer ssh-agent over key files
+}
+
+// DefaultAuthProvider picks token, SSH key or ssh-agent authentication based on the URL scheme
+type DefaultAuthProvider struct {
+	credService *CredentialService
+	mu          sync.RWMutex // Guards sshOptions, set from settings while syncs read them
+	sshOptions  SSHAuthOptions
+}
+
+// NewAuthProvider creates a new DefaultAuthProvider backed by the given credential service
+func NewAuthProvider(credService *CredentialService) *DefaultAuthProvider {
+	return &DefaultAuthProvider{
+		credService: credService,
+	}
+}
+
+// SetSSHOptions updates the SSH authentication options
+func (ap *DefaultAuthProvider) SetSSHOptions(options SSHAuthOptions) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	ap.sshOptions = options
+}
+
+// SSHOptions returns the current SSH authentication options
+func (ap *DefaultAuthProvider) SSHOptions() SSHAuthOptions {
+	ap.mu.RLock()
+	defer ap.mu.RUnlock()
+
+	return ap.sshOptions
+}
+
+// AuthMethod returns the auth method for the given remote URL
+func (ap *DefaultAuthProvider) AuthMethod(repoURL string) (transport.AuthMethod, AuthMode, error) {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return nil, AuthModeNone, fmt.Errorf("invalid repository URL: %w", err)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		return ap.sshAuth(endpoint)
+	case "http", "https":
+		return ap.tokenAuth(repoURL)
+	default:
+		// file:// and git:// remotes don't take credentials
+		return nil, AuthModeNone, nil
+	}
+}
+
+// TokenAuth returns HTTPS basic auth for an explicit token
+func TokenAuth(token string) *http.BasicAuth {
+	return &http.BasicAuth{
+		Username: "github-token", // This can be any string when using a token
+		Password: token,
+	}
+}
+
+// IsSSHURL reports whether the remote URL uses the SSH transport
+func IsSSHURL(repoURL string) bool {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return false
+	}
+	return endpoint.Protocol == "ssh"
+}
+
+// tokenAuth builds HTTPS basic auth from the stored token
+func (ap *DefaultAuthProvider) tokenAuth(repoURL string) (transport.AuthMethod, AuthMode, error) {
+	token, err := ap.credService.GetCredential(repoURL)
+	if err != nil || token == "" {
+		// No token stored, the remote may still be public
+		if err != nil {
+			fmt.Printf("Warning: Unable to retrieve credentials: %v\n", err)
+		}
+		return nil, AuthModeNone, nil
+	}
+
+	return TokenAuth(token), AuthModeToken, nil
+}
+
+// sshAuth builds SSH auth using either ssh-agent or a private key file
+func (ap *DefaultAuthProvider) sshAuth(endpoint *transport.Endpoint) (transport.AuthMethod, AuthMode, error) {
+	user := endpoint.User
+	if user == "" {
+		user = "git"
+	}
+
+	// Use one snapshot of the options for the whole lookup
+	options := ap.SSHOptions()
+
+	keyPath := options.KeyPath
+	if !options.UseAgent && keyPath == "" {
+		keyPath = defaultSSHKeyPath()
+	}
+
+	// Fall back to ssh-agent when no key file is available
+	mode := AuthModeSSHKey
+	if options.UseAgent || keyPath == "" {
+		mode = AuthModeSSHAgent
+	}
+
+	hostKeyCallback, err := knownHostsCallback(options.KnownHostsPath)
+	if err != nil {
+		return nil, mode, &GitError{
+			Op:      "ssh_auth",
+			Err:     ErrAuthenticationFailed,
+			Details: fmt.Sprintf("Unable to load known_hosts: %v", err),
+		}
+	}
+
+	if mode == AuthModeSSHAgent {
+		auth, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, AuthModeSSHAgent, &GitError{
+				Op:      "ssh_auth",
+				Err:     ErrAuthenticationFailed,
+				Details: authFailureDetails(AuthModeSSHAgent) + fmt.Sprintf(" (%v)", err),
+			}
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, AuthModeSSHAgent, nil
+	}
+
+	// The passphrase is optional and kept in the credential store keyed by the key path
+	passphrase, _ := ap.credService.GetCredential(sshPassphraseAccount(keyPath))
+
+	auth, err := ssh.NewPublicKeysFromFile(user, keyPath, passphrase)
+	if err != nil {
+		return nil, AuthModeSSHKey, &GitError{
+			Op:      "ssh_auth",
+			Err:     ErrAuthenticationFailed,
+			Details: authFailureDetails(AuthModeSSHKey) + fmt.Sprintf(" (%v)", err),
+		}
+	}
+	auth.HostKeyCallback = hostKeyCallback
+	return auth, AuthModeSSHKey, nil
+}
+
+// knownHostsCallback builds a host key callback that verifies against a known_hosts
+// file, or the default locations when the path is empty
+func knownHostsCallback(knownHostsPath string) (gossh.HostKeyCallback, error) {
+	if knownHostsPath != "" {
+		return ssh.NewKnownHostsCallback(knownHostsPath)
+	}
+	return ssh.NewKnownHostsCallback()
+}
+
+// defaultSSHKeyPath returns the first default private key found in ~/.ssh
+func defaultSSHKeyPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		keyPath := filepath.Join(homeDir, ".ssh", name)
+		if _, err := os.Stat(keyPath); err == nil {
+			return keyPath
+		}
+	}
+
+	return ""
+}
+
+// sshPassphraseAccount returns the credential store account for a key passphrase
+func sshPassphraseAccount(keyPath string) string {
+	return "ssh-passphrase:" + keyPath
+}
+
+// authFailureDetails returns a human-readable hint for an authentication failure in the given mode
+func authFailureDetails(mode AuthMode) string {
+	switch mode {
+	case AuthModeToken:
+		return "Authentication failed. Check your Personal Access Token."
+	case AuthModeSSHKey:
+		return "SSH authentication failed. Check that the private key is registered with the remote and the passphrase is correct."
+	case AuthModeSSHAgent:
+		return "SSH agent authentication failed. Check that ssh-agent is running (SSH_AUTH_SOCK) and has a key loaded for this remote."
+	default:
+		return "Authentication required. Configure a Personal Access Token or an SSH key for this repository."
+	}
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTestServer serves git-upload-pack over SSH to clients with the authorized key
+type sshTestServer struct {
+	addr    string
+	hostKey gossh.PublicKey
+}
+
+// startSSHTestServer starts an SSH server on localhost that runs git upload-pack for
+// exec requests. It stops when the test ends.
+func startSSHTestServer(t *testing.T, authorized gossh.PublicKey) *sshTestServer {
+	t.Helper()
+	requireGit(t)
+
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	hostSigner, err := gossh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+
+	serverConfig := &gossh.ServerConfig{
+		PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized key")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSHConn(conn, serverConfig)
+		}
+	}()
+
+	return &sshTestServer{addr: listener.Addr().String(), hostKey: hostSigner.PublicKey()}
+}
+
+// serveSSHConn runs the exec requests of one SSH connection
+func serveSSHConn(conn net.Conn, serverConfig *gossh.ServerConfig) {
+	defer conn.Close()
+
+	_, channels, requests, err := gossh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" || len(request.Payload) < 4 {
+					request.Reply(false, nil)
+					continue
+				}
+				request.Reply(true, nil)
+
+				// The command is "git-upload-pack '<path>'"
+				command := string(request.Payload[4:])
+				service, repoPath, _ := strings.Cut(command, " ")
+				cmd := exec.Command("git", strings.TrimPrefix(service, "git-"), strings.Trim(repoPath, "'"))
+				cmd.Stdin = channel
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+
+				status := uint32(0)
+				if err := cmd.Run(); err != nil {
+					status = 1
+				}
+				exitStatus := make([]byte, 4)
+				binary.BigEndian.PutUint32(exitStatus, status)
+				channel.SendRequest("exit-status", false, exitStatus)
+				return
+			}
+		}()
+	}
+}
+
+// newTestSSHKey writes a new unencrypted private key file and returns its path and public key
+func newTestSSHKey(t *testing.T) (string, gossh.PublicKey) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block, err := gossh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	return keyPath, signer.PublicKey()
+}
+
+// writeKnownHosts writes a known_hosts file listing the keys for the address
+func writeKnownHosts(t *testing.T, addr string, keys ...gossh.PublicKey) string {
+	t.Helper()
+
+	var lines strings.Builder
+	for _, key := range keys {
+		lines.WriteString(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n")
+	}
+	knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHostsPath, []byte(lines.String()), 0600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	return knownHostsPath
+}
+
+// newTestAuthProvider returns an auth provider whose credentials live in a temp file
+func newTestAuthProvider(t *testing.T, options SSHAuthOptions) *DefaultAuthProvider {
+	t.Helper()
+
+	backend := NewEncryptedFileBackend(filepath.Join(t.TempDir(), "credentials.enc"))
+	provider := NewAuthProvider(NewCredentialServiceWithBackend(backend))
+	provider.SetSSHOptions(options)
+	return provider
+}
+
+// listRemoteOverSSH lists the refs of the repository through the provider's auth method
+func listRemoteOverSSH(t *testing.T, provider *DefaultAuthProvider, server *sshTestServer, repoPath string) error {
+	t.Helper()
+
+	repoURL := fmt.Sprintf("ssh://git@%s%s", server.addr, filepath.ToSlash(repoPath))
+	auth, mode, err := provider.AuthMethod(repoURL)
+	if err != nil {
+		return err
+	}
+	if mode != AuthModeSSHKey {
+		t.Fatalf("auth mode = %s, want %s", mode, AuthModeSSHKey)
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return classifyGitError("list", mode, err)
+	}
+	if len(refs) == 0 {
+		t.Fatal("no refs listed")
+	}
+	return nil
+}
+
+func TestSSHAuthKnownHosts(t *testing.T) {
+	keyPath, publicKey := newTestSSHKey(t)
+	server := startSSHTestServer(t, publicKey)
+	repoPath := initTestRepo(t, map[string]string{"note.md": "# Note\n"})
+
+	_, otherKey := newTestSSHKey(t)
+	tests := []struct {
+		name       string
+		knownHosts []gossh.PublicKey
+		wantErr    bool
+	}{
+		{name: "known host", knownHosts: []gossh.PublicKey{server.hostKey}},
+		{name: "changed host key", knownHosts: []gossh.PublicKey{otherKey}, wantErr: true},
+		{name: "unknown host", knownHosts: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestAuthProvider(t, SSHAuthOptions{
+				KeyPath:        keyPath,
+				KnownHostsPath: writeKnownHosts(t, server.addr, tt.knownHosts...),
+			})
+
+			err := listRemoteOverSSH(t, provider, server, repoPath)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("list remote: %v", err)
+				}
+				return
+			}
+
+			var gitErr *GitError
+			if !errors.As(err, &gitErr) || !errors.Is(gitErr.Err, ErrAuthenticationFailed) {
+				t.Fatalf("error = %v, want a host key verification failure", err)
+			}
+			if !strings.Contains(gitErr.Details, "Host key verification failed") {
+				t.Fatalf("details = %q, want a host key hint", gitErr.Details)
+			}
+		})
+	}
+}
+
+func TestSSHKeyAuth(t *testing.T) {
+	authorizedKeyPath, authorizedKey := newTestSSHKey(t)
+	otherKeyPath, _ := newTestSSHKey(t)
+	server := startSSHTestServer(t, authorizedKey)
+	repoPath := initTestRepo(t, map[string]string{"note.md": "# Note\n"})
+	knownHostsPath := writeKnownHosts(t, server.addr, server.hostKey)
+
+	tests := []struct {
+		name    string
+		keyPath string
+		wantErr bool
+	}{
+		{name: "authorized key", keyPath: authorizedKeyPath},
+		{name: "unauthorized key", keyPath: otherKeyPath, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestAuthProvider(t, SSHAuthOptions{KeyPath: tt.keyPath, KnownHostsPath: knownHostsPath})
+
+			err := listRemoteOverSSH(t, provider, server, repoPath)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("list remote: %v", err)
+				}
+				return
+			}
+
+			var gitErr *GitError
+			if !errors.As(err, &gitErr) || !errors.Is(gitErr.Err, ErrAuthenticationFailed) {
+				t.Fatalf("error = %v, want an authentication failure", err)
+			}
+		})
+	}
+}
+
+func TestSSHOptionsConcurrentAccess(t *testing.T) {
+	keyPath, _ := newTestSSHKey(t)
+	knownHostsPath := writeKnownHosts(t, "127.0.0.1:22")
+	provider := newTestAuthProvider(t, SSHAuthOptions{KeyPath: keyPath, KnownHostsPath: knownHostsPath})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				provider.SetSSHOptions(SSHAuthOptions{KeyPath: keyPath, KnownHostsPath: knownHostsPath})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, _, err := provider.AuthMethod("ssh://git@127.0.0.1/notes.git"); err != nil {
+					t.Errorf("auth method: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
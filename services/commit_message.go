@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Change actions reported for staged files
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	ChangeRenamed  = "renamed"
+)
+
+// maxListedFiles is the number of files listed individually in a commit message
+const maxListedFiles = 20
+
+// DefaultCommitMessageTemplate is used when no template is configured
+const DefaultCommitMessageTemplate = `{{.Summary}}
+
+{{range .ListedFiles}}- {{.Action}}: {{.Path}}{{if .OldPath}} (from {{.OldPath}}){{end}}{{if .Title}} "{{.Title}}"{{end}}
+{{end}}{{if .MoreFiles}}- ... and {{.MoreFiles}} more
+{{end}}
+Synced by GitNotes from {{.Hostname}} on {{.Date}}`
+
+// CommitFileChange describes one staged file in a commit
+type CommitFileChange struct {
+	Action  string `json:"action"`            // added, modified, deleted or renamed
+	Path    string `json:"path"`              // Repository-relative path
+	OldPath string `json:"oldPath,omitempty"` // Previous path for renames
+	Title   string `json:"title,omitempty"`   // First Markdown heading of the note
+}
+
+// CommitMessageData is the data available to commit message templates
+type CommitMessageData struct {
+	Summary     string             // One-line summary of the changeset
+	Hostname    string             // Machine the commit was made on
+	Date        string             // Commit date, formatted as 2006-01-02 15:04
+	Time        time.Time          // Commit time
+	Files       []CommitFileChange // All changed files
+	ListedFiles []CommitFileChange // The first files, for templates that list them
+	MoreFiles   int                // Number of files not in ListedFiles
+	Added       int
+	Modified    int
+	Deleted     int
+	Renamed     int
+}
+
+// CommitMessageGenerator builds commit messages from staged changes using a template
+type CommitMessageGenerator struct {
+	template *template.Template
+}
+
+// NewCommitMessageGenerator parses the template, using the default if it's empty
+func NewCommitMessageGenerator(tmpl string) (*CommitMessageGenerator, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = DefaultCommitMessageTemplate
+	}
+
+	parsed, err := template.New("commit").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit message template: %w", err)
+	}
+
+	generator := &CommitMessageGenerator{template: parsed}
+
+	// Execute once with sample data so field typos are reported up front
+	sample := []CommitFileChange{{Action: ChangeModified, Path: "note.md", Title: "Note"}}
+	if _, err := generator.Generate(sample); err != nil {
+		return nil, err
+	}
+
+	return generator, nil
+}
+
+// Generate renders the commit message for the changes
+func (g *CommitMessageGenerator) Generate(changes []CommitFileChange) (string, error) {
+	data := newCommitMessageData(changes)
+
+	var message bytes.Buffer
+	if err := g.template.Execute(&message, data); err != nil {
+		return "", fmt.Errorf("error rendering commit message: %w", err)
+	}
+
+	return strings.TrimSpace(message.String()), nil
+}
+
+// newCommitMessageData summarizes the changes for the template
+func newCommitMessageData(changes []CommitFileChange) CommitMessageData {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown host"
+	}
+
+	now := time.Now()
+	data := CommitMessageData{
+		Hostname: hostname,
+		Date:     now.Format("2006-01-02 15:04"),
+		Time:     now,
+		Files:    changes,
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case ChangeAdded:
+			data.Added++
+		case ChangeModified:
+			data.Modified++
+		case ChangeDeleted:
+			data.Deleted++
+		case ChangeRenamed:
+			data.Renamed++
+		}
+	}
+
+	data.ListedFiles = changes
+	if len(changes) > maxListedFiles {
+		data.ListedFiles = changes[:maxListedFiles]
+		data.MoreFiles = len(changes) - maxListedFiles
+	}
+
+	data.Summary = summarizeChanges(data)
+	return data
+}
+
+// summarizeChanges returns a one-line summary, naming the notes for small changesets
+// and giving counts for large ones
+func summarizeChanges(data CommitMessageData) string {
+	switch len(data.Files) {
+	case 0:
+		return "Update notes"
+	case 1:
+		change := data.Files[0]
+		return changeVerbs[change.Action] + " " + changeObject(change)
+	case 2, 3:
+		// One verb when every note changed the same way, e.g. "Add A, B", otherwise
+		// one per note, e.g. "Add A, update B"
+		sameAction := true
+		for _, change := range data.Files[1:] {
+			sameAction = sameAction && change.Action == data.Files[0].Action
+		}
+
+		parts := make([]string, 0, len(data.Files))
+		for i, change := range data.Files {
+			switch {
+			case sameAction && i > 0:
+				parts = append(parts, changeObject(change))
+			case i > 0:
+				parts = append(parts, strings.ToLower(changeVerbs[change.Action])+" "+changeObject(change))
+			default:
+				parts = append(parts, changeVerbs[change.Action]+" "+changeObject(change))
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	counts := make([]string, 0, 4)
+	for _, count := range []struct {
+		n     int
+		label string
+	}{
+		{data.Added, "added"},
+		{data.Modified, "modified"},
+		{data.Deleted, "deleted"},
+		{data.Renamed, "renamed"},
+	} {
+		if count.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", count.n, count.label))
+		}
+	}
+
+	return fmt.Sprintf("Update %d notes (%s)", len(data.Files), strings.Join(counts, ", "))
+}
+
+// changeVerbs are the summary verbs of each change action
+var changeVerbs = map[string]string{
+	ChangeAdded:    "Add",
+	ChangeModified: "Update",
+	ChangeDeleted:  "Delete",
+	ChangeRenamed:  "Rename",
+}
+
+// changeObject names a changed note in a summary, a rename with both paths
+func changeObject(change CommitFileChange) string {
+	if change.Action == ChangeRenamed {
+		return fmt.Sprintf("%s to %s", change.OldPath, change.Path)
+	}
+	return displayName(change)
+}
+
+// displayName returns the note title, or the file name when there is no heading
+func displayName(change CommitFileChange) string {
+	if change.Title != "" {
+		return change.Title
+	}
+	return filepath.Base(change.Path)
+}
+
+// isMarkdownPath reports whether the path has a Markdown extension
+func isMarkdownPath(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// headingPattern matches an ATX Markdown heading
+var headingPattern = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*\s*$`)
+
+// firstHeading returns the text of the first Markdown heading, skipping front matter
+func firstHeading(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inFrontMatter := false
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if lineNumber == 0 && strings.TrimSpace(line) == "---" {
+			inFrontMatter = true
+			continue
+		}
+		if inFrontMatter {
+			if strings.TrimSpace(line) == "---" {
+				inFrontMatter = false
+			}
+			continue
+		}
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
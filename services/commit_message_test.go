@@ -0,0 +1,58 @@
+package services
+
+import "testing"
+
+func TestSummarizeChanges(t *testing.T) {
+	added := CommitFileChange{Action: ChangeAdded, Path: "ideas.md", Title: "Ideas"}
+	modified := CommitFileChange{Action: ChangeModified, Path: "todo.md"}
+	deleted := CommitFileChange{Action: ChangeDeleted, Path: "old.md", Title: "Old"}
+	renamed := CommitFileChange{Action: ChangeRenamed, Path: "b.md", OldPath: "a.md"}
+
+	tests := []struct {
+		name    string
+		changes []CommitFileChange
+		want    string
+	}{
+		{name: "none", want: "Update notes"},
+		{name: "added", changes: []CommitFileChange{added}, want: "Add Ideas"},
+		{name: "renamed", changes: []CommitFileChange{renamed}, want: "Rename a.md to b.md"},
+		{
+			name:    "all added",
+			changes: []CommitFileChange{added, {Action: ChangeAdded, Path: "plan.md"}},
+			want:    "Add Ideas, plan.md",
+		},
+		{
+			name:    "all deleted",
+			changes: []CommitFileChange{deleted, {Action: ChangeDeleted, Path: "older.md"}, {Action: ChangeDeleted, Path: "oldest.md"}},
+			want:    "Delete Old, older.md, oldest.md",
+		},
+		{
+			name:    "all modified",
+			changes: []CommitFileChange{modified, {Action: ChangeModified, Path: "done.md"}},
+			want:    "Update todo.md, done.md",
+		},
+		{
+			name:    "mixed",
+			changes: []CommitFileChange{added, modified, deleted},
+			want:    "Add Ideas, update todo.md, delete Old",
+		},
+		{
+			name:    "mixed with a rename",
+			changes: []CommitFileChange{renamed, modified},
+			want:    "Rename a.md to b.md, update todo.md",
+		},
+		{
+			name:    "counts",
+			changes: []CommitFileChange{added, modified, deleted, renamed},
+			want:    "Update 4 notes (1 added, 1 modified, 1 deleted, 1 renamed)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newCommitMessageData(tt.changes).Summary; got != tt.want {
+				t.Fatalf("summary = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/crypto/ssh"
+)
+
+// SigningFormat identifies how commits are signed
+type SigningFormat string
+
+// SigningFormat constants define the supported commit signature formats
+const (
+	SigningFormatNone    SigningFormat = ""        // Commits are not signed
+	SigningFormatOpenPGP SigningFormat = "openpgp" // Armored OpenPGP signature, like gpg.format=openpgp
+	SigningFormatSSH     SigningFormat = "ssh"     // SSHSIG signature, like gpg.format=ssh
+)
+
+// CommitSigningOptions configures commit signing
+type CommitSigningOptions struct {
+	Format  SigningFormat `json:"format"`
+	KeyPath string        `json:"keyPath"` // Armored OpenPGP secret key or SSH private key file
+}
+
+// NewCommitSigner loads the signing key and returns a go-git signer for the format
+func NewCommitSigner(options CommitSigningOptions, passphrase string) (git.Signer, error) {
+	if options.Format == SigningFormatNone {
+		return nil, nil
+	}
+	if options.KeyPath == "" {
+		return nil, errors.New("signing key path is required")
+	}
+
+	keyData, err := os.ReadFile(options.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+
+	switch options.Format {
+	case SigningFormatOpenPGP:
+		return newOpenPGPSigner(keyData, passphrase)
+	case SigningFormatSSH:
+		return newSSHSigner(keyData, passphrase)
+	default:
+		return nil, fmt.Errorf("unsupported signing format: %s", options.Format)
+	}
+}
+
+// signingPassphraseAccount returns the credential store account for a signing key passphrase
+func signingPassphraseAccount(keyPath string) string {
+	return "signing-passphrase:" + keyPath
+}
+
+// openPGPSigner signs commits with an OpenPGP key
+type openPGPSigner struct {
+	entity *openpgp.Entity
+}
+
+// newOpenPGPSigner reads the first secret key from an armored key ring
+func newOpenPGPSigner(keyData []byte, passphrase string) (*openPGPSigner, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyData))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing OpenPGP key: %w", err)
+	}
+	if len(entities) == 0 || entities[0].PrivateKey == nil {
+		return nil, errors.New("OpenPGP key file contains no secret key")
+	}
+
+	entity := entities[0]
+	if entity.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return nil, errors.New("OpenPGP key is passphrase protected")
+		}
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("error decrypting OpenPGP key: %w", err)
+		}
+	}
+
+	return &openPGPSigner{entity: entity}, nil
+}
+
+// Sign returns an armored detached signature of the message
+func (s *openPGPSigner) Sign(message io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, s.entity, message, nil); err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
+
+// sshSigner signs commits in the SSHSIG format used by git's gpg.format=ssh
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+// sshSigNamespace is the namespace git uses for commit signatures
+const sshSigNamespace = "git"
+
+// newSSHSigner parses an SSH private key
+func newSSHSigner(keyData []byte, passphrase string) (*sshSigner, error) {
+	var signer ssh.Signer
+	var err error
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing SSH signing key: %w", err)
+	}
+
+	return &sshSigner{signer: signer}, nil
+}
+
+// Sign returns an armored SSHSIG signature of the message
+// See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func (s *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	hash := sha512.New()
+	if _, err := io.Copy(hash, message); err != nil {
+		return nil, err
+	}
+
+	// The signed data is the preamble followed by the namespace and message hash
+	signedData := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSigNamespace, "", "sha512", hash.Sum(nil)})...)
+
+	// RSA keys must use a SHA-2 signature algorithm, ssh-rsa (SHA-1) is rejected by git
+	var signature *ssh.Signature
+	var err error
+	if algorithmSigner, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		signature, err = algorithmSigner.SignWithAlgorithm(rand.Reader, signedData, ssh.KeyAlgoRSASHA512)
+	} else {
+		signature, err = s.signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error signing commit: %w", err)
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, s.signer.PublicKey().Marshal(), sshSigNamespace, "", "sha512", ssh.Marshal(signature)})...)
+
+	// Armor the signature, wrapping the base64 body at 70 columns like ssh-keygen
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored bytes.Buffer
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----\n")
+
+	return armored.Bytes(), nil
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Hunk resolution choices
+const (
+	HunkChoiceOurs   = "ours"   // Keep the local text
+	HunkChoiceTheirs = "theirs" // Keep the remote text
+	HunkChoiceBoth   = "both"   // Keep the local text followed by the remote text
+	HunkChoiceCustom = "custom" // Replace the hunk with text from the UI
+)
+
+// ErrConflictNotFound is returned when a file has no unresolved conflict
+var ErrConflictNotFound = errors.New("file has no unresolved conflict")
+
+// ConflictChunk is a part of a conflicted file: text that merged cleanly, or a
+// conflict hunk with the text of each side
+type ConflictChunk struct {
+	Hunk   int    `json:"hunk"`           // Index of the conflict hunk, -1 for merged text
+	Text   string `json:"text,omitempty"` // Merged text
+	Base   string `json:"base,omitempty"` // Common ancestor text of a hunk
+	Ours   string `json:"ours,omitempty"`
+	Theirs string `json:"theirs,omitempty"`
+}
+
+// ConflictFile is a conflicted file split into merged text and conflict hunks.
+// Binary files and rename conflicts have no chunks and are resolved as a whole.
+type ConflictFile struct {
+	ConflictInfo
+	Chunks    []ConflictChunk `json:"chunks"`
+	HunkCount int             `json:"hunkCount"`
+}
+
+// HunkResolution is the choice for one conflict hunk
+type HunkResolution struct {
+	Hunk   int    `json:"hunk"`
+	Choice string `json:"choice"`         // HunkChoiceOurs, HunkChoiceTheirs, HunkChoiceBoth or HunkChoiceCustom
+	Text   string `json:"text,omitempty"` // Replacement text for HunkChoiceCustom
+}
+
+// ConflictResolutionResult reports the state of the sync after a file was resolved
+type ConflictResolutionResult struct {
+	Path          string   `json:"path"`
+	Remaining     []string `json:"remaining"`     // Files that still have conflicts
+	SyncCompleted bool     `json:"syncCompleted"` // The merge was committed and pushed
+}
+
+// GetConflictFile splits a conflicted file into merged text and conflict hunks
+func (sm *SyncManager) GetConflictFile(relPath string) (ConflictFile, error) {
+	conflict, err := sm.findConflict(relPath)
+	if err != nil {
+		return ConflictFile{}, err
+	}
+
+	file := ConflictFile{ConflictInfo: conflict, Chunks: make([]ConflictChunk, 0)}
+	if !hunkResolvable(conflict) {
+		return file, nil
+	}
+
+	base, ours, theirs, err := sm.gitService.ConflictVersions(conflict)
+	if err != nil {
+		return ConflictFile{}, err
+	}
+
+	for _, chunk := range merge3(splitLines(string(base)), splitLines(string(ours)), splitLines(string(theirs))) {
+		if !chunk.Conflict {
+			file.Chunks = append(file.Chunks, ConflictChunk{Hunk: -1, Text: chunk.Text})
+			continue
+		}
+		file.Chunks = append(file.Chunks, ConflictChunk{
+			Hunk:   file.HunkCount,
+			Base:   chunk.Base,
+			Ours:   chunk.Ours,
+			Theirs: chunk.Theirs,
+		})
+		file.HunkCount++
+	}
+
+	return file, nil
+}
+
+// ResolveConflictHunks writes a conflicted file merged with a choice for every hunk
+// and marks it resolved. Once no conflicts remain the merge is committed and pushed.
+func (sm *SyncManager) ResolveConflictHunks(relPath string, resolutions []HunkResolution) (ConflictResolutionResult, error) {
+	file, err := sm.GetConflictFile(relPath)
+	if err != nil {
+		return ConflictResolutionResult{}, err
+	}
+	if !hunkResolvable(file.ConflictInfo) {
+		return ConflictResolutionResult{}, fmt.Errorf("%s conflicts in %s must be resolved as a whole file", file.Type, relPath)
+	}
+
+	choices := make(map[int]HunkResolution, len(resolutions))
+	for _, resolution := range resolutions {
+		choices[resolution.Hunk] = resolution
+	}
+
+	var merged strings.Builder
+	keptDeletingSide := true
+	for _, chunk := range file.Chunks {
+		if chunk.Hunk < 0 {
+			merged.WriteString(chunk.Text)
+			continue
+		}
+
+		resolution, ok := choices[chunk.Hunk]
+		if !ok {
+			return ConflictResolutionResult{}, fmt.Errorf("hunk %d of %s is unresolved", chunk.Hunk, relPath)
+		}
+
+		switch resolution.Choice {
+		case HunkChoiceOurs:
+			merged.WriteString(chunk.Ours)
+		case HunkChoiceTheirs:
+			merged.WriteString(chunk.Theirs)
+		case HunkChoiceBoth:
+			merged.WriteString(chunk.Ours)
+			if chunk.Ours != "" && !strings.HasSuffix(chunk.Ours, "\n") {
+				merged.WriteString("\n")
+			}
+			merged.WriteString(chunk.Theirs)
+		case HunkChoiceCustom:
+			merged.WriteString(resolution.Text)
+		default:
+			return ConflictResolutionResult{}, fmt.Errorf("invalid choice for hunk %d: %s", chunk.Hunk, resolution.Choice)
+		}
+
+		if resolution.Choice != file.DeletedBy {
+			keptDeletingSide = false
+		}
+	}
+
+	// Siding with the deletion in a modify/delete conflict deletes the file
+	content := []byte(merged.String())
+	if file.Type == ConflictTypeModifyDelete && keptDeletingSide && merged.Len() == 0 {
+		content = nil
+	}
+
+	return sm.markConflictResolved(relPath, content)
+}
+
+// ResolveConflictFile resolves a conflicted file as a whole by keeping one side's
+// version. The file is deleted when that side deleted or renamed it.
+func (sm *SyncManager) ResolveConflictFile(relPath string, choice string) (ConflictResolutionResult, error) {
+	if choice != HunkChoiceOurs && choice != HunkChoiceTheirs {
+		return ConflictResolutionResult{}, fmt.Errorf("invalid choice for %s: %s (must be 'ours' or 'theirs')", relPath, choice)
+	}
+
+	conflict, err := sm.findConflict(relPath)
+	if err != nil {
+		return ConflictResolutionResult{}, err
+	}
+
+	_, ours, theirs, err := sm.gitService.ConflictVersions(conflict)
+	if err != nil {
+		return ConflictResolutionResult{}, err
+	}
+
+	content := ours
+	if choice == HunkChoiceTheirs {
+		content = theirs
+	}
+
+	return sm.markConflictResolved(relPath, content)
+}
+
+// findConflict returns the index conflict of a file
+func (sm *SyncManager) findConflict(relPath string) (ConflictInfo, error) {
+	conflicts, err := sm.gitService.Conflicts()
+	if err != nil {
+		return ConflictInfo{}, err
+	}
+
+	for _, conflict := range conflicts {
+		if conflict.Path == relPath {
+			return conflict, nil
+		}
+	}
+	return ConflictInfo{}, fmt.Errorf("%w: %s", ErrConflictNotFound, relPath)
+}
+
+// markConflictResolved stages the resolved file and completes the sync when it was the
+// last conflict
+func (sm *SyncManager) markConflictResolved(relPath string, content []byte) (ConflictResolutionResult, error) {
+	result := ConflictResolutionResult{Path: relPath, Remaining: make([]string, 0)}
+
+	release, err := sm.vaultLock().BeginSync(sm.ctx)
+	if err != nil {
+		return result, err
+	}
+	err = sm.gitService.MarkResolved(relPath, content)
+	release()
+	if err != nil {
+		sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to resolve %s", relPath), err)
+		return result, err
+	}
+	sm.observers.notify(absolutePaths(sm.gitService.repoPath, []string{relPath})...)
+
+	conflicts, err := sm.DetectConflicts()
+	if err != nil {
+		return result, err
+	}
+	for _, conflict := range conflicts {
+		result.Remaining = append(result.Remaining, conflict.Path)
+	}
+
+	if len(conflicts) > 0 {
+		sm.updateStatus(SyncStatusConflict, fmt.Sprintf("Resolved %s, %d files with conflicts remaining", relPath, len(conflicts)), nil)
+		return result, nil
+	}
+
+	if err := sm.completeMerge(); err != nil {
+		return result, err
+	}
+	result.SyncCompleted = true
+	return result, nil
+}
+
+// completeMerge commits the resolved merge and pushes it, finishing the sync that
+// stopped at the conflicts
+func (sm *SyncManager) completeMerge() error {
+	sm.beginRun()
+	err := sm.commitAndPushMerge()
+	sm.finishRun(err)
+	return err
+}
+
+// commitAndPushMerge runs the commit and push steps of a sync for a resolved merge
+func (sm *SyncManager) commitAndPushMerge() error {
+	sm.updateStatus(SyncStatusCommit, "Committing resolved conflicts", nil)
+	if err := sm.gitService.CommitMerge(""); err != nil {
+		sm.updateStatus(SyncStatusError, "Failed to commit resolved conflicts", err)
+		return err
+	}
+
+	sm.mu.Lock()
+	sm.prePullHead = plumbing.ZeroHash
+	sm.prePullUntracked = nil
+	sm.mu.Unlock()
+
+	sm.updateStatus(SyncStatusPushing, "Pushing resolved conflicts to remote", nil)
+	if err := sm.gitService.PushChanges(); err != nil {
+		sm.updateStatus(SyncStatusError, "Failed to push changes", err)
+		return err
+	}
+
+	sm.updateStatus(SyncStatusSuccess, "Conflicts resolved and changes pushed", nil)
+	return nil
+}
+
+// hunkResolvable reports whether a conflict can be resolved hunk by hunk
+func hunkResolvable(conflict ConflictInfo) bool {
+	return !conflict.Binary && conflict.Type != ConflictTypeRename
+}
+
+// GetConflictFile returns a conflicted note split into merged text and conflict hunks
+// with the base, ours and theirs text of each hunk, as JSON
+func (gns *GitNotesService) GetConflictFile(vaultID string, filePath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := v.syncManager.GetConflictFile(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	fileJSON, err := json.Marshal(file)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling conflict file: %w", err)
+	}
+
+	return string(fileJSON), nil
+}
+
+// ResolveConflictHunks resolves a conflicted note with a choice for every hunk, given as a
+// JSON array of HunkResolution. The sync completes once every file is resolved.
+// It returns a ConflictResolutionResult as JSON.
+func (gns *GitNotesService) ResolveConflictHunks(vaultID string, filePath string, resolutionsJSON string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if v.syncManager.IsSyncing() {
+		return "", errors.New("cannot resolve conflicts while a sync is in progress")
+	}
+
+	var resolutions []HunkResolution
+	if err := json.Unmarshal([]byte(resolutionsJSON), &resolutions); err != nil {
+		return "", fmt.Errorf("invalid hunk resolutions: %w", err)
+	}
+
+	result, err := v.syncManager.ResolveConflictHunks(relPath, resolutions)
+	if err != nil {
+		return "", err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling resolution result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
+
+// ResolveConflictFile resolves a conflicted file as a whole with "ours" or "theirs",
+// for binary files and rename conflicts. It returns a ConflictResolutionResult as JSON.
+func (gns *GitNotesService) ResolveConflictFile(vaultID string, filePath string, choice string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if v.syncManager.IsSyncing() {
+		return "", errors.New("cannot resolve conflicts while a sync is in progress")
+	}
+
+	result, err := v.syncManager.ResolveConflictFile(relPath, choice)
+	if err != nil {
+		return "", err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling resolution result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newContentConflict syncs a clone whose note.md conflicts with the remote and
+// returns its sync manager, stopped at the conflict
+func newContentConflict(t *testing.T) (*SyncManager, string) {
+	t.Helper()
+
+	gitService, remoteDir := divergedClone(t, "# Note\n\nfirst\n\nlast\n",
+		map[string]string{"note.md": "# Note\n\nremote edit\n\nlast\n"},
+		map[string]string{"note.md": "# Note\n\nlocal edit\n\nlast\n"})
+	sm := NewSyncManager(gitService)
+	if _, err := sm.TriggerManualSync(); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("sync error = %v, want a merge conflict", err)
+	}
+	return sm, remoteDir
+}
+
+// newModifyDeleteConflict syncs a clone that edited note.md after the remote deleted
+// it and returns its sync manager, stopped at the conflict
+func newModifyDeleteConflict(t *testing.T) *SyncManager {
+	t.Helper()
+
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n", "other.md": "other\n"})
+	gitService := cloneTestRemote(t, remoteDir)
+	other := cloneTestRemote(t, remoteDir)
+	if err := os.Remove(filepath.Join(other.repoPath, "note.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSyncManager(other).TriggerManualSync(); err != nil {
+		t.Fatalf("sync other clone: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(gitService.repoPath, "note.md"), "# Note\n\nlocal edit\n")
+	sm := NewSyncManager(gitService)
+	if _, err := sm.TriggerManualSync(); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("sync error = %v, want a merge conflict", err)
+	}
+	return sm
+}
+
+func TestResolveConflictHunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution HunkResolution
+		want       string
+	}{
+		{name: "ours", resolution: HunkResolution{Choice: HunkChoiceOurs}, want: "# Note\n\nlocal edit\n\nlast\n"},
+		{name: "theirs", resolution: HunkResolution{Choice: HunkChoiceTheirs}, want: "# Note\n\nremote edit\n\nlast\n"},
+		{name: "both", resolution: HunkResolution{Choice: HunkChoiceBoth}, want: "# Note\n\nlocal edit\nremote edit\n\nlast\n"},
+		{name: "custom", resolution: HunkResolution{Choice: HunkChoiceCustom, Text: "merged edit\n"}, want: "# Note\n\nmerged edit\n\nlast\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, remoteDir := newContentConflict(t)
+
+			file, err := sm.GetConflictFile("note.md")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.HunkCount != 1 {
+				t.Fatalf("conflict file has %d hunks, want 1: %+v", file.HunkCount, file.Chunks)
+			}
+
+			result, err := sm.ResolveConflictHunks("note.md", []HunkResolution{tt.resolution})
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if !result.SyncCompleted || len(result.Remaining) != 0 {
+				t.Fatalf("result = %+v, want the sync completed", result)
+			}
+			if got := readTestFile(t, filepath.Join(sm.gitService.repoPath, "note.md")); got != tt.want {
+				t.Errorf("note.md = %q, want %q", got, tt.want)
+			}
+			if got := mustRunGit(t, remoteDir, "show", "master:note.md") + "\n"; got != tt.want {
+				t.Errorf("remote note.md = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveConflictHunksRejectsIncompleteResolutions(t *testing.T) {
+	sm, _ := newContentConflict(t)
+
+	tests := []struct {
+		name        string
+		resolutions []HunkResolution
+		errText     string
+	}{
+		{name: "missing hunk", resolutions: nil, errText: "hunk 0 of note.md is unresolved"},
+		{name: "other hunk", resolutions: []HunkResolution{{Hunk: 1, Choice: HunkChoiceOurs}}, errText: "hunk 0 of note.md is unresolved"},
+		{name: "invalid choice", resolutions: []HunkResolution{{Choice: "mine"}}, errText: "invalid choice for hunk 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sm.ResolveConflictHunks("note.md", tt.resolutions)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error = %v, want %q", err, tt.errText)
+			}
+		})
+	}
+
+	// The rejected resolutions left the conflict in place
+	if conflicts, err := sm.DetectConflicts(); err != nil || len(conflicts) != 1 {
+		t.Fatalf("conflicts = %+v, %v, want note.md still conflicted", conflicts, err)
+	}
+	if _, err := sm.ResolveConflictHunks("other.md", nil); !errors.Is(err, ErrConflictNotFound) {
+		t.Errorf("resolving a file without conflict = %v, want ErrConflictNotFound", err)
+	}
+}
+
+func TestResolveModifyDeleteConflict(t *testing.T) {
+	tests := []struct {
+		name        string
+		resolve     func(sm *SyncManager) (ConflictResolutionResult, error)
+		wantDeleted bool
+	}{
+		{
+			name: "hunk siding with the deletion",
+			resolve: func(sm *SyncManager) (ConflictResolutionResult, error) {
+				return sm.ResolveConflictHunks("note.md", []HunkResolution{{Choice: HunkChoiceTheirs}})
+			},
+			wantDeleted: true,
+		},
+		{
+			name: "hunk keeping the edit",
+			resolve: func(sm *SyncManager) (ConflictResolutionResult, error) {
+				return sm.ResolveConflictHunks("note.md", []HunkResolution{{Choice: HunkChoiceOurs}})
+			},
+		},
+		{
+			name: "file siding with the deletion",
+			resolve: func(sm *SyncManager) (ConflictResolutionResult, error) {
+				return sm.ResolveConflictFile("note.md", HunkChoiceTheirs)
+			},
+			wantDeleted: true,
+		},
+		{
+			name: "file keeping the edit",
+			resolve: func(sm *SyncManager) (ConflictResolutionResult, error) {
+				return sm.ResolveConflictFile("note.md", HunkChoiceOurs)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newModifyDeleteConflict(t)
+			conflicts, err := sm.DetectConflicts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 || conflicts[0].Type != ConflictTypeModifyDelete || conflicts[0].DeletedBy != ConflictSideTheirs {
+				t.Fatalf("conflicts = %+v, want note.md deleted by theirs", conflicts)
+			}
+
+			result, err := tt.resolve(sm)
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if !result.SyncCompleted {
+				t.Fatalf("result = %+v, want the sync completed", result)
+			}
+
+			_, err = os.Stat(filepath.Join(sm.gitService.repoPath, "note.md"))
+			if deleted := os.IsNotExist(err); deleted != tt.wantDeleted {
+				t.Fatalf("note.md deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			tracked := mustRunGit(t, sm.gitService.repoPath, "ls-files", "note.md")
+			if (tracked == "") != tt.wantDeleted {
+				t.Errorf("note.md tracked = %v after the resolution", tracked != "")
+			}
+			if status := mustRunGit(t, sm.gitService.repoPath, "status", "--porcelain"); status != "" {
+				t.Errorf("working tree not clean:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestResolveConflictFileRejectsInvalidChoice(t *testing.T) {
+	sm, _ := newContentConflict(t)
+
+	if _, err := sm.ResolveConflictFile("note.md", HunkChoiceBoth); err == nil {
+		t.Fatal("resolving a file with both sides succeeded")
+	}
+	result, err := sm.ResolveConflictFile("note.md", HunkChoiceTheirs)
+	if err != nil || !result.SyncCompleted {
+		t.Fatalf("resolve = %+v, %v, want the sync completed", result, err)
+	}
+	if got := readTestFile(t, filepath.Join(sm.gitService.repoPath, "note.md")); got != "# Note\n\nremote edit\n\nlast\n" {
+		t.Errorf("note.md = %q, want the remote version", got)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// resolveWithConflictedCopies keeps the remote version of every conflicted file at its
+// path and saves the local version next to it as a conflicted copy, then commits the
+// merge. No text is lost and nothing is left for the user to resolve.
+func (sm *SyncManager) resolveWithConflictedCopies() error {
+	conflicts, err := sm.gitService.Conflicts()
+	if err != nil {
+		sm.updateStatus(SyncStatusError, "Failed to list conflicted files", err)
+		return fmt.Errorf("failed to list conflicted files: %w", err)
+	}
+	if len(conflicts) == 0 {
+		sm.updateStatus(SyncStatusSuccess, "No conflicts to resolve", nil)
+		return nil
+	}
+
+	host := conflictedCopyHost()
+	now := time.Now()
+	copies := make([]string, 0, len(conflicts))
+	touched := make([]string, 0, len(conflicts))
+
+	for _, conflict := range conflicts {
+		_, ours, theirs, err := sm.gitService.ConflictVersions(conflict)
+		if err != nil {
+			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to read versions of %s", conflict.Path), err)
+			return err
+		}
+
+		// Keep the remote version, or the local one when the remote side deleted or
+		// renamed the file
+		keep := theirs
+		if keep == nil {
+			keep = ours
+		}
+		if err := sm.gitService.MarkResolved(conflict.Path, keep); err != nil {
+			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to resolve %s", conflict.Path), err)
+			return err
+		}
+		touched = append(touched, conflict.Path)
+
+		if ours == nil || theirs == nil || bytes.Equal(ours, theirs) {
+			continue
+		}
+
+		copyPath := sm.conflictedCopyPath(conflict.Path, host, now)
+		if err := sm.gitService.MarkResolved(copyPath, ours); err != nil {
+			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to save conflicted copy of %s", conflict.Path), err)
+			return err
+		}
+		copies = append(copies, copyPath)
+		touched = append(touched, copyPath)
+	}
+	sm.observers.notify(absolutePaths(sm.gitService.repoPath, touched)...)
+
+	if err := sm.gitService.CommitMerge("Keep local versions of conflicted files as conflicted copies"); err != nil {
+		sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to commit conflicted copies: %v", err), err)
+		return fmt.Errorf("failed to commit conflicted copies: %w", err)
+	}
+
+	sm.mu.Lock()
+	// During a sync the copies are listed on the entry that ends it
+	if sm.activeRun != nil {
+		sm.activeRun.conflictCopies = append(sm.activeRun.conflictCopies, copies...)
+	}
+	record := sm.recordStatusLocked(SyncHistoryEntry{
+		Timestamp:      time.Now(),
+		Status:         SyncStatusSuccess,
+		Message:        fmt.Sprintf("Conflicts resolved, local versions saved as %d conflicted copies", len(copies)),
+		ConflictCopies: copies,
+	})
+	sm.mu.Unlock()
+
+	record.publish()
+	return nil
+}
+
+// conflictedCopyPath returns a free path for the local version of a conflicted file,
+// e.g. "Note (conflicted copy laptop 2024-05-01).md"
+func (sm *SyncManager) conflictedCopyPath(relPath string, host string, when time.Time) string {
+	label := fmt.Sprintf("conflicted copy %s %s", host, when.Format("2006-01-02"))
+	return freeLabeledPath(sm.gitService.repoPath, relPath, label)
+}
+
+// freeLabeledPath returns the first free "stem (label)ext", "stem (label 2)ext" and
+// so on next to a file
+func freeLabeledPath(root string, relPath string, label string) string {
+	ext := path.Ext(relPath)
+	stem := strings.TrimSuffix(relPath, ext)
+
+	candidate := fmt.Sprintf("%s (%s)%s", stem, label, ext)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(candidate))); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%s %d)%s", stem, label, n, ext)
+	}
+}
+
+// conflictedCopyHost returns the host name used to label conflicted copies
+func conflictedCopyHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown host"
+	}
+
+	// Keep the short name and drop characters that aren't allowed in file names
+	host, _, _ = strings.Cut(host, ".")
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '-'
+		}
+		return r
+	}, host)
+}
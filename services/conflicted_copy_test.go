@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncResolvesWithConflictedCopies(t *testing.T) {
+	copyName := func(n string) string {
+		return fmt.Sprintf("note (conflicted copy %s %s%s).md", conflictedCopyHost(), time.Now().Format("2006-01-02"), n)
+	}
+
+	tests := []struct {
+		name     string
+		pushed   map[string]string
+		wantCopy string
+	}{
+		{
+			name:     "first copy",
+			pushed:   map[string]string{"note.md": "# Note\n\nremote edit\n"},
+			wantCopy: copyName(""),
+		},
+		{
+			name:     "name taken by an earlier copy",
+			pushed:   map[string]string{"note.md": "# Note\n\nremote edit\n", copyName(""): "earlier copy\n"},
+			wantCopy: copyName(" 2"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitService, remoteDir := divergedClone(t, "# Note\n\nfirst\n", tt.pushed,
+				map[string]string{"note.md": "# Note\n\nlocal edit\n"})
+			sm := NewSyncManager(gitService)
+			sm.SetConflictStrategy(ConflictStrategyCopy)
+
+			if _, err := sm.TriggerManualSync(); err != nil {
+				t.Fatalf("sync: %v", err)
+			}
+
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, "note.md")); got != "# Note\n\nremote edit\n" {
+				t.Errorf("note.md = %q, want the remote version", got)
+			}
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, tt.wantCopy)); got != "# Note\n\nlocal edit\n" {
+				t.Errorf("%s = %q, want the local version", tt.wantCopy, got)
+			}
+			if got := mustRunGit(t, remoteDir, "show", "master:"+tt.wantCopy); got != "# Note\n\nlocal edit" {
+				t.Errorf("remote %s = %q, want the pushed copy", tt.wantCopy, got)
+			}
+			if gitService.MergeInProgress() {
+				t.Error("merge still in progress")
+			}
+
+			// The entry that ends the sync lists the copy
+			history := sm.GetSyncHistory()
+			if len(history) == 0 {
+				t.Fatal("no sync history")
+			}
+			last := history[len(history)-1]
+			if last.Status != SyncStatusSuccess || !reflect.DeepEqual(last.ConflictCopies, []string{tt.wantCopy}) {
+				t.Errorf("last history entry = %+v, want a success listing %q", last, tt.wantCopy)
+			}
+		})
+	}
+}
+
+func TestConflictedCopyHost(t *testing.T) {
+	host := conflictedCopyHost()
+	if host == "" {
+		t.Fatal("empty host name")
+	}
+	if strings.ContainsAny(host, `/\:*?"<>|.`) {
+		t.Fatalf("host name %q contains characters not allowed in file names", host)
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// credentialPassphraseEnv is the environment variable read to unlock the encrypted file backend
+const credentialPassphraseEnv = "GITNOTES_CREDENTIAL_PASSPHRASE"
+
+// scrypt parameters used to derive the file encryption key from the passphrase
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// encryptedCredentialFile is the on-disk format of the encrypted credential store
+type encryptedCredentialFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// EncryptedFileBackend stores credentials in an AES-GCM encrypted file whose key is
+// derived from a user passphrase. It is the fallback when no OS keyring is available.
+type EncryptedFileBackend struct {
+	path       string
+	passphrase string
+	mu         sync.Mutex
+}
+
+// NewEncryptedFileBackend creates a new EncryptedFileBackend for the given file.
+// The backend starts unlocked if the passphrase environment variable is set.
+func NewEncryptedFileBackend(path string) *EncryptedFileBackend {
+	return &EncryptedFileBackend{
+		path:       path,
+		passphrase: os.Getenv(credentialPassphraseEnv),
+	}
+}
+
+// defaultCredentialFilePath returns the location of the encrypted credential file
+func defaultCredentialFilePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), ".gitnotes", "credentials.enc")
+	}
+	return filepath.Join(homeDir, ".gitnotes", "credentials.enc")
+}
+
+// Name returns the backend identifier
+func (fb *EncryptedFileBackend) Name() string {
+	return "encrypted-file"
+}
+
+// Unlock sets the passphrase, verifying it against the existing file if there is one
+func (fb *EncryptedFileBackend) Unlock(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("passphrase is required")
+	}
+
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	previous := fb.passphrase
+	fb.passphrase = passphrase
+	if _, err := fb.load(); err != nil {
+		fb.passphrase = previous
+		return err
+	}
+
+	return nil
+}
+
+// IsLocked reports whether a passphrase still has to be provided
+func (fb *EncryptedFileBackend) IsLocked() bool {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	return fb.passphrase == ""
+}
+
+// Store saves the secret for the account
+func (fb *EncryptedFileBackend) Store(account, secret string) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	secrets, err := fb.load()
+	if err != nil {
+		return err
+	}
+
+	secrets[account] = secret
+	return fb.save(secrets)
+}
+
+// Get returns the secret for the account
+func (fb *EncryptedFileBackend) Get(account string) (string, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	secrets, err := fb.load()
+	if err != nil {
+		return "", err
+	}
+
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+
+	return secret, nil
+}
+
+// Delete removes the secret for the account
+func (fb *EncryptedFileBackend) Delete(account string) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	secrets, err := fb.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[account]; !ok {
+		return ErrCredentialNotFound
+	}
+
+	delete(secrets, account)
+	return fb.save(secrets)
+}
+
+// load decrypts the credential file, returning an empty map if it doesn't exist yet
+func (fb *EncryptedFileBackend) load() (map[string]string, error) {
+	if fb.passphrase == "" {
+		return nil, ErrCredentialStoreLocked
+	}
+
+	data, err := os.ReadFile(fb.path)
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading credential file: %w", err)
+	}
+
+	var file encryptedCredentialFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing credential file: %w", err)
+	}
+
+	gcm, err := fb.cipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, errors.New("incorrect passphrase or corrupted credential file")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("error parsing credentials: %w", err)
+	}
+
+	return secrets, nil
+}
+
+// save encrypts the secrets with a fresh salt and nonce and writes them to disk
+func (fb *EncryptedFileBackend) save(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("error marshaling credentials: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("error generating salt: %w", err)
+	}
+
+	gcm, err := fb.cipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	data, err := json.Marshal(encryptedCredentialFile{
+		Version: 1,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling credential file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fb.path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
+	if err := os.WriteFile(fb.path, data, 0600); err != nil {
+		return fmt.Errorf("error writing credential file: %w", err)
+	}
+
+	return nil
+}
+
+// cipher derives the AES-GCM cipher from the passphrase and salt
+func (fb *EncryptedFileBackend) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(fb.passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
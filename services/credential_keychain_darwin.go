@@ -0,0 +1,78 @@
+//go:build darwin
+
+package services
+
+import (
+	"fmt"
+
+	"github.com/keybase/go-keychain"
+)
+
+// keychainBackend stores credentials in the macOS Keychain
+type keychainBackend struct {
+	serviceName string
+}
+
+// platformCredentialBackend returns the macOS Keychain backend
+func platformCredentialBackend(serviceName string) CredentialBackend {
+	return &keychainBackend{serviceName: serviceName}
+}
+
+// Name returns the backend identifier
+func (kb *keychainBackend) Name() string {
+	return "keychain"
+}
+
+// Store saves the secret in the Keychain
+func (kb *keychainBackend) Store(account, secret string) error {
+	// Create a keychain item
+	item := keychain.NewItem()
+	item.SetSecClass(keychain.SecClassGenericPassword)
+	item.SetService(kb.serviceName)
+	item.SetAccount(account)
+	item.SetLabel(fmt.Sprintf("GitNotes: %s", account))
+	item.SetData([]byte(secret))
+	item.SetSynchronizable(keychain.SynchronizableNo)
+	item.SetAccessible(keychain.AccessibleWhenUnlocked)
+
+	// Delete any existing item before adding
+	_ = keychain.DeleteItem(item)
+
+	// Add the new item
+	return keychain.AddItem(item)
+}
+
+// Get retrieves the secret from the Keychain
+func (kb *keychainBackend) Get(account string) (string, error) {
+	// Create a query item
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService(kb.serviceName)
+	query.SetAccount(account)
+	query.SetMatchLimit(keychain.MatchLimitOne)
+	query.SetReturnData(true)
+
+	// Query the keychain
+	results, err := keychain.QueryItem(query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(results) == 0 {
+		return "", ErrCredentialNotFound
+	}
+
+	// Return the first result's data as a string
+	return string(results[0].Data), nil
+}
+
+// Delete removes the secret from the Keychain
+func (kb *keychainBackend) Delete(account string) error {
+	// Create a delete item
+	item := keychain.NewItem()
+	item.SetSecClass(keychain.SecClassGenericPassword)
+	item.SetService(kb.serviceName)
+	item.SetAccount(account)
+
+	return keychain.DeleteItem(item)
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux && !windows
+
+package services
+
+// platformCredentialBackend returns nil, so the encrypted file backend is used
+func platformCredentialBackend(serviceName string) CredentialBackend {
+	return nil
+}
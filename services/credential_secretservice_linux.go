@@ -0,0 +1,134 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/keybase/dbus"
+	"github.com/keybase/go-keychain/secretservice"
+)
+
+// secretServiceBackend stores credentials through the freedesktop Secret Service
+// D-Bus API (GNOME Keyring, KWallet and compatible daemons)
+type secretServiceBackend struct {
+	serviceName string
+	service     *secretservice.SecretService
+	mu          sync.Mutex // Prompt handling in the secretservice package is not thread-safe
+}
+
+// platformCredentialBackend returns the Secret Service backend, or nil when no
+// Secret Service daemon is reachable on the session bus
+func platformCredentialBackend(serviceName string) CredentialBackend {
+	service, err := secretservice.NewService()
+	if err != nil {
+		return nil
+	}
+	service.SetSessionOpenTimeout(2 * time.Second)
+
+	// Probe the daemon, a session bus without a keyring is common on headless machines
+	session, err := service.OpenSession(secretservice.AuthenticationDHAES)
+	if err != nil {
+		return nil
+	}
+	service.CloseSession(session)
+
+	return &secretServiceBackend{
+		serviceName: serviceName,
+		service:     service,
+	}
+}
+
+// Name returns the backend identifier
+func (sb *secretServiceBackend) Name() string {
+	return "secret-service"
+}
+
+// attributes returns the lookup attributes identifying an account's item
+func (sb *secretServiceBackend) attributes(account string) secretservice.Attributes {
+	return secretservice.Attributes{
+		"service": sb.serviceName,
+		"account": account,
+	}
+}
+
+// Store saves the secret in the default collection
+func (sb *secretServiceBackend) Store(account, secret string) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	session, err := sb.service.OpenSession(secretservice.AuthenticationDHAES)
+	if err != nil {
+		return err
+	}
+	defer sb.service.CloseSession(session)
+
+	// The collection may be locked until the user logs in to the keyring
+	if err := sb.service.Unlock([]dbus.ObjectPath{secretservice.DefaultCollection}); err != nil {
+		return fmt.Errorf("%w: %v", ErrCredentialStoreLocked, err)
+	}
+
+	value, err := session.NewSecret([]byte(secret))
+	if err != nil {
+		return err
+	}
+
+	properties := secretservice.NewSecretProperties(fmt.Sprintf("GitNotes: %s", account), sb.attributes(account))
+	_, err = sb.service.CreateItem(secretservice.DefaultCollection, properties, value, secretservice.ReplaceBehaviorReplace)
+	return err
+}
+
+// Get retrieves the secret from the default collection
+func (sb *secretServiceBackend) Get(account string) (string, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	items, err := sb.service.SearchCollection(secretservice.DefaultCollection, sb.attributes(account))
+	if err != nil {
+		return "", err
+	}
+	if len(items) == 0 {
+		return "", ErrCredentialNotFound
+	}
+
+	if err := sb.service.Unlock(items); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrCredentialStoreLocked, err)
+	}
+
+	session, err := sb.service.OpenSession(secretservice.AuthenticationDHAES)
+	if err != nil {
+		return "", err
+	}
+	defer sb.service.CloseSession(session)
+
+	secret, err := sb.service.GetSecret(items[0], *session)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// Delete removes every item stored for the account
+func (sb *secretServiceBackend) Delete(account string) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	items, err := sb.service.SearchCollection(secretservice.DefaultCollection, sb.attributes(account))
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return ErrCredentialNotFound
+	}
+
+	for _, item := range items {
+		if err := sb.service.DeleteItem(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,49 +3,82 @@ package services
 import (
 	"errors"
 	"fmt"
-	"runtime"
+	"sync"
+)
 
-	"github.com/keybase/go-keychain"
+// Errors returned by credential backends
+var (
+	ErrCredentialNotFound    = errors.New("credential not found")
+	ErrCredentialStoreLocked = errors.New("credential store is locked")
+)
+
+// CredentialBackend is a secure store for secrets keyed by account name
+type CredentialBackend interface {
+	// Name returns a short identifier for the backend (e.g. "keychain")
+	Name() string
+	// Store saves the secret for the account, replacing any existing value
+	Store(account, secret string) error
+	// Get returns the secret for the account or ErrCredentialNotFound
+	Get(account string) (string, error)
+	// Delete removes the secret for the account
+	Delete(account string) error
+}
+
+// defaultBackend is shared by every CredentialService so that probing the platform
+// keyring happens once and unlocking the encrypted file applies everywhere
+var (
+	defaultBackend     CredentialBackend
+	defaultBackendOnce sync.Once
 )
 
 // CredentialService handles secure storage and retrieval of credentials
 type CredentialService struct {
 	// Service name used for keychain entries
 	serviceName string
+	backend     CredentialBackend
 }
 
 // NewCredentialService creates a new CredentialService instance
+// The platform backend (macOS Keychain, Secret Service, Windows Credential Manager)
+// is used when available, otherwise credentials go to an encrypted file.
 func NewCredentialService() *CredentialService {
+	serviceName := "GitNotesApp"
+
+	defaultBackendOnce.Do(func() {
+		defaultBackend = platformCredentialBackend(serviceName)
+		if defaultBackend == nil {
+			defaultBackend = NewEncryptedFileBackend(defaultCredentialFilePath())
+		}
+	})
+
+	return &CredentialService{
+		serviceName: serviceName,
+		backend:     defaultBackend,
+	}
+}
+
+// NewCredentialServiceWithBackend creates a CredentialService using the given backend
+func NewCredentialServiceWithBackend(backend CredentialBackend) *CredentialService {
 	return &CredentialService{
 		serviceName: "GitNotesApp",
+		backend:     backend,
 	}
 }
 
+// BackendName returns the name of the backend in use
+func (cs *CredentialService) BackendName() string {
+	return cs.backend.Name()
+}
+
+// Backend returns the backend in use
+func (cs *CredentialService) Backend() CredentialBackend {
+	return cs.backend
+}
+
 // StoreCredential securely stores a credential for the given repository URL
 func (cs *CredentialService) StoreCredential(repoURL, token string) error {
-	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
-		// For non-macOS systems, we'd need different implementations
-		// For now, we'll focus on macOS since the user's platform is darwin
-		return errors.New("secure credential storage is currently only implemented for macOS")
-	}
-
-	// Create a keychain item
-	item := keychain.NewItem()
-	item.SetSecClass(keychain.SecClassGenericPassword)
-	item.SetService(cs.serviceName)
-	item.SetAccount(repoURL)
-	item.SetLabel(fmt.Sprintf("GitNotes: %s", repoURL))
-	item.SetData([]byte(token))
-	item.SetSynchronizable(keychain.SynchronizableNo)
-	item.SetAccessible(keychain.AccessibleWhenUnlocked)
-
-	// Delete any existing item before adding
-	_ = keychain.DeleteItem(item)
-
-	// Add the new item
-	err := keychain.AddItem(item)
-	if err != nil {
-		return fmt.Errorf("failed to store credential in keychain: %w", err)
+	if err := cs.backend.Store(repoURL, token); err != nil {
+		return fmt.Errorf("failed to store credential in %s: %w", cs.backend.Name(), err)
 	}
 
 	return nil
@@ -53,50 +86,21 @@ func (cs *CredentialService) StoreCredential(repoURL, token string) error {
 
 // GetCredential retrieves a credential for the given repository URL
 func (cs *CredentialService) GetCredential(repoURL string) (string, error) {
-	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
-		// For non-macOS systems, we'd need different implementations
-		return "", errors.New("secure credential retrieval is currently only implemented for macOS")
-	}
-
-	// Create a query item
-	query := keychain.NewItem()
-	query.SetSecClass(keychain.SecClassGenericPassword)
-	query.SetService(cs.serviceName)
-	query.SetAccount(repoURL)
-	query.SetMatchLimit(keychain.MatchLimitOne)
-	query.SetReturnData(true)
-
-	// Query the keychain
-	results, err := keychain.QueryItem(query)
+	token, err := cs.backend.Get(repoURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to query keychain: %w", err)
+		if errors.Is(err, ErrCredentialNotFound) {
+			return "", fmt.Errorf("no credentials found for %s: %w", repoURL, err)
+		}
+		return "", fmt.Errorf("failed to query %s: %w", cs.backend.Name(), err)
 	}
 
-	if len(results) == 0 {
-		return "", fmt.Errorf("no credentials found for %s", repoURL)
-	}
-
-	// Return the first result's data as a string
-	return string(results[0].Data), nil
+	return token, nil
 }
 
 // DeleteCredential removes a credential for the given repository URL
 func (cs *CredentialService) DeleteCredential(repoURL string) error {
-	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
-		// For non-macOS systems, we'd need different implementations
-		return errors.New("secure credential deletion is currently only implemented for macOS")
-	}
-
-	// Create a delete item
-	item := keychain.NewItem()
-	item.SetSecClass(keychain.SecClassGenericPassword)
-	item.SetService(cs.serviceName)
-	item.SetAccount(repoURL)
-
-	// Delete the item
-	err := keychain.DeleteItem(item)
-	if err != nil {
-		return fmt.Errorf("failed to delete credential from keychain: %w", err)
+	if err := cs.backend.Delete(repoURL); err != nil {
+		return fmt.Errorf("failed to delete credential from %s: %w", cs.backend.Name(), err)
 	}
 
 	return nil
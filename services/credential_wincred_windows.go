@@ -0,0 +1,142 @@
+//go:build windows
+
+package services
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modAdvapi32    = windows.NewLazySystemDLL("advapi32.dll")
+	procCredWriteW = modAdvapi32.NewProc("CredWriteW")
+	procCredReadW  = modAdvapi32.NewProc("CredReadW")
+	procCredDelete = modAdvapi32.NewProc("CredDeleteW")
+	procCredFree   = modAdvapi32.NewProc("CredFree")
+)
+
+// Credential Manager constants from wincred.h
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// winCredential mirrors the CREDENTIALW structure
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// winCredBackend stores credentials in the Windows Credential Manager
+type winCredBackend struct {
+	serviceName string
+}
+
+// platformCredentialBackend returns the Windows Credential Manager backend
+func platformCredentialBackend(serviceName string) CredentialBackend {
+	if err := modAdvapi32.Load(); err != nil {
+		return nil
+	}
+	return &winCredBackend{serviceName: serviceName}
+}
+
+// Name returns the backend identifier
+func (wb *winCredBackend) Name() string {
+	return "wincred"
+}
+
+// targetName returns the Credential Manager target for an account
+func (wb *winCredBackend) targetName(account string) string {
+	return wb.serviceName + ":" + account
+}
+
+// Store saves the secret as a generic credential
+func (wb *winCredBackend) Store(account, secret string) error {
+	target, err := windows.UTF16PtrFromString(wb.targetName(account))
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	ret, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// Get reads the secret of a generic credential
+func (wb *winCredBackend) Get(account string) (string, error) {
+	target, err := windows.UTF16PtrFromString(wb.targetName(account))
+	if err != nil {
+		return "", err
+	}
+
+	var cred *winCredential
+	ret, _, err := procCredReadW.Call(
+		uintptr(unsafe.Pointer(target)),
+		credTypeGeneric,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+	)
+	if ret == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return "", ErrCredentialNotFound
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), nil
+}
+
+// Delete removes the generic credential
+func (wb *winCredBackend) Delete(account string) error {
+	target, err := windows.UTF16PtrFromString(wb.targetName(account))
+	if err != nil {
+		return err
+	}
+
+	ret, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return ErrCredentialNotFound
+		}
+		return err
+	}
+
+	return nil
+}
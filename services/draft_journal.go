@@ -0,0 +1,361 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// draftFileExt is the extension of the files holding drafts
+const draftFileExt = ".draft.json"
+
+// ErrDraftNotFound is returned when a note has no draft
+var ErrDraftNotFound = errors.New("no draft for note")
+
+// Draft is the latest unsaved editor buffer of a note
+type Draft struct {
+	Path     string    `json:"path"`               // Slash-separated path relative to the vault root
+	Content  string    `json:"content"`            // Buffer content
+	BaseHash string    `json:"baseHash,omitempty"` // Hash of the file content the edit started from
+	SavedAt  time.Time `json:"savedAt"`
+}
+
+// DraftInfo describes a recoverable draft without its content
+type DraftInfo struct {
+	Path        string    `json:"path"`
+	SavedAt     time.Time `json:"savedAt"`
+	Size        int       `json:"size"`
+	FileExists  bool      `json:"fileExists"`
+	FileChanged bool      `json:"fileChanged"` // The note changed or was deleted since the edit started, e.g. by a pull
+}
+
+// DraftJournal keeps unsaved editor buffers outside the repository, so edits survive
+// a crash without ever being committed. A draft is dropped once the note is saved
+// with the same content.
+type DraftJournal struct {
+	root string
+	dir  string
+	mu   sync.Mutex
+}
+
+// NewDraftJournal creates a draft journal for the notes under root, storing drafts in dir
+func NewDraftJournal(root, dir string) *DraftJournal {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+	return &DraftJournal{root: absRoot, dir: dir}
+}
+
+// NewVaultDraftJournal creates the draft journal for a repository
+func NewVaultDraftJournal(repoPath string) (*DraftJournal, error) {
+	dataDir, err := repoDataDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(dataDir, "drafts")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating draft directory: %w", err)
+	}
+
+	return NewDraftJournal(repoPath, dir), nil
+}
+
+// Save replaces the draft of a note with a new snapshot of the buffer
+func (dj *DraftJournal) Save(relPath string, content string, baseHash string) error {
+	data, err := json.Marshal(Draft{
+		Path:     relPath,
+		Content:  content,
+		BaseHash: baseHash,
+		SavedAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling draft: %w", err)
+	}
+
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	if err := os.MkdirAll(dj.dir, 0700); err != nil {
+		return fmt.Errorf("error creating draft directory: %w", err)
+	}
+	if err := atomicWriteFile(dj.draftPath(relPath), data, 0600); err != nil {
+		return fmt.Errorf("error saving draft: %w", err)
+	}
+	return nil
+}
+
+// Get returns the draft of a note
+func (dj *DraftJournal) Get(relPath string) (Draft, error) {
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	return dj.readLocked(dj.draftPath(relPath))
+}
+
+// List returns the drafts that differ from their note, most recent first. Drafts
+// that match the note on disk have nothing to recover and are removed.
+func (dj *DraftJournal) List() ([]DraftInfo, error) {
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	entries, err := os.ReadDir(dj.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading drafts: %w", err)
+	}
+
+	infos := make([]DraftInfo, 0)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), draftFileExt) {
+			continue
+		}
+
+		draftPath := filepath.Join(dj.dir, entry.Name())
+		draft, err := dj.readLocked(draftPath)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring unreadable draft %s: %v\n", entry.Name(), err)
+			continue
+		}
+
+		info := DraftInfo{Path: draft.Path, SavedAt: draft.SavedAt, Size: len(draft.Content)}
+		current, err := os.ReadFile(dj.notePath(draft.Path))
+		if err == nil {
+			if string(current) == draft.Content {
+				os.Remove(draftPath)
+				continue
+			}
+			info.FileExists = true
+			info.FileChanged = draft.BaseHash != "" && contentHash(current) != draft.BaseHash
+		} else if os.IsNotExist(err) {
+			// A draft of an existing note means the note was deleted since
+			info.FileChanged = draft.BaseHash != ""
+		} else {
+			return nil, fmt.Errorf("error reading note: %w", err)
+		}
+
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].SavedAt.After(infos[j].SavedAt) })
+	return infos, nil
+}
+
+// Discard removes the draft of a note
+func (dj *DraftJournal) Discard(relPath string) error {
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	if err := os.Remove(dj.draftPath(relPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error discarding draft: %w", err)
+	}
+	return nil
+}
+
+// FilesChanged drops the drafts of notes that were saved with the draft's content
+func (dj *DraftJournal) FilesChanged(paths []string) {
+	dj.mu.Lock()
+	defer dj.mu.Unlock()
+
+	for _, changed := range paths {
+		relPath, ok := relativeVaultPath(dj.root, changed)
+		if !ok {
+			continue
+		}
+
+		draftPath := dj.draftPath(relPath)
+		draft, err := dj.readLocked(draftPath)
+		if err != nil {
+			continue
+		}
+		current, err := os.ReadFile(changed)
+		if err == nil && string(current) == draft.Content {
+			os.Remove(draftPath)
+		}
+	}
+}
+
+// readLocked reads a draft file. The caller must hold dj.mu.
+func (dj *DraftJournal) readLocked(draftPath string) (Draft, error) {
+	data, err := os.ReadFile(draftPath)
+	if os.IsNotExist(err) {
+		return Draft{}, ErrDraftNotFound
+	}
+	if err != nil {
+		return Draft{}, fmt.Errorf("error reading draft: %w", err)
+	}
+
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return Draft{}, fmt.Errorf("error decoding draft: %w", err)
+	}
+	return draft, nil
+}
+
+// draftPath returns the file holding the draft of a note, named by a hash of its path
+func (dj *DraftJournal) draftPath(relPath string) string {
+	sum := sha256.Sum256([]byte(relPath))
+	return filepath.Join(dj.dir, hex.EncodeToString(sum[:])[:16]+draftFileExt)
+}
+
+// notePath returns the absolute path of a note
+func (dj *DraftJournal) notePath(relPath string) string {
+	return filepath.Join(dj.root, filepath.FromSlash(relPath))
+}
+
+// drafts returns the draft journal of a vault
+func (v *vault) drafts() (*DraftJournal, error) {
+	if v.draftJournal == nil {
+		return nil, errors.New("drafts are unavailable")
+	}
+	return v.draftJournal, nil
+}
+
+// SaveDraft stores a snapshot of a note's unsaved editor buffer. baseHash is the hash
+// of the content the edit started from, as returned by GetFileContentWithHash.
+func (gns *GitNotesService) SaveDraft(vaultID string, filePath string, content string, baseHash string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return err
+	}
+	drafts, err := v.drafts()
+	if err != nil {
+		return err
+	}
+
+	return drafts.Save(relPath, content, baseHash)
+}
+
+// ListDrafts returns the drafts that can be recovered, as a JSON array of DraftInfo
+func (gns *GitNotesService) ListDrafts(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	drafts, err := v.drafts()
+	if err != nil {
+		return "", err
+	}
+
+	infos, err := drafts.List()
+	if err != nil {
+		return "", err
+	}
+
+	infosJSON, err := json.Marshal(infos)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling drafts: %w", err)
+	}
+
+	return string(infosJSON), nil
+}
+
+// GetDraftDiff compares a note's draft with the note on disk and returns a FileDiff
+// as JSON. The mode is DiffModeUnified or DiffModeWord.
+func (gns *GitNotesService) GetDraftDiff(vaultID string, filePath string, mode string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	drafts, err := v.drafts()
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		mode = DiffModeUnified
+	}
+	if mode != DiffModeUnified && mode != DiffModeWord {
+		return "", fmt.Errorf("unknown diff mode: %s", mode)
+	}
+
+	draft, err := drafts.Get(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	// A note deleted since the draft was saved diffs as empty
+	notePath, err := v.fileService.resolvePath(relPath)
+	if err != nil {
+		return "", err
+	}
+	current, err := os.ReadFile(notePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+
+	result := FileDiff{Path: relPath, To: "draft", Mode: mode}
+	if isBinaryContent(current) {
+		result.Binary = true
+	} else if mode == DiffModeWord {
+		result.Segments, result.Added, result.Removed = wordDiff(string(current), draft.Content)
+	} else {
+		result.Unified, result.Added, result.Removed = unifiedDiff("a/"+relPath, "b/"+relPath, string(current), draft.Content)
+	}
+
+	diffJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling diff: %w", err)
+	}
+
+	return string(diffJSON), nil
+}
+
+// RestoreDraft writes a note's draft to the note and removes the draft
+func (gns *GitNotesService) RestoreDraft(vaultID string, filePath string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return err
+	}
+	drafts, err := v.drafts()
+	if err != nil {
+		return err
+	}
+
+	draft, err := drafts.Get(relPath)
+	if err != nil {
+		return err
+	}
+
+	if err := v.fileService.WriteFileContent(drafts.notePath(relPath), draft.Content); err != nil {
+		return err
+	}
+	return drafts.Discard(relPath)
+}
+
+// DiscardDraft removes a note's draft without changing the note
+func (gns *GitNotesService) DiscardDraft(vaultID string, filePath string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return err
+	}
+	drafts, err := v.drafts()
+	if err != nil {
+		return err
+	}
+
+	return drafts.Discard(relPath)
+}
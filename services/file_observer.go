@@ -0,0 +1,51 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// FileObserver is notified when files in a vault change on disk
+type FileObserver interface {
+	// FilesChanged receives the absolute paths of files that were written, created or
+	// deleted. A deleted directory is reported by its own path.
+	FilesChanged(paths []string)
+}
+
+// fileObservers is a list of observers that is safe for concurrent use
+type fileObservers struct {
+	mu        sync.RWMutex
+	observers []FileObserver
+}
+
+// add registers an observer
+func (fo *fileObservers) add(observer FileObserver) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	fo.observers = append(fo.observers, observer)
+}
+
+// notify reports changed paths to every observer
+func (fo *fileObservers) notify(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	fo.mu.RLock()
+	observers := append([]FileObserver(nil), fo.observers...)
+	fo.mu.RUnlock()
+
+	for _, observer := range observers {
+		observer.FilesChanged(paths)
+	}
+}
+
+// absolutePaths joins repository-relative slash paths onto the repository root
+func absolutePaths(repoPath string, relPaths []string) []string {
+	paths := make([]string, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		paths = append(paths, filepath.Join(repoPath, filepath.FromSlash(relPath)))
+	}
+	return paths
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // FileNode represents a file or directory in the file system
@@ -16,9 +17,22 @@ type FileNode struct {
 	Children []FileNode `json:"children,omitempty"`
 }
 
+// FileContent is the content of a file with its hash, for conditional writes
+type FileContent struct {
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+}
+
 // FileService handles file system operations
 type FileService struct {
 	repoService *RepositoryService
+	observers   fileObservers
+	lock        *VaultLock // Coordinates writes with sync, nil when not shared
+	trash       *Trash     // Receives deleted files, nil deletes them permanently
+	writeMu     sync.Mutex // Makes checking and replacing a file one step
+	sandboxMu   sync.Mutex
+	sandbox     *PathSandbox // Built for the connected repository on first use
+	protected   []string     // Vault-relative paths denied besides .git and the trash
 }
 
 // NewFileService creates a new FileService instance
@@ -28,6 +42,31 @@ func NewFileService(repoService *RepositoryService) *FileService {
 	}
 }
 
+// AddFileObserver registers an observer notified after files are written or deleted
+func (fs *FileService) AddFileObserver(observer FileObserver) {
+	fs.observers.add(observer)
+}
+
+// SetVaultLock sets the lock writes take so they don't overlap with sync steps
+func (fs *FileService) SetVaultLock(lock *VaultLock) {
+	fs.lock = lock
+}
+
+// SetTrash sets the trash deleted files and directories are moved to
+func (fs *FileService) SetTrash(trash *Trash) {
+	fs.trash = trash
+}
+
+// SetProtectedPaths sets the vault-relative paths, with everything below them, that
+// file operations refuse to touch
+func (fs *FileService) SetProtectedPaths(paths []string) {
+	fs.sandboxMu.Lock()
+	defer fs.sandboxMu.Unlock()
+
+	fs.protected = append([]string(nil), paths...)
+	fs.sandbox = nil
+}
+
 // GetRepositoryStructure returns the directory structure of the repository
 func (fs *FileService) GetRepositoryStructure() (FileNode, error) {
 	if !fs.repoService.IsConnected() {
@@ -104,9 +143,9 @@ func (fs *FileService) buildFileTree(node *FileNode, path string, currentDepth,
 
 // GetFileContent reads and returns the content of a file
 func (fs *FileService) GetFileContent(filePath string) (string, error) {
-	// Validate path
-	if !fs.isPathSafe(filePath) {
-		return "", errors.New("invalid file path")
+	filePath, err := fs.resolvePath(filePath)
+	if err != nil {
+		return "", err
 	}
 
 	// Check if file exists and is not a directory
@@ -127,56 +166,147 @@ func (fs *FileService) GetFileContent(filePath string) (string, error) {
 	return string(content), nil
 }
 
-// WriteFileContent writes content to a file
+// GetFileContentWithHash reads a file and returns its content with the hash that
+// WriteFileContentIfMatch expects
+func (fs *FileService) GetFileContentWithHash(filePath string) (FileContent, error) {
+	content, err := fs.GetFileContent(filePath)
+	if err != nil {
+		return FileContent{}, err
+	}
+	return FileContent{Content: content, Hash: contentHash([]byte(content))}, nil
+}
+
+// WriteFileContent writes content to a file, replacing it atomically
 func (fs *FileService) WriteFileContent(filePath string, content string) error {
-	// Validate path
-	if !fs.isPathSafe(filePath) {
-		return errors.New("invalid file path")
+	_, err := fs.writeFile(filePath, content, nil)
+	return err
+}
+
+// WriteFileContentIfMatch writes content to a file only if the file still has the
+// content with expectedHash, as returned by GetFileContentWithHash. An empty
+// expectedHash expects the file not to exist. A file that changed, e.g. by a pull or
+// another window, fails with a *WriteConflictError holding the current content.
+// It returns the hash of the written content.
+func (fs *FileService) WriteFileContentIfMatch(filePath string, content string, expectedHash string) (string, error) {
+	return fs.writeFile(filePath, content, &expectedHash)
+}
+
+// writeFile atomically writes a file, first checking its hash when expectedHash is set
+func (fs *FileService) writeFile(filePath string, content string, expectedHash *string) (string, error) {
+	filePath, err := fs.resolveEntry(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	release, err := fs.lock.BeginWrite()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return fs.writeFileLocked(filePath, content, expectedHash)
+}
+
+// writeFileLocked writes a resolved path like writeFile. The caller holds a vault write.
+func (fs *FileService) writeFileLocked(filePath string, content string, expectedHash *string) (string, error) {
+	fs.writeMu.Lock()
+	defer fs.writeMu.Unlock()
+
+	if expectedHash != nil {
+		if err := checkFileHash(filePath, *expectedHash); err != nil {
+			return "", err
+		}
 	}
 
 	// Ensure the directory exists
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("error creating directory: %w", err)
+		return "", fmt.Errorf("error creating directory: %w", err)
 	}
 
 	// Write the file
-	err := os.WriteFile(filePath, []byte(content), 0644)
+	if err := atomicWriteFile(filePath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("error writing file: %w", err)
+	}
+
+	fs.observers.notify(filePath)
+	return contentHash([]byte(content)), nil
+}
+
+// checkFileHash returns a *WriteConflictError unless the file has the expected hash,
+// or doesn't exist when the hash is empty
+func checkFileHash(filePath string, expectedHash string) error {
+	current, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		if expectedHash == "" {
+			return nil
+		}
+		return &WriteConflictError{Path: filePath, ExpectedHash: expectedHash, Deleted: true}
+	}
 	if err != nil {
-		return fmt.Errorf("error writing file: %w", err)
+		return fmt.Errorf("error reading file: %w", err)
 	}
 
-	return nil
+	currentHash := contentHash(current)
+	if currentHash == expectedHash {
+		return nil
+	}
+	return &WriteConflictError{
+		Path:           filePath,
+		ExpectedHash:   expectedHash,
+		CurrentHash:    currentHash,
+		CurrentContent: string(current),
+	}
 }
 
-// isPathSafe checks if a file path is safe to access
-// This helps prevent directory traversal attacks
-func (fs *FileService) isPathSafe(filePath string) bool {
-	if !fs.repoService.IsConnected() {
-		return false
+// resolvePath checks an absolute or vault-relative path against the vault's sandbox
+// and returns the absolute path to use
+func (fs *FileService) resolvePath(filePath string) (string, error) {
+	sandbox, err := fs.pathSandbox()
+	if err != nil {
+		return "", err
 	}
+	return sandbox.Resolve(filePath)
+}
 
-	// Get the absolute path of the repository and the file
-	repoPath := fs.repoService.GetRepositoryPath()
-	absRepoPath, err := filepath.Abs(repoPath)
+// resolveEntry checks a path that an operation creates, changes, moves or deletes,
+// which can't be the vault root, and returns it as an absolute path
+func (fs *FileService) resolveEntry(filePath string) (string, error) {
+	sandbox, err := fs.pathSandbox()
 	if err != nil {
-		return false
+		return "", err
 	}
+	return sandbox.ResolveEntry(filePath)
+}
 
-	absFilePath, err := filepath.Abs(filePath)
+// pathSandbox returns the sandbox of the connected repository
+func (fs *FileService) pathSandbox() (*PathSandbox, error) {
+	if !fs.repoService.IsConnected() {
+		return nil, errors.New("not connected to a repository")
+	}
+	repoPath, err := filepath.Abs(fs.repoService.GetRepositoryPath())
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("error resolving vault path: %w", err)
 	}
 
-	// Check if the file path is within the repository directory
-	return strings.HasPrefix(absFilePath, absRepoPath)
+	fs.sandboxMu.Lock()
+	defer fs.sandboxMu.Unlock()
+
+	if fs.sandbox == nil || fs.sandbox.Root() != repoPath {
+		sandbox, err := NewPathSandbox(repoPath, fs.protected)
+		if err != nil {
+			return nil, err
+		}
+		fs.sandbox = sandbox
+	}
+	return fs.sandbox, nil
 }
 
 // GetChildrenOfPath gets the direct children of a directory
 func (fs *FileService) GetChildrenOfPath(dirPath string) ([]FileNode, error) {
-	// Validate path
-	if !fs.isPathSafe(dirPath) {
-		return nil, errors.New("invalid directory path")
+	dirPath, err := fs.resolvePath(dirPath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if path exists and is a directory
@@ -222,28 +352,39 @@ func (fs *FileService) IsMarkdownFile(filePath string) bool {
 
 // CreateFile creates a new file with the given content
 func (fs *FileService) CreateFile(filePath string, content string) error {
-	// Check if file already exists
-	_, err := os.Stat(filePath)
-	if err == nil {
+	// An empty hash expects no file, the path is validated before anything is checked
+	_, err := fs.writeFile(filePath, content, new(string))
+	if errors.Is(err, ErrWriteConflict) {
 		return errors.New("file already exists")
 	}
-	if !os.IsNotExist(err) {
-		return fmt.Errorf("error checking file: %w", err)
-	}
-
-	// Create the file
-	return fs.WriteFileContent(filePath, content)
+	return err
 }
 
-// DeleteFile deletes a file
+// DeleteFile moves a file or directory to the trash, or deletes the file permanently
+// when the service has no trash
 func (fs *FileService) DeleteFile(filePath string) error {
-	// Validate path
-	if !fs.isPathSafe(filePath) {
-		return errors.New("invalid file path")
+	filePath, err := fs.resolveEntry(filePath)
+	if err != nil {
+		return err
+	}
+
+	release, err := fs.lock.BeginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// With a trash, files and whole directories are moved there so they can be restored
+	if fs.trash != nil {
+		if _, err := fs.trash.Move(filePath); err != nil {
+			return err
+		}
+		fs.observers.notify(filePath)
+		return nil
 	}
 
 	// Check if file exists
-	_, err := os.Stat(filePath)
+	_, err = os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return errors.New("file does not exist")
@@ -257,18 +398,141 @@ func (fs *FileService) DeleteFile(filePath string) error {
 		return fmt.Errorf("error deleting file: %w", err)
 	}
 
+	fs.observers.notify(filePath)
+	return nil
+}
+
+// RestoreFromTrash moves a deleted file or directory back into the vault and
+// returns the path it was restored to
+func (fs *FileService) RestoreFromTrash(id string) (string, error) {
+	if fs.trash == nil {
+		return "", errors.New("trash is unavailable")
+	}
+
+	release, err := fs.lock.BeginWrite()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	restoredPath, err := fs.trash.Restore(id)
+	if err != nil {
+		return "", err
+	}
+
+	fs.observers.notify(restoredPath)
+	return restoredPath, nil
+}
+
+// RenameFile renames a file within its directory and returns its new path
+func (fs *FileService) RenameFile(filePath string, newName string) (string, error) {
+	if !isValidFileName(newName) {
+		return "", errors.New("invalid file name")
+	}
+
+	newPath := filepath.Join(filepath.Dir(filePath), newName)
+	if err := fs.MoveFile(filePath, newPath); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// isValidFileName checks that a name is a single path element
+func isValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// MoveFile moves a file to a new path, creating missing directories
+func (fs *FileService) MoveFile(oldPath string, newPath string) error {
+	return fs.move(oldPath, newPath, false)
+}
+
+// MoveDirectory moves a directory and everything in it to a new path
+func (fs *FileService) MoveDirectory(oldDir string, newDir string) error {
+	return fs.move(oldDir, newDir, true)
+}
+
+// move renames a file or directory on disk and notifies observers of both paths
+func (fs *FileService) move(oldPath, newPath string, isDir bool) error {
+	oldPath, err := fs.resolveEntry(oldPath)
+	if err != nil {
+		return err
+	}
+	newPath, err = fs.resolveEntry(newPath)
+	if err != nil {
+		return err
+	}
+
+	release, err := fs.lock.BeginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fs.moveLocked(oldPath, newPath, isDir)
+}
+
+// moveLocked moves resolved paths like move. The caller holds a vault write.
+func (fs *FileService) moveLocked(oldPath, newPath string, isDir bool) error {
+	// Check the source exists and is the expected kind
+	info, err := os.Stat(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("file does not exist")
+		}
+		return fmt.Errorf("error checking file: %w", err)
+	}
+	if info.IsDir() != isDir {
+		if isDir {
+			return errors.New("path is not a directory")
+		}
+		return errors.New("cannot move a directory as a file")
+	}
+
+	// The target must not exist, except for a case-only rename on a case-insensitive file system
+	if targetInfo, err := os.Stat(newPath); err == nil {
+		if !strings.EqualFold(oldPath, newPath) || !os.SameFile(info, targetInfo) {
+			return errors.New("target already exists")
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error checking target: %w", err)
+	}
+
+	if isDir {
+		rel, err := filepath.Rel(oldPath, newPath)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return errors.New("cannot move a directory into itself")
+		}
+	}
+
+	// Ensure the target directory exists
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("error moving file: %w", err)
+	}
+
+	fs.observers.notify(oldPath, newPath)
 	return nil
 }
 
 // CreateDirectory creates a new directory
 func (fs *FileService) CreateDirectory(dirPath string) error {
-	// Validate path
-	if !fs.isPathSafe(dirPath) {
-		return errors.New("invalid directory path")
+	dirPath, err := fs.resolveEntry(dirPath)
+	if err != nil {
+		return err
+	}
+
+	release, err := fs.lock.BeginWrite()
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	// Check if directory already exists
-	_, err := os.Stat(dirPath)
+	_, err = os.Stat(dirPath)
 	if err == nil {
 		return errors.New("directory already exists")
 	}
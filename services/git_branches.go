@@ -0,0 +1,278 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// DefaultRemoteName is the remote used when a branch has no upstream configured
+const DefaultRemoteName = "origin"
+
+// ErrDetachedHead is returned when HEAD doesn't point to a branch
+var ErrDetachedHead = errors.New("HEAD is not on a branch")
+
+// Upstream is the remote branch the local notes branch syncs with
+type Upstream struct {
+	Branch       string `json:"branch"`       // Local branch name
+	Remote       string `json:"remote"`       // Remote name, e.g. origin
+	RemoteBranch string `json:"remoteBranch"` // Branch name on the remote
+}
+
+// BranchInfo describes a branch on the remote
+type BranchInfo struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Current bool   `json:"current"` // The branch the notes branch tracks
+}
+
+// resolveUpstream returns the upstream of the checked out branch from the repository
+// config (branch.<name>.remote and branch.<name>.merge), defaulting to the
+// same-named branch on origin
+func resolveUpstream(repo *git.Repository) (Upstream, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return Upstream{}, fmt.Errorf("error reading HEAD: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return Upstream{}, ErrDetachedHead
+	}
+
+	upstream := Upstream{
+		Branch:       head.Name().Short(),
+		Remote:       DefaultRemoteName,
+		RemoteBranch: head.Name().Short(),
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return upstream, fmt.Errorf("error reading repository config: %w", err)
+	}
+
+	if branch, ok := cfg.Branches[upstream.Branch]; ok {
+		if branch.Remote != "" {
+			upstream.Remote = branch.Remote
+		}
+		if branch.Merge.IsBranch() {
+			upstream.RemoteBranch = branch.Merge.Short()
+		}
+	}
+
+	return upstream, nil
+}
+
+// pullOptions returns the pull options for the upstream
+func (u Upstream) pullOptions() *git.PullOptions {
+	return &git.PullOptions{
+		RemoteName:    u.Remote,
+		ReferenceName: plumbing.NewBranchReferenceName(u.RemoteBranch),
+	}
+}
+
+// trackingRef returns the remote-tracking reference of the remote branch, which a
+// pull's fetch updates
+func (u Upstream) trackingRef() plumbing.ReferenceName {
+	return plumbing.NewRemoteReferenceName(u.Remote, u.RemoteBranch)
+}
+
+// pushRefSpec maps the local branch onto the remote branch
+func (u Upstream) pushRefSpec() config.RefSpec {
+	return config.RefSpec(fmt.Sprintf("%s:%s",
+		plumbing.NewBranchReferenceName(u.Branch),
+		plumbing.NewBranchReferenceName(u.RemoteBranch)))
+}
+
+// Upstream returns the remote branch the checked out branch syncs with
+func (gs *GitService) Upstream() (Upstream, error) {
+	upstream, err := resolveUpstream(gs.repository)
+	if err != nil {
+		return upstream, gs.classifyError("resolve_upstream", err)
+	}
+	return upstream, nil
+}
+
+// SetUpstream sets the remote branch the checked out branch syncs with
+func (gs *GitService) SetUpstream(remoteName, remoteBranch string) error {
+	upstream, err := gs.Upstream()
+	if err != nil {
+		return err
+	}
+
+	if remoteName == "" {
+		remoteName = upstream.Remote
+	}
+	if remoteBranch == "" {
+		remoteBranch = upstream.RemoteBranch
+	}
+
+	if _, err := gs.repository.Remote(remoteName); err != nil {
+		return gs.classifyError("set_upstream", fmt.Errorf("%w: %s", ErrRemoteNotFound, remoteName))
+	}
+
+	return gs.setBranchUpstream(upstream.Branch, remoteName, remoteBranch)
+}
+
+// setBranchUpstream writes the tracking configuration of a local branch
+func (gs *GitService) setBranchUpstream(branch, remoteName, remoteBranch string) error {
+	cfg, err := gs.repository.Config()
+	if err != nil {
+		return gs.classifyError("set_upstream", err)
+	}
+
+	cfg.Branches[branch] = &config.Branch{
+		Name:   branch,
+		Remote: remoteName,
+		Merge:  plumbing.NewBranchReferenceName(remoteBranch),
+	}
+
+	if err := gs.repository.SetConfig(cfg); err != nil {
+		return gs.classifyError("set_upstream", err)
+	}
+
+	return nil
+}
+
+// ListRemoteBranches lists the branches on the upstream remote
+func (gs *GitService) ListRemoteBranches() ([]BranchInfo, error) {
+	upstream, err := gs.Upstream()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := gs.repository.Remote(upstream.Remote)
+	if err != nil {
+		return nil, gs.classifyError("list_branches", fmt.Errorf("%w: %s", ErrRemoteNotFound, upstream.Remote))
+	}
+
+	auth, err := gs.getAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, gs.classifyError("list_branches", err)
+	}
+
+	branches := make([]BranchInfo, 0, len(refs))
+	for _, ref := range refs {
+		if !ref.Name().IsBranch() {
+			continue
+		}
+		branches = append(branches, BranchInfo{
+			Name:    ref.Name().Short(),
+			Hash:    ref.Hash().String(),
+			Current: ref.Name().Short() == upstream.RemoteBranch,
+		})
+	}
+
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+
+	return branches, nil
+}
+
+// SwitchBranch checks out a branch, creating a local tracking branch when it only
+// exists on the remote. It refuses to switch with uncommitted changes.
+func (gs *GitService) SwitchBranch(branch string) error {
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if err := branchRef.Validate(); err != nil {
+		return fmt.Errorf("invalid branch name %q: %w", branch, err)
+	}
+
+	hasChanges, err := gs.HasLocalChanges()
+	if err != nil {
+		return err
+	}
+	if hasChanges {
+		return &GitError{
+			Op:      "switch_branch",
+			Err:     ErrLocalChanges,
+			Details: "Sync or discard your changes before switching branches",
+		}
+	}
+
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return gs.classifyError("switch_branch", err)
+	}
+
+	// Check out an existing local branch as is
+	if _, err := gs.repository.Reference(branchRef, true); err == nil {
+		if err := w.Checkout(&git.CheckoutOptions{Branch: branchRef}); err != nil {
+			return gs.classifyError("switch_branch", err)
+		}
+		return nil
+	}
+
+	// Otherwise fetch the branch from the current upstream remote and track it
+	upstream, err := gs.Upstream()
+	if err != nil && !errors.Is(err, ErrDetachedHead) {
+		return err
+	}
+	if upstream.Remote == "" {
+		upstream.Remote = DefaultRemoteName
+	}
+
+	auth, err := gs.getAuth()
+	if err != nil {
+		return err
+	}
+
+	remoteRef := plumbing.NewRemoteReferenceName(upstream.Remote, branch)
+	err = gs.repository.Fetch(&git.FetchOptions{
+		RemoteName: upstream.Remote,
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, remoteRef))},
+		Progress:   newProgressWriter("pull", gs.emitter),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return gs.classifyError("switch_branch", err)
+	}
+
+	ref, err := gs.repository.Reference(remoteRef, true)
+	if err != nil {
+		return gs.classifyError("switch_branch", fmt.Errorf("branch %s not found on %s: %w", branch, upstream.Remote, err))
+	}
+
+	if err := w.Checkout(&git.CheckoutOptions{Branch: branchRef, Hash: ref.Hash(), Create: true}); err != nil {
+		return gs.classifyError("switch_branch", err)
+	}
+
+	return gs.setBranchUpstream(branch, upstream.Remote, branch)
+}
+
+// CreateBranch creates a branch from the current commit, checks it out and sets it to
+// track a same-named branch on the upstream remote, which is created by the next push
+func (gs *GitService) CreateBranch(branch string) error {
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if err := branchRef.Validate(); err != nil {
+		return fmt.Errorf("invalid branch name %q: %w", branch, err)
+	}
+
+	if _, err := gs.repository.Reference(branchRef, true); err == nil {
+		return fmt.Errorf("branch %s already exists", branch)
+	}
+
+	upstream, err := gs.Upstream()
+	if err != nil {
+		return err
+	}
+
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return gs.classifyError("create_branch", err)
+	}
+
+	// Keep uncommitted changes, they carry over to the new branch
+	if err := w.Checkout(&git.CheckoutOptions{Branch: branchRef, Create: true, Keep: true}); err != nil {
+		return gs.classifyError("create_branch", err)
+	}
+
+	return gs.setBranchUpstream(branch, upstream.Remote, branch)
+}
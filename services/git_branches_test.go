@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestSyncCreatesRemoteBranch(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gitService := cloneTestRemote(t, remoteDir)
+
+	if err := gitService.CreateBranch("drafts"); err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	writeTestFile(t, filepath.Join(gitService.repoPath, "draft.md"), "# Draft\n")
+
+	if _, err := NewSyncManager(gitService).TriggerManualSync(); err != nil {
+		t.Fatalf("sync new branch: %v", err)
+	}
+
+	remote, err := git.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := remote.Reference(plumbing.NewBranchReferenceName("drafts"), true)
+	if err != nil {
+		t.Fatalf("remote branch: %v", err)
+	}
+	head, err := gitService.HeadCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash() != head {
+		t.Errorf("remote branch at %s, want %s", ref.Hash(), head)
+	}
+}
+
+func TestPullMissingUpstreamBranch(t *testing.T) {
+	requireGit(t)
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gitService := cloneTestRemote(t, remoteDir)
+	if err := gitService.CreateBranch("drafts"); err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+
+	// Never pushed, so there is nothing to pull yet
+	if err := gitService.PullChanges(); err != nil {
+		t.Fatalf("pull of a new branch: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(gitService.repoPath, "draft.md"), "# Draft\n")
+	if _, err := NewSyncManager(gitService).TriggerManualSync(); err != nil {
+		t.Fatalf("sync new branch: %v", err)
+	}
+
+	// Deleted on the remote after it was pushed
+	mustRunGit(t, remoteDir, "branch", "-D", "drafts")
+	if err := gitService.PullChanges(); !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		t.Fatalf("pull of a deleted upstream branch = %v, want ErrReferenceNotFound", err)
+	}
+}
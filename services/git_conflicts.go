@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Conflict types, from the unmerged index stages of a file
+const (
+	ConflictTypeContent      = "content"       // Both sides changed the file
+	ConflictTypeModifyDelete = "modify/delete" // One side changed the file, the other deleted it
+	ConflictTypeAddAdd       = "add/add"       // Both sides added different files at the path
+	ConflictTypeRename       = "rename"        // The file was renamed on one or both sides
+)
+
+// Sides of a merge
+const (
+	ConflictSideOurs   = "ours"
+	ConflictSideTheirs = "theirs"
+)
+
+// ConflictInfo describes an unmerged file in the index
+type ConflictInfo struct {
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	BaseID    string `json:"baseId,omitempty"`    // Blob of the common ancestor (stage 1)
+	OursID    string `json:"oursId,omitempty"`    // Blob of the local version (stage 2)
+	TheirsID  string `json:"theirsId,omitempty"`  // Blob of the remote version (stage 3)
+	DeletedBy string `json:"deletedBy,omitempty"` // Side that deleted the file, for modify/delete
+	Binary    bool   `json:"binary"`
+}
+
+// Conflicts returns the files with unmerged stages in the index, sorted by path.
+// Unlike scanning for conflict markers this finds binary conflicts and ignores notes
+// that merely contain marker-like lines.
+func (gs *GitService) Conflicts() ([]ConflictInfo, error) {
+	idx, err := gs.repository.Storer.Index()
+	if err != nil {
+		return nil, gs.classifyError("detect_conflicts", err)
+	}
+
+	stages := make(map[string]map[index.Stage]plumbing.Hash)
+	for _, entry := range idx.Entries {
+		if entry.Stage == 0 {
+			continue
+		}
+		if stages[entry.Name] == nil {
+			stages[entry.Name] = make(map[index.Stage]plumbing.Hash)
+		}
+		stages[entry.Name][entry.Stage] = entry.Hash
+	}
+
+	conflicts := make([]ConflictInfo, 0, len(stages))
+	for filePath, fileStages := range stages {
+		base, hasBase := fileStages[index.AncestorMode]
+		ours, hasOurs := fileStages[index.OurMode]
+		theirs, hasTheirs := fileStages[index.TheirMode]
+
+		conflict := ConflictInfo{Path: filePath}
+		if hasBase {
+			conflict.BaseID = base.String()
+		}
+		if hasOurs {
+			conflict.OursID = ours.String()
+		}
+		if hasTheirs {
+			conflict.TheirsID = theirs.String()
+		}
+
+		switch {
+		case hasBase && hasOurs && hasTheirs:
+			conflict.Type = ConflictTypeContent
+		case hasBase && hasOurs:
+			conflict.Type = ConflictTypeModifyDelete
+			conflict.DeletedBy = ConflictSideTheirs
+		case hasBase && hasTheirs:
+			conflict.Type = ConflictTypeModifyDelete
+			conflict.DeletedBy = ConflictSideOurs
+		case hasOurs && hasTheirs:
+			conflict.Type = ConflictTypeAddAdd
+		default:
+			// A lone stage is left by rename/rename and rename/delete conflicts,
+			// where each side's version is recorded under its own path
+			conflict.Type = ConflictTypeRename
+		}
+
+		conflict.Binary = gs.isBinaryConflict(filePath, base, ours, theirs)
+		conflicts = append(conflicts, conflict)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return conflicts, nil
+}
+
+// isBinaryConflict reports whether any version of a conflicted file is binary
+func (gs *GitService) isBinaryConflict(filePath string, hashes ...plumbing.Hash) bool {
+	read := false
+	for _, hash := range hashes {
+		if hash.IsZero() {
+			continue
+		}
+		blob, err := gs.repository.BlobObject(hash)
+		if err != nil {
+			continue
+		}
+		reader, err := blob.Reader()
+		if err != nil {
+			continue
+		}
+		head := make([]byte, 8000)
+		n, _ := io.ReadFull(reader, head)
+		reader.Close()
+		if isBinaryContent(head[:n]) {
+			return true
+		}
+		read = true
+	}
+	if read {
+		return false
+	}
+
+	// Fall back to the working copy when no blob could be read
+	content, err := os.ReadFile(filepath.Join(gs.repoPath, filePath))
+	return err == nil && isBinaryContent(content)
+}
+
+// ConflictVersions returns the base, ours and theirs content of a conflicted file.
+// A side without a stage, e.g. the deleting side of a modify/delete conflict, is nil.
+func (gs *GitService) ConflictVersions(conflict ConflictInfo) ([]byte, []byte, []byte, error) {
+	versions := make([][]byte, 3)
+	for i, id := range []string{conflict.BaseID, conflict.OursID, conflict.TheirsID} {
+		if id == "" {
+			continue
+		}
+
+		blob, err := gs.repository.BlobObject(plumbing.NewHash(id))
+		if err != nil {
+			return nil, nil, nil, gs.classifyError("conflict_versions", err)
+		}
+		reader, err := blob.Reader()
+		if err != nil {
+			return nil, nil, nil, gs.classifyError("conflict_versions", err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, nil, nil, gs.classifyError("conflict_versions", err)
+		}
+		versions[i] = content
+	}
+
+	return versions[0], versions[1], versions[2], nil
+}
+
+// MarkResolved writes the resolved content of a conflicted file, or deletes the file
+// when content is nil, and replaces its unmerged stages in the index with the result
+func (gs *GitService) MarkResolved(relPath string, content []byte) error {
+	fullPath := filepath.Join(gs.repoPath, filepath.FromSlash(relPath))
+	if content == nil {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return gs.classifyError("mark_resolved", err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return gs.classifyError("mark_resolved", err)
+		}
+		if err := atomicWriteFile(fullPath, content, 0644); err != nil {
+			return gs.classifyError("mark_resolved", err)
+		}
+	}
+
+	if err := gs.gitExecutor().Add(context.Background(), []string{relPath}); err != nil {
+		return gs.executorError("mark_resolved", err)
+	}
+
+	return nil
+}
+
+// CommitMerge commits the staged merge result with the merged commit from MERGE_HEAD
+// as second parent and clears the merge state. Without a merge in progress it makes
+// a regular commit.
+func (gs *GitService) CommitMerge(message string) error {
+	gitDir := filepath.Join(gs.repoPath, ".git")
+	mergeHead, err := os.ReadFile(filepath.Join(gitDir, "MERGE_HEAD"))
+	if os.IsNotExist(err) {
+		return gs.CommitChanges(message)
+	}
+	if err != nil {
+		return gs.classifyError("commit_merge", err)
+	}
+
+	head, err := gs.repository.Head()
+	if err != nil {
+		return gs.classifyError("commit_merge", err)
+	}
+
+	parents := []plumbing.Hash{head.Hash()}
+	for _, line := range strings.Fields(string(mergeHead)) {
+		parents = append(parents, plumbing.NewHash(line))
+	}
+
+	// Prefer the message git prepared for the merge, without the conflict list git
+	// appends as comments
+	if message == "" {
+		if mergeMsg, err := os.ReadFile(filepath.Join(gitDir, "MERGE_MSG")); err == nil {
+			lines := make([]string, 0)
+			for _, line := range strings.Split(string(mergeMsg), "\n") {
+				if !strings.HasPrefix(line, "#") {
+					lines = append(lines, line)
+				}
+			}
+			message = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+	}
+	if message == "" {
+		message = "Merge remote changes"
+	}
+
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return gs.classifyError("commit_merge", err)
+	}
+
+	identity := gs.CommitIdentity()
+	_, err = w.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  identity.Name,
+			Email: identity.Email,
+			When:  time.Now(),
+		},
+		Parents: parents,
+		Signer:  gs.commitSigner(),
+	})
+	if err != nil {
+		return gs.classifyError("commit_merge", err)
+	}
+
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "AUTO_MERGE"} {
+		if err := os.Remove(filepath.Join(gitDir, name)); err != nil && !os.IsNotExist(err) {
+			return gs.classifyError("commit_merge", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,732 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// defaultGitTimeout bounds a single executor operation when no timeout is configured
+const defaultGitTimeout = 2 * time.Minute
+
+// Executors that can be chosen in the settings
+const (
+	ExecutorGoGit = "go-git" // In process, the default
+	ExecutorCLI   = "cli"    // The git binary on PATH
+)
+
+// GitExecutor runs the merge of a diverged pull and the index and working tree
+// operations used to resolve conflicts. Fetch, push and commits always use go-git,
+// which handles authentication and commit signing. Operations stop when the context
+// is cancelled or the executor's timeout expires, and fail with a *GitError.
+type GitExecutor interface {
+	// UnmergedFiles returns the paths with unmerged stages in the index, sorted
+	UnmergedFiles(ctx context.Context) ([]string, error)
+	// CheckoutSide writes the ConflictSideOurs or ConflictSideTheirs version of conflicted
+	// files to the working tree, deleting the files that side doesn't have
+	CheckoutSide(ctx context.Context, side string, paths []string) error
+	// Add stages the working tree version of files, or their deletion, replacing any
+	// unmerged stages
+	Add(ctx context.Context, paths []string) error
+	// Merge merges the commit ref points to into HEAD without committing and leaves
+	// MERGE_HEAD and MERGE_MSG for CommitMerge. Conflicted files get conflict markers
+	// and unmerged stages. It returns the conflicted paths, sorted.
+	Merge(ctx context.Context, ref plumbing.ReferenceName) ([]string, error)
+}
+
+// NewGitExecutor creates the executor with the name, ExecutorGoGit or ExecutorCLI. An
+// empty name selects go-git.
+func NewGitExecutor(name string, repoPath string, repository *git.Repository) (GitExecutor, error) {
+	switch name {
+	case "", ExecutorGoGit:
+		return NewGoGitExecutor(repoPath, repository, 0), nil
+	case ExecutorCLI:
+		return NewCLIGitExecutor(repoPath, 0)
+	default:
+		return nil, fmt.Errorf("unknown git executor: %s", name)
+	}
+}
+
+// GoGitExecutor runs git operations in process with go-git, no git binary is needed
+type GoGitExecutor struct {
+	repoPath   string
+	repository *git.Repository
+	timeout    time.Duration
+}
+
+// NewGoGitExecutor creates a go-git executor. A zero timeout uses the default.
+func NewGoGitExecutor(repoPath string, repository *git.Repository, timeout time.Duration) *GoGitExecutor {
+	if timeout <= 0 {
+		timeout = defaultGitTimeout
+	}
+	return &GoGitExecutor{repoPath: repoPath, repository: repository, timeout: timeout}
+}
+
+// UnmergedFiles returns the paths with unmerged stages in the index
+func (e *GoGitExecutor) UnmergedFiles(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	idx, err := e.repository.Storer.Index()
+	if err != nil {
+		return nil, classifyGitError("unmerged_files", AuthModeNone, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &GitError{Op: "unmerged_files", Err: err}
+	}
+
+	seen := make(map[string]bool)
+	paths := make([]string, 0)
+	for _, entry := range idx.Entries {
+		if entry.Stage != 0 && !seen[entry.Name] {
+			seen[entry.Name] = true
+			paths = append(paths, entry.Name)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// CheckoutSide writes one side's version of conflicted files from the index stages
+func (e *GoGitExecutor) CheckoutSide(ctx context.Context, side string, paths []string) error {
+	stage, err := conflictSideStage(side)
+	if err != nil {
+		return &GitError{Op: "checkout_side", Err: err}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	idx, err := e.repository.Storer.Index()
+	if err != nil {
+		return classifyGitError("checkout_side", AuthModeNone, err)
+	}
+
+	for _, relPath := range paths {
+		if err := ctx.Err(); err != nil {
+			return &GitError{Op: "checkout_side", Err: err, Details: relPath}
+		}
+
+		fullPath := filepath.Join(e.repoPath, filepath.FromSlash(relPath))
+		var entry *index.Entry
+		for _, candidate := range idx.Entries {
+			if candidate.Name == relPath && candidate.Stage == stage {
+				entry = candidate
+				break
+			}
+		}
+		if entry == nil {
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				return &GitError{Op: "checkout_side", Err: err, Details: relPath}
+			}
+			continue
+		}
+
+		if err := e.writeBlob(entry, fullPath); err != nil {
+			return &GitError{Op: "checkout_side", Err: err, Details: relPath}
+		}
+	}
+
+	return nil
+}
+
+// writeBlob writes the content of an index entry to a file
+func (e *GoGitExecutor) writeBlob(entry *index.Entry, fullPath string) error {
+	content, err := e.readBlob(entry.Hash)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, content, 0644)
+}
+
+// readBlob returns the content of a blob
+func (e *GoGitExecutor) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := e.repository.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Add stages files, dropping their unmerged stages first
+func (e *GoGitExecutor) Add(ctx context.Context, paths []string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	staged := make(map[string]bool, len(paths))
+	for _, relPath := range paths {
+		staged[relPath] = true
+	}
+
+	// Drop every entry of the paths, adding the files puts the current ones back
+	idx, err := e.repository.Storer.Index()
+	if err != nil {
+		return classifyGitError("add", AuthModeNone, err)
+	}
+	entries := idx.Entries[:0]
+	for _, entry := range idx.Entries {
+		if !staged[entry.Name] {
+			entries = append(entries, entry)
+		}
+	}
+	idx.Entries = entries
+	if err := e.repository.Storer.SetIndex(idx); err != nil {
+		return classifyGitError("add", AuthModeNone, err)
+	}
+
+	w, err := e.repository.Worktree()
+	if err != nil {
+		return classifyGitError("add", AuthModeNone, err)
+	}
+	for _, relPath := range paths {
+		if err := ctx.Err(); err != nil {
+			return &GitError{Op: "add", Err: err, Details: relPath}
+		}
+
+		// A deleted file stays out of the index
+		if _, err := os.Lstat(filepath.Join(e.repoPath, filepath.FromSlash(relPath))); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := w.Add(relPath); err != nil {
+			return &GitError{Op: "add", Err: err, Details: relPath}
+		}
+	}
+
+	return nil
+}
+
+// treeEntry is a file's blob and mode in a commit
+type treeEntry struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+// mergeAction is the outcome of merging one path: content for the working tree, nil
+// to delete the file, and the index entries that replace the path's entries
+type mergeAction struct {
+	path     string
+	write    bool // The working tree file changes
+	content  []byte
+	mode     filemode.FileMode
+	entries  []*index.Entry
+	conflict bool
+}
+
+// Merge merges in process, like git's recursive merge of a single merge base:
+// a path changed on one side takes that side, a text file both sides changed is
+// merged line by line, and everything else conflicts. It refuses to run with
+// uncommitted changes or when an untracked file would be overwritten.
+func (e *GoGitExecutor) Merge(ctx context.Context, ref plumbing.ReferenceName) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	theirsRef, err := e.repository.Reference(ref, true)
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	head, err := e.repository.Head()
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	oursCommit, err := e.repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	theirsCommit, err := e.repository.CommitObject(theirsRef.Hash())
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+
+	// Unrelated histories merge against an empty base
+	base := make(map[string]treeEntry)
+	bases, err := oursCommit.MergeBase(theirsCommit)
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	if len(bases) > 0 {
+		if base, err = commitEntries(bases[0]); err != nil {
+			return nil, classifyGitError("merge", AuthModeNone, err)
+		}
+	}
+	ours, err := commitEntries(oursCommit)
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	theirs, err := commitEntries(theirsCommit)
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+
+	w, err := e.repository.Worktree()
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, classifyGitError("merge", AuthModeNone, err)
+	}
+	for relPath, fileStatus := range status {
+		if fileStatus.Worktree != git.Untracked && (fileStatus.Staging != git.Unmodified || fileStatus.Worktree != git.Unmodified) {
+			return nil, &GitError{Op: "merge", Err: ErrLocalChanges, Details: relPath}
+		}
+	}
+
+	// Plan every path before touching anything, so a refused merge changes nothing
+	paths := make([]string, 0, len(ours)+len(theirs))
+	for _, entries := range []map[string]treeEntry{base, ours, theirs} {
+		for relPath := range entries {
+			paths = append(paths, relPath)
+		}
+	}
+	sort.Strings(paths)
+
+	actions := make([]mergeAction, 0)
+	for i, relPath := range paths {
+		if i > 0 && paths[i-1] == relPath {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, &GitError{Op: "merge", Err: err}
+		}
+
+		action, changed, err := e.mergePath(relPath, ref.Short(), base, ours, theirs)
+		if err != nil {
+			return nil, &GitError{Op: "merge", Err: err, Details: relPath}
+		}
+		if !changed {
+			continue
+		}
+
+		// Git refuses to overwrite untracked files
+		if _, tracked := ours[relPath]; action.write && !tracked {
+			if _, err := os.Lstat(filepath.Join(e.repoPath, filepath.FromSlash(relPath))); err == nil {
+				return nil, &GitError{Op: "merge", Err: ErrLocalChanges, Details: "untracked file would be overwritten: " + relPath}
+			}
+		}
+		actions = append(actions, action)
+	}
+
+	if err := e.applyMerge(actions); err != nil {
+		return nil, &GitError{Op: "merge", Err: err}
+	}
+
+	gitDir := filepath.Join(e.repoPath, ".git")
+	if err := os.WriteFile(filepath.Join(gitDir, "MERGE_HEAD"), []byte(theirsRef.Hash().String()+"\n"), 0644); err != nil {
+		return nil, &GitError{Op: "merge", Err: err}
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "MERGE_MSG"), []byte(mergeMessage(ref)+"\n"), 0644); err != nil {
+		return nil, &GitError{Op: "merge", Err: err}
+	}
+
+	conflicts := make([]string, 0)
+	for _, action := range actions {
+		if action.conflict {
+			conflicts = append(conflicts, action.path)
+		}
+	}
+	return conflicts, nil
+}
+
+// mergePath decides the merge result of one path, labelling their side of conflict
+// markers with theirsLabel. It reports false when the path keeps our version.
+func (e *GoGitExecutor) mergePath(relPath, theirsLabel string, base, ours, theirs map[string]treeEntry) (mergeAction, bool, error) {
+	baseEntry, hasBase := base[relPath]
+	oursEntry, hasOurs := ours[relPath]
+	theirsEntry, hasTheirs := theirs[relPath]
+	action := mergeAction{path: relPath}
+
+	switch {
+	case hasOurs == hasTheirs && oursEntry == theirsEntry, hasBase == hasTheirs && baseEntry == theirsEntry:
+		// Both sides agree or only ours changed
+		return action, false, nil
+
+	case hasBase == hasOurs && baseEntry == oursEntry:
+		// Only theirs changed
+		action.write = true
+		if hasTheirs {
+			content, err := e.readBlob(theirsEntry.hash)
+			if err != nil {
+				return action, false, err
+			}
+			action.content = content
+			action.mode = theirsEntry.mode
+			action.entries = []*index.Entry{{Name: relPath, Hash: theirsEntry.hash, Mode: theirsEntry.mode}}
+		}
+		return action, true, nil
+	}
+
+	// Both sides changed the path. Text files both have are merged line by line.
+	var baseContent, oursContent, theirsContent []byte
+	var err error
+	if hasBase {
+		if baseContent, err = e.readBlob(baseEntry.hash); err != nil {
+			return action, false, err
+		}
+	}
+	if hasOurs {
+		if oursContent, err = e.readBlob(oursEntry.hash); err != nil {
+			return action, false, err
+		}
+	}
+	if hasTheirs {
+		if theirsContent, err = e.readBlob(theirsEntry.hash); err != nil {
+			return action, false, err
+		}
+	}
+
+	action.conflict = true
+	action.mode = oursEntry.mode
+	mergeable := hasOurs && hasTheirs && oursEntry.mode.IsFile() && oursEntry.mode != filemode.Symlink &&
+		theirsEntry.mode.IsFile() && theirsEntry.mode != filemode.Symlink &&
+		!isBinaryContent(baseContent) && !isBinaryContent(oursContent) && !isBinaryContent(theirsContent)
+	if mergeable {
+		// A mode only one side changed is kept
+		if hasBase && oursEntry.mode == baseEntry.mode {
+			action.mode = theirsEntry.mode
+		}
+
+		var merged bytes.Buffer
+		clean := true
+		for _, chunk := range merge3(splitLines(string(baseContent)), splitLines(string(oursContent)), splitLines(string(theirsContent))) {
+			if !chunk.Conflict {
+				merged.WriteString(chunk.Text)
+				continue
+			}
+			clean = false
+			writeConflictMarkers(&merged, chunk.Ours, chunk.Theirs, theirsLabel)
+		}
+
+		action.write = true
+		action.content = merged.Bytes()
+		if clean {
+			hash, err := e.storeBlob(action.content)
+			if err != nil {
+				return action, false, err
+			}
+			action.conflict = false
+			action.entries = []*index.Entry{{Name: relPath, Hash: hash, Mode: action.mode}}
+			return action, true, nil
+		}
+	} else if !hasOurs {
+		// Keep the version of the side that modified a file the other deleted
+		action.write = true
+		action.content = theirsContent
+		action.mode = theirsEntry.mode
+	}
+
+	// Record the unmerged stages like git does
+	for _, stage := range []struct {
+		present bool
+		entry   treeEntry
+		stage   index.Stage
+	}{
+		{hasBase, baseEntry, index.AncestorMode},
+		{hasOurs, oursEntry, index.OurMode},
+		{hasTheirs, theirsEntry, index.TheirMode},
+	} {
+		if stage.present {
+			action.entries = append(action.entries, &index.Entry{Name: relPath, Hash: stage.entry.hash, Mode: stage.entry.mode, Stage: stage.stage})
+		}
+	}
+	return action, true, nil
+}
+
+// applyMerge writes the planned files and replaces the index entries of their paths
+func (e *GoGitExecutor) applyMerge(actions []mergeAction) error {
+	idx, err := e.repository.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		replaced[action.path] = true
+	}
+	entries := make([]*index.Entry, 0, len(idx.Entries)+len(actions))
+	for _, entry := range idx.Entries {
+		if !replaced[entry.Name] {
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, action := range actions {
+		fullPath := filepath.Join(e.repoPath, filepath.FromSlash(action.path))
+		if action.write {
+			if err := writeMergedFile(fullPath, action.content, action.mode); err != nil {
+				return err
+			}
+		}
+
+		for _, entry := range action.entries {
+			if entry.Stage == 0 {
+				if info, err := os.Lstat(fullPath); err == nil {
+					entry.Size = uint32(info.Size())
+					entry.ModifiedAt = info.ModTime()
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	// The encoder's sort isn't stable, so stages must already be in order
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Stage < entries[j].Stage
+	})
+	idx.Entries = entries
+	return e.repository.Storer.SetIndex(idx)
+}
+
+// storeBlob writes content to the object database and returns its hash
+func (e *GoGitExecutor) storeBlob(content []byte) (plumbing.Hash, error) {
+	obj := e.repository.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return e.repository.Storer.SetEncodedObject(obj)
+}
+
+// commitEntries returns the files of a commit by path
+func commitEntries(commit *object.Commit) (map[string]treeEntry, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]treeEntry)
+	err = tree.Files().ForEach(func(file *object.File) error {
+		entries[file.Name] = treeEntry{hash: file.Hash, mode: file.Mode}
+		return nil
+	})
+	return entries, err
+}
+
+// writeMergedFile writes a merge result to the working tree, nil content deletes it
+func writeMergedFile(fullPath string, content []byte, mode filemode.FileMode) error {
+	if content == nil {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if mode == filemode.Symlink {
+		return os.Symlink(string(content), fullPath)
+	}
+
+	perm := os.FileMode(0644)
+	if mode == filemode.Executable {
+		perm = 0755
+	}
+	return os.WriteFile(fullPath, content, perm)
+}
+
+// writeConflictMarkers writes both sides of a conflict between git's markers
+func writeConflictMarkers(buf *bytes.Buffer, ours, theirs, theirsLabel string) {
+	buf.WriteString("<<<<<<< HEAD\n")
+	buf.WriteString(withFinalNewline(ours))
+	buf.WriteString("=======\n")
+	buf.WriteString(withFinalNewline(theirs))
+	buf.WriteString(">>>>>>> " + theirsLabel + "\n")
+}
+
+// mergeMessage returns the commit message git uses for merging ref
+func mergeMessage(ref plumbing.ReferenceName) string {
+	if ref.IsRemote() {
+		return fmt.Sprintf("Merge remote-tracking branch '%s'", ref.Short())
+	}
+	return fmt.Sprintf("Merge branch '%s'", ref.Short())
+}
+
+// CLIGitExecutor runs git operations with the git binary
+type CLIGitExecutor struct {
+	repoPath string
+	gitPath  string
+	timeout  time.Duration
+}
+
+// NewCLIGitExecutor creates an executor for the git binary on PATH. A zero timeout
+// uses the default.
+func NewCLIGitExecutor(repoPath string, timeout time.Duration) (*CLIGitExecutor, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, &GitError{Op: "find_git", Err: err, Details: "The git command line tool is not installed or not on PATH."}
+	}
+	if timeout <= 0 {
+		timeout = defaultGitTimeout
+	}
+	return &CLIGitExecutor{repoPath: repoPath, gitPath: gitPath, timeout: timeout}, nil
+}
+
+// UnmergedFiles lists the unmerged paths with git diff
+func (e *CLIGitExecutor) UnmergedFiles(ctx context.Context) ([]string, error) {
+	output, err := e.run(ctx, "unmerged_files", "diff", "--name-only", "--diff-filter=U", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	paths := nulSeparated(output)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// CheckoutSide checks out one side with git checkout --ours or --theirs. Files the
+// side doesn't have are deleted, git checkout refuses them.
+func (e *CLIGitExecutor) CheckoutSide(ctx context.Context, side string, paths []string) error {
+	stage, err := conflictSideStage(side)
+	if err != nil {
+		return &GitError{Op: "checkout_side", Err: err}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	// Entries are "<mode> <hash> <stage>\t<path>"
+	args := append([]string{"ls-files", "--unmerged", "-z", "--"}, paths...)
+	output, err := e.run(ctx, "checkout_side", args...)
+	if err != nil {
+		return err
+	}
+	hasSide := make(map[string]bool)
+	for _, entry := range nulSeparated(output) {
+		info, relPath, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if ok && len(fields) == 3 && fields[2] == fmt.Sprint(int(stage)) {
+			hasSide[relPath] = true
+		}
+	}
+
+	checkout := make([]string, 0, len(paths))
+	for _, relPath := range paths {
+		if hasSide[relPath] {
+			checkout = append(checkout, relPath)
+			continue
+		}
+		if err := os.Remove(filepath.Join(e.repoPath, filepath.FromSlash(relPath))); err != nil && !os.IsNotExist(err) {
+			return &GitError{Op: "checkout_side", Err: err, Details: relPath}
+		}
+	}
+	if len(checkout) == 0 {
+		return nil
+	}
+
+	args = append([]string{"checkout", "--" + side, "--"}, checkout...)
+	_, err = e.run(ctx, "checkout_side", args...)
+	return err
+}
+
+// Add stages files with git add, including deletions and ignored files
+func (e *CLIGitExecutor) Add(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := append([]string{"add", "--force", "--all", "--"}, paths...)
+	_, err := e.run(ctx, "add", args...)
+	return err
+}
+
+// Merge runs git merge --no-commit. Stopping on conflicts isn't an error.
+func (e *CLIGitExecutor) Merge(ctx context.Context, ref plumbing.ReferenceName) ([]string, error) {
+	args := []string{"merge", "--no-ff", "--no-commit", "-m", mergeMessage(ref), ref.String()}
+
+	// git wants an identity even though CommitMerge makes the commit, so fall back
+	// to GitNotes' default when git has none
+	if _, err := e.run(ctx, "merge", "var", "GIT_COMMITTER_IDENT"); err != nil {
+		args = append([]string{"-c", "user.name=GitNotes", "-c", "user.email=gitnotes@example.com"}, args...)
+	}
+
+	_, err := e.run(ctx, "merge", args...)
+	if err == nil {
+		return []string{}, nil
+	}
+
+	conflicts, unmergedErr := e.UnmergedFiles(ctx)
+	if unmergedErr != nil || len(conflicts) == 0 {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// run runs git in the repository and returns its output. On failure the error
+// carries git's stderr as details.
+func (e *CLIGitExecutor) run(ctx context.Context, op string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.gitPath, args...)
+	cmd.Dir = e.repoPath
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Report the cancellation or timeout rather than the killed process
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, &GitError{Op: op, Err: err, Details: strings.TrimSpace(stderr.String())}
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// conflictSideStage returns the index stage holding a side's version of a conflicted file
+func conflictSideStage(side string) (index.Stage, error) {
+	switch side {
+	case ConflictSideOurs:
+		return index.OurMode, nil
+	case ConflictSideTheirs:
+		return index.TheirMode, nil
+	}
+	return 0, fmt.Errorf("invalid side: %s (must be 'ours' or 'theirs')", side)
+}
+
+// nulSeparated splits NUL-terminated git output
+func nulSeparated(output []byte) []string {
+	fields := make([]string, 0)
+	for _, field := range strings.Split(string(output), "\x00") {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
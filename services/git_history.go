@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// defaultHistoryLimit is the number of revisions returned when no limit is given
+const defaultHistoryLimit = 100
+
+// ErrFileNotInRevision is returned when a file doesn't exist in the requested commit
+var ErrFileNotInRevision = errors.New("file does not exist in that version")
+
+// FileRevision is a commit that changed a file
+type FileRevision struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"shortHash"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"`
+	Path      string    `json:"path"`              // Path of the file in this commit
+	OldPath   string    `json:"oldPath,omitempty"` // Previous path when the commit renamed the file
+	Action    string    `json:"action"`            // ChangeAdded, ChangeModified, ChangeDeleted or ChangeRenamed
+}
+
+// FileHistory returns the commits that changed a file, newest first, following it
+// through renames. A limit of zero or less returns up to defaultHistoryLimit revisions.
+func (gs *GitService) FileHistory(relPath string, limit int) ([]FileRevision, error) {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	revisions := make([]FileRevision, 0)
+	err := gs.traceFile(relPath, func(_ *object.Commit, _ string, revision *FileRevision) bool {
+		if revision != nil {
+			revisions = append(revisions, *revision)
+		}
+		return len(revisions) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// FileContentAt returns the content of a file in a commit and the path it had there.
+// relPath is the file's current path; renames since the commit are followed.
+func (gs *GitService) FileContentAt(relPath string, revision string) ([]byte, string, error) {
+	hash, err := gs.repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, "", gs.classifyError("file_content_at", err)
+	}
+	commit, err := gs.repository.CommitObject(*hash)
+	if err != nil {
+		return nil, "", gs.classifyError("file_content_at", err)
+	}
+
+	// Find the file's path in the commit if it was renamed since
+	pathAtCommit := relPath
+	if _, err := commit.File(relPath); err != nil {
+		pathAtCommit = ""
+		err := gs.traceFile(relPath, func(traced *object.Commit, tracedPath string, _ *FileRevision) bool {
+			if traced.Hash == commit.Hash {
+				pathAtCommit = tracedPath
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if pathAtCommit == "" {
+			return nil, "", ErrFileNotInRevision
+		}
+	}
+
+	file, err := commit.File(pathAtCommit)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, "", ErrFileNotInRevision
+		}
+		return nil, "", gs.classifyError("file_content_at", err)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return nil, "", gs.classifyError("file_content_at", err)
+	}
+
+	return []byte(content), pathAtCommit, nil
+}
+
+// DeletedFile is a file deleted by a commit that doesn't exist at HEAD
+type DeletedFile struct {
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash"` // Commit that deleted the file
+	ShortHash string    `json:"shortHash"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"`
+}
+
+// DeletedFiles returns the files deleted in the history that don't exist at HEAD, most
+// recent deletion first. Renamed files aren't deleted. A limit of zero or less returns
+// up to defaultHistoryLimit files.
+func (gs *GitService) DeletedFiles(limit int) ([]DeletedFile, error) {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	deleted := make([]DeletedFile, 0)
+	head, err := gs.repository.Head()
+	if err != nil {
+		// No commits yet, so nothing was deleted
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return deleted, nil
+		}
+		return nil, gs.classifyError("deleted_files", err)
+	}
+	headCommit, err := gs.repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, gs.classifyError("deleted_files", err)
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, gs.classifyError("deleted_files", err)
+	}
+
+	commits, err := gs.repository.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, gs.classifyError("deleted_files", err)
+	}
+	defer commits.Close()
+
+	seen := make(map[string]bool)
+	errStop := errors.New("stop")
+	err = commits.ForEach(func(commit *object.Commit) error {
+		// The deletions a merge brings in are reported for the commits that made them
+		if commit.NumParents() != 1 {
+			return nil
+		}
+
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return err
+		}
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+		changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			filePath := change.From.Name
+			if change.To.Name != "" || filePath == "" || seen[filePath] {
+				continue
+			}
+			// Only the latest deletion of a path counts, and only if it wasn't recreated
+			seen[filePath] = true
+			if !fileHashInTree(headTree, filePath).IsZero() {
+				continue
+			}
+
+			deleted = append(deleted, DeletedFile{
+				Path:      filePath,
+				Hash:      commit.Hash.String(),
+				ShortHash: commit.Hash.String()[:7],
+				Message:   commit.Message,
+				Author:    commit.Author.Name,
+				Email:     commit.Author.Email,
+				Date:      commit.Author.When,
+			})
+			if len(deleted) >= limit {
+				return errStop
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, gs.classifyError("deleted_files", err)
+	}
+
+	return deleted, nil
+}
+
+// traceFile walks the history from HEAD, newest first, following the file through
+// renames. visit receives every commit the file's path is known for, with a revision
+// when the commit changed the file. Returning false stops the walk.
+//
+// Like git log, a merge only counts as a change when the file differs from every
+// parent, so changes are attributed to the commit that made them.
+func (gs *GitService) traceFile(relPath string, visit func(commit *object.Commit, path string, revision *FileRevision) bool) error {
+	head, err := gs.repository.Head()
+	if err != nil {
+		// No commits yet, so no history
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil
+		}
+		return gs.classifyError("file_history", err)
+	}
+
+	commits, err := gs.repository.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return gs.classifyError("file_history", err)
+	}
+	defer commits.Close()
+
+	// The file's path in each commit still to visit
+	tracked := map[plumbing.Hash]string{head.Hash(): relPath}
+	errStop := errors.New("stop")
+	err = commits.ForEach(func(commit *object.Commit) error {
+		filePath, ok := tracked[commit.Hash]
+		if !ok {
+			return nil
+		}
+		delete(tracked, commit.Hash)
+
+		tree, err := commit.Tree()
+		if err != nil {
+			return err
+		}
+		current := fileHashInTree(tree, filePath)
+
+		revision := &FileRevision{
+			Hash:      commit.Hash.String(),
+			ShortHash: commit.Hash.String()[:7],
+			Message:   commit.Message,
+			Author:    commit.Author.Name,
+			Email:     commit.Author.Email,
+			Date:      commit.Author.When,
+			Path:      filePath,
+			Action:    ChangeModified,
+		}
+		changed := !current.IsZero() && commit.NumParents() == 0
+
+		for i, parentHash := range commit.ParentHashes {
+			parent, err := commit.Parent(i)
+			if err != nil {
+				return err
+			}
+			parentTree, err := parent.Tree()
+			if err != nil {
+				return err
+			}
+
+			parentPath := filePath
+			previous := fileHashInTree(parentTree, filePath)
+			if !current.IsZero() && previous.IsZero() {
+				// The file is new relative to this parent unless it was renamed
+				parentPath = renamedFrom(parentTree, tree, filePath)
+				if parentPath != "" {
+					previous = fileHashInTree(parentTree, parentPath)
+				}
+			}
+
+			if parentPath != "" {
+				if _, ok := tracked[parentHash]; !ok {
+					tracked[parentHash] = parentPath
+				}
+			}
+
+			// The commit changed the file only if it differs from every parent
+			if current == previous && parentPath == filePath {
+				changed = false
+				break
+			}
+			if i == 0 {
+				changed = true
+				switch {
+				case current.IsZero():
+					revision.Action = ChangeDeleted
+				case parentPath == "":
+					revision.Action = ChangeAdded
+				case parentPath != filePath:
+					revision.Action = ChangeRenamed
+					revision.OldPath = parentPath
+				}
+			}
+		}
+
+		if commit.NumParents() == 0 {
+			revision.Action = ChangeAdded
+		}
+		if !changed {
+			revision = nil
+		}
+
+		if !visit(commit, filePath, revision) || len(tracked) == 0 {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return gs.classifyError("file_history", err)
+	}
+
+	return nil
+}
+
+// fileHashInTree returns the blob hash of a file in a tree, or the zero hash if it doesn't exist
+func fileHashInTree(tree *object.Tree, filePath string) plumbing.Hash {
+	entry, err := tree.FindEntry(filePath)
+	if err != nil || !entry.Mode.IsFile() {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// renamedFrom returns the path a file was renamed from between two trees, or "" when
+// it was added
+func renamedFrom(from, to *object.Tree, filePath string) string {
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, object.DefaultDiffTreeOptions)
+	if err != nil {
+		fmt.Printf("Warning: Unable to detect renames of %s: %v\n", filePath, err)
+		return ""
+	}
+
+	for _, change := range changes {
+		if change.To.Name == filePath && change.From.Name != "" && change.From.Name != filePath {
+			return change.From.Name
+		}
+	}
+	return ""
+}
@@ -6,284 +6,1091 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
 // GitNotesService is the main service that combines all other services
-// and is exposed to the Wails frontend
+// and is exposed to the Wails frontend.
+// Every vault has its own repository, file and sync services. APIs taking a
+// vaultID operate on the active vault when it is empty.
 type GitNotesService struct {
-	repoService *RepositoryService
-	fileService *FileService
-	syncManager *SyncManager
-	syncActive  bool
-	stopSync    chan struct{}
+	vaults        map[string]*vault      // Connected vaults by ID
+	connecting    map[string]*sync.Mutex // Serializes connecting each vault, by ID
+	activeVaultID string
+	credService   *CredentialService
+	emitter       EventEmitter
+	mu            sync.Mutex
+	settingsMu    sync.Mutex     // Serializes read-modify-write cycles of the settings file
+	shutDown      bool           // Set by OnShutdown, no vault connects after it
+	restoring     sync.WaitGroup // Vaults connecting to restart their automatic sync
 }
 
 // NewGitNotesService creates a new GitNotesService instance
 func NewGitNotesService() *GitNotesService {
-	repoService := NewRepositoryService()
-	fileService := NewFileService(repoService)
+	gns := &GitNotesService{
+		vaults:      make(map[string]*vault),
+		connecting:  make(map[string]*sync.Mutex),
+		credService: NewCredentialService(),
+	}
+
+	// Registered vaults are connected on first use, or now to restart their automatic sync
+	vaults, activeVaultID := vaultsFromSettings(gns.loadSettingsMap())
+	gns.activeVaultID = activeVaultID
+	gns.restoreAutomaticSync(vaults)
+
+	// Move any plaintext token left in settings.json by older versions into the credential store
+	if err := gns.migrateSettingsToken(); err != nil {
+		fmt.Printf("Warning: Unable to migrate token from settings: %v\n", err)
+	}
+
+	return gns
+}
 
-	return &GitNotesService{
-		repoService: repoService,
-		fileService: fileService,
-		syncManager: nil,
-		syncActive:  false,
-		stopSync:    make(chan struct{}),
+// SetEventEmitter sets the emitter used to push sync status, conflict and progress events
+// to the frontend
+func (gns *GitNotesService) SetEventEmitter(emitter EventEmitter) {
+	gns.emitter = emitter
+	for _, v := range gns.openVaults() {
+		v.repoService.SetEventEmitter(gns.eventEmitterFor(v.config.ID))
+		v.syncManager.SetEventEmitter(gns.eventEmitterFor(v.config.ID))
 	}
 }
 
-// ConnectRepository connects to a GitHub repository
-func (gns *GitNotesService) ConnectRepository(repoURL, localPath, token string) error {
-	// Stop sync if it's already running
-	if gns.syncActive {
-		gns.StopAutomaticSync()
+// ConnectRepository connects to a GitHub repository and makes it the active vault
+// branch and remoteName choose the notes branch and the remote it syncs with,
+// empty values keep the checked out branch (or the remote's default when cloning) and origin.
+// A repository already registered at localPath reuses its vault.
+func (gns *GitNotesService) ConnectRepository(repoURL, localPath, token, branch, remoteName string) error {
+	vaults, _ := vaultsFromSettings(gns.loadSettingsMap())
+
+	config := VaultConfig{
+		Name:      vaultName(localPath),
+		RepoURL:   repoURL,
+		LocalPath: localPath,
+		Branch:    branch,
+		Remote:    remoteName,
+	}
+	for _, existing := range vaults {
+		if filepath.Clean(existing.LocalPath) == filepath.Clean(localPath) {
+			config.ID = existing.ID
+			config.Name = existing.Name
+		}
 	}
 
+	_, err := gns.addVault(config, token)
+	return err
+}
+
+// AddVault registers and connects a repository as a new vault and makes it active.
+// It returns the vault as JSON.
+func (gns *GitNotesService) AddVault(name, repoURL, localPath, token, branch, remoteName string) (string, error) {
+	if name == "" {
+		name = vaultName(localPath)
+	}
+
+	config, err := gns.addVault(VaultConfig{
+		Name:      name,
+		RepoURL:   repoURL,
+		LocalPath: localPath,
+		Branch:    branch,
+		Remote:    remoteName,
+	}, token)
+	if err != nil {
+		return "", err
+	}
+
+	vaultJSON, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling vault: %w", err)
+	}
+
+	return string(vaultJSON), nil
+}
+
+// addVault connects the vault, registers it (replacing a vault with the same ID) and
+// makes it active
+func (gns *GitNotesService) addVault(config VaultConfig, token string) (VaultConfig, error) {
+	settings := gns.loadSettingsMap()
+
 	// If no token is provided, try to get it from settings
 	if token == "" {
-		settingsJson, err := gns.LoadSettings()
-		if err == nil && settingsJson != "{}" {
-			var settings map[string]interface{}
-			if err := json.Unmarshal([]byte(settingsJson), &settings); err == nil {
-				if tokenVal, ok := settings["token"].(string); ok && tokenVal != "" {
-					token = tokenVal
-				}
-			}
+		if tokenVal, ok := settings["token"].(string); ok && tokenVal != "" {
+			token = tokenVal
+		}
+	}
+
+	if config.ID == "" {
+		id, err := newVaultID()
+		if err != nil {
+			return config, err
+		}
+		config.ID = id
+	}
+
+	// Reconnecting replaces the running services of the vault
+	connectMu := gns.connectLock(config.ID)
+	connectMu.Lock()
+	defer connectMu.Unlock()
+
+	gns.mu.Lock()
+	previous := gns.vaults[config.ID]
+	gns.mu.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+
+	v, err := gns.connectVault(config, token)
+	if err != nil {
+		return config, err
+	}
+
+	gns.mu.Lock()
+	if gns.shutDown {
+		gns.mu.Unlock()
+		v.close()
+		return config, ErrServiceShutDown
+	}
+	gns.vaults[config.ID] = v
+	gns.activeVaultID = config.ID
+	gns.mu.Unlock()
+
+	err = gns.updateVaultRegistry(func(vaults []VaultConfig, _ string) ([]VaultConfig, string, error) {
+		if idx := findVaultConfig(vaults, config.ID); idx >= 0 {
+			config.AutoSync = vaults[idx].AutoSync
+			config.SyncInterval = vaults[idx].SyncInterval
+			vaults[idx] = config
+		} else {
+			vaults = append(vaults, config)
+		}
+		return vaults, config.ID, nil
+	})
+	if err != nil {
+		return config, err
+	}
+
+	// A reconnected vault keeps syncing automatically
+	if config.AutoSync {
+		v.syncMu.Lock()
+		defer v.syncMu.Unlock()
+		if err := gns.startAutomaticSyncLocked(v, config.SyncInterval); err != nil {
+			fmt.Printf("Warning: Unable to restart automatic sync: %v\n", err)
+		}
+	}
+
+	return config, nil
+}
+
+// RemoveVault stops syncing a vault and removes it from the registry.
+// The local clone is left on disk.
+func (gns *GitNotesService) RemoveVault(vaultID string) error {
+	err := gns.updateVaultRegistry(func(vaults []VaultConfig, activeID string) ([]VaultConfig, string, error) {
+		idx := findVaultConfig(vaults, vaultID)
+		if idx < 0 {
+			return nil, "", fmt.Errorf("%w: %s", ErrVaultNotFound, vaultID)
+		}
+		if activeID == vaultID {
+			activeID = ""
+		}
+		return append(vaults[:idx], vaults[idx+1:]...), activeID, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	gns.mu.Lock()
+	v := gns.vaults[vaultID]
+	delete(gns.vaults, vaultID)
+	if gns.activeVaultID == vaultID {
+		gns.activeVaultID = ""
+	}
+	gns.mu.Unlock()
+
+	if v != nil {
+		v.close()
+		v.syncManager.CancelSync()
+	}
+
+	return nil
+}
+
+// ListVaults returns the registered vaults and their state as JSON
+func (gns *GitNotesService) ListVaults() (string, error) {
+	vaults, _ := vaultsFromSettings(gns.loadSettingsMap())
+
+	gns.mu.Lock()
+	infos := make([]VaultInfo, 0, len(vaults))
+	for _, config := range vaults {
+		info := VaultInfo{
+			VaultConfig: config,
+			Active:      config.ID == gns.activeVaultID,
+		}
+		if v, ok := gns.vaults[config.ID]; ok {
+			info.Connected = v.repoService.IsConnected()
+			info.SyncActive = v.isSyncActive()
+			info.SyncStatus = v.syncManager.GetSyncStatus()
+		}
+		infos = append(infos, info)
+	}
+	gns.mu.Unlock()
+
+	vaultsJSON, err := json.Marshal(infos)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling vaults: %w", err)
+	}
+
+	return string(vaultsJSON), nil
+}
+
+// SwitchVault makes a registered vault the active one, connecting it if needed.
+// Automatic sync of the other vaults keeps running.
+func (gns *GitNotesService) SwitchVault(vaultID string) error {
+	if vaultID == "" {
+		return errors.New("vault ID is required")
+	}
+
+	if _, err := gns.vault(vaultID); err != nil {
+		return err
+	}
+
+	gns.mu.Lock()
+	gns.activeVaultID = vaultID
+	gns.mu.Unlock()
+
+	return gns.updateVaultRegistry(func(vaults []VaultConfig, _ string) ([]VaultConfig, string, error) {
+		return vaults, vaultID, nil
+	})
+}
+
+// GetActiveVault returns the active vault as JSON
+func (gns *GitNotesService) GetActiveVault() (string, error) {
+	v, err := gns.vault("")
+	if err != nil {
+		return "", err
+	}
+
+	vaultJSON, err := json.Marshal(v.config)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling vault: %w", err)
+	}
+
+	return string(vaultJSON), nil
+}
+
+// ValidateConnection tests if the repository connection works
+func (gns *GitNotesService) ValidateConnection(repoURL, token string) error {
+	// Validate with a fresh service so connected vaults are unaffected
+	repoService := NewRepositoryService()
+	repoService.AuthProvider().SetSSHOptions(sshOptionsFromSettings(gns.loadSettingsMap()))
+
+	err := repoService.ValidateConnection(repoURL, token)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetSSHCredentials configures SSH authentication for git@host: and ssh:// remotes
+// An empty keyPath with useAgent set uses the keys loaded in ssh-agent.
+// The passphrase is stored in the credential store, never in the settings file.
+func (gns *GitNotesService) SetSSHCredentials(keyPath, passphrase, knownHostsPath string, useAgent bool) error {
+	if keyPath != "" {
+		if _, err := os.Stat(keyPath); err != nil {
+			return fmt.Errorf("error accessing SSH key: %w", err)
+		}
+	}
+
+	if keyPath != "" && passphrase != "" {
+		if err := gns.credService.StoreCredential(sshPassphraseAccount(keyPath), passphrase); err != nil {
+			return fmt.Errorf("failed to store SSH key passphrase: %w", err)
+		}
+	}
+
+	options := SSHAuthOptions{
+		KeyPath:        keyPath,
+		KnownHostsPath: knownHostsPath,
+		UseAgent:       useAgent,
+	}
+	for _, v := range gns.openVaults() {
+		v.repoService.AuthProvider().SetSSHOptions(options)
+	}
+
+	// Persist the non-secret options alongside the other settings
+	return gns.updateSettings(func(settings map[string]interface{}) error {
+		settings["sshKeyPath"] = options.KeyPath
+		settings["sshKnownHostsPath"] = options.KnownHostsPath
+		settings["sshUseAgent"] = options.UseAgent
+		return nil
+	})
+}
+
+// GetCommitIdentity returns the author identity used for commits as JSON
+// The identity depends on the git config of the vault repository.
+func (gns *GitNotesService) GetCommitIdentity(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	identityJSON, err := json.Marshal(v.syncManager.gitService.CommitIdentity())
+	if err != nil {
+		return "", fmt.Errorf("error marshaling commit identity: %w", err)
+	}
+
+	return string(identityJSON), nil
+}
+
+// SetCommitIdentity sets the author name and email used for commits.
+// Empty values fall back to the repository and global git config.
+func (gns *GitNotesService) SetCommitIdentity(name, email string) error {
+	var settings map[string]interface{}
+	err := gns.updateSettings(func(stored map[string]interface{}) error {
+		stored["commitAuthorName"] = name
+		stored["commitAuthorEmail"] = email
+		settings = stored
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		if err := gns.applyCommitSettings(v.syncManager.gitService, settings); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetCommitSigning configures commit signing
+// format is "openpgp", "ssh" or "" to disable signing. The key passphrase is
+// stored in the credential store, never in the settings file.
+func (gns *GitNotesService) SetCommitSigning(format, keyPath, passphrase string) error {
+	options := CommitSigningOptions{
+		Format:  SigningFormat(format),
+		KeyPath: keyPath,
+	}
+
+	// Validate the key before saving anything
+	if _, err := NewCommitSigner(options, passphrase); err != nil {
+		return err
+	}
+
+	if keyPath != "" && passphrase != "" {
+		if err := gns.credService.StoreCredential(signingPassphraseAccount(keyPath), passphrase); err != nil {
+			return fmt.Errorf("failed to store signing key passphrase: %w", err)
 		}
 	}
 
-	// Connect to the repository
-	err := gns.repoService.ConnectRepository(repoURL, localPath, token)
+	var settings map[string]interface{}
+	err := gns.updateSettings(func(stored map[string]interface{}) error {
+		stored["commitSigningFormat"] = format
+		stored["commitSigningKey"] = keyPath
+		settings = stored
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		if err := gns.applyCommitSettings(v.syncManager.gitService, settings); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetCurrentBranch returns the checked out branch and its upstream as JSON
+func (gns *GitNotesService) GetCurrentBranch(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	upstream, err := v.syncManager.gitService.Upstream()
+	if err != nil {
+		return "", err
+	}
+
+	upstreamJSON, err := json.Marshal(upstream)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling branch: %w", err)
+	}
+
+	return string(upstreamJSON), nil
+}
+
+// ListRemoteBranches returns the branches on the upstream remote as JSON
+func (gns *GitNotesService) ListRemoteBranches(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	branches, err := v.syncManager.gitService.ListRemoteBranches()
+	if err != nil {
+		return "", err
+	}
+
+	branchesJSON, err := json.Marshal(branches)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling branches: %w", err)
+	}
+
+	return string(branchesJSON), nil
+}
+
+// SwitchBranch checks out another branch as the notes branch
+// It fails if there are uncommitted changes or a sync is in progress.
+func (gns *GitNotesService) SwitchBranch(vaultID string, branch string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	if v.syncManager.IsSyncing() {
+		return errors.New("cannot switch branches while a sync is in progress")
+	}
+
+	return v.syncManager.gitService.SwitchBranch(branch)
+}
+
+// CreateBranch creates a branch from the current one and makes it the notes branch
+// The branch is created on the remote by the next sync.
+func (gns *GitNotesService) CreateBranch(vaultID string, branch string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	if v.syncManager.IsSyncing() {
+		return errors.New("cannot create a branch while a sync is in progress")
+	}
+
+	return v.syncManager.gitService.CreateBranch(branch)
+}
+
+// GetRepositoryStructure returns the directory structure of the repository
+func (gns *GitNotesService) GetRepositoryStructure(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	rootNode, err := v.fileService.GetRepositoryStructure()
+	if err != nil {
+		return "", err
+	}
+
+	// Convert the file tree to JSON
+	jsonData, err := json.Marshal(rootNode)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling file tree: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// GetFileContent reads and returns the content of a file
+func (gns *GitNotesService) GetFileContent(vaultID string, filePath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := v.fileService.GetFileContent(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	// Opened notes rank higher in quick open
+	if v.quickOpen != nil {
+		v.quickOpen.RecordOpened(filePath)
+	}
+
+	return content, nil
+}
+
+// WriteFileContent writes content to a file
+func (gns *GitNotesService) WriteFileContent(vaultID string, filePath string, content string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+
+	return v.fileService.WriteFileContent(filePath, content)
+}
+
+// GetFileContentWithHash returns a note's content with the hash to pass to
+// WriteFileContentIfMatch, as a FileContent JSON object
+func (gns *GitNotesService) GetFileContentWithHash(vaultID string, filePath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := v.fileService.GetFileContentWithHash(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if v.quickOpen != nil {
+		v.quickOpen.RecordOpened(filePath)
+	}
+
+	fileJSON, err := json.Marshal(file)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling file content: %w", err)
+	}
+
+	return string(fileJSON), nil
+}
+
+// FileWriteResult is the outcome of a conditional write: the hash of the written
+// content, or the conflict that prevented the write
+type FileWriteResult struct {
+	Hash     string              `json:"hash,omitempty"`
+	Conflict *WriteConflictError `json:"conflict,omitempty"`
+}
+
+// WriteFileContentIfMatch writes a note only if it still has the content with
+// expectedHash. A note changed since it was read isn't written, the returned
+// FileWriteResult JSON then holds the conflict with the current content.
+func (gns *GitNotesService) WriteFileContentIfMatch(vaultID string, filePath string, content string, expectedHash string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	var result FileWriteResult
+	result.Hash, err = v.fileService.WriteFileContentIfMatch(filePath, content, expectedHash)
+	if err != nil && !errors.As(err, &result.Conflict) {
+		return "", err
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling write result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
+
+// GetChildrenOfPath gets the direct children of a directory
+func (gns *GitNotesService) GetChildrenOfPath(vaultID string, dirPath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	children, err := v.fileService.GetChildrenOfPath(dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Convert to JSON
+	jsonData, err := json.Marshal(children)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling children: %w", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// IsMarkdownFile checks if a file is a Markdown file
+func (gns *GitNotesService) IsMarkdownFile(filePath string) bool {
+	return isMarkdownPath(filePath)
+}
+
+// CreateFile creates a new file with the given content
+func (gns *GitNotesService) CreateFile(vaultID string, filePath string, content string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+
+	return v.fileService.CreateFile(filePath, content)
+}
+
+// CreateDirectory creates a new directory
+func (gns *GitNotesService) CreateDirectory(vaultID string, dirPath string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+
+	return v.fileService.CreateDirectory(dirPath)
+}
+
+// DeleteFile moves a file or directory to the vault's trash
+func (gns *GitNotesService) DeleteFile(vaultID string, filePath string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+
+	return v.fileService.DeleteFile(filePath)
+}
+
+// Search searches the notes of the vault and returns the results as JSON, best match first
+// Words must all appear in a note, "quoted phrases" must appear in order and a
+// trailing * matches words starting with the prefix.
+func (gns *GitNotesService) Search(vaultID string, query string, limit int) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	if v.searchIndex == nil {
+		return "", errors.New("search index not available")
+	}
+
+	results, err := v.searchIndex.Search(query, limit)
+	if err != nil {
+		return "", err
+	}
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling search results: %w", err)
+	}
+
+	return string(resultsJSON), nil
+}
+
+// QuickOpen fuzzy matches the query against every file path in the vault and returns
+// the ranked results as JSON. Recently opened notes are boosted and an empty query
+// lists them first.
+func (gns *GitNotesService) QuickOpen(vaultID string, query string, limit int) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	if v.quickOpen == nil {
+		return "", errors.New("quick open not available")
+	}
+
+	results, err := v.quickOpen.Search(query, limit)
+	if err != nil {
+		return "", err
+	}
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling quick open results: %w", err)
+	}
+
+	return string(resultsJSON), nil
+}
+
+// GetBacklinks returns the links pointing to a note from other notes as JSON
+func (gns *GitNotesService) GetBacklinks(vaultID string, filePath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	links, err := v.linkGraph.Backlinks(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	linksJSON, err := json.Marshal(links)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling backlinks: %w", err)
+	}
+
+	return string(linksJSON), nil
+}
+
+// GetOutgoingLinks returns the wiki and Markdown links in a note as JSON.
+// Links that don't resolve to a note in the vault have an empty target.
+func (gns *GitNotesService) GetOutgoingLinks(vaultID string, filePath string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	links, err := v.linkGraph.OutgoingLinks(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	linksJSON, err := json.Marshal(links)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling links: %w", err)
+	}
+
+	return string(linksJSON), nil
+}
+
+// GetOrphanNotes returns the paths of notes without any links to or from other notes as JSON
+func (gns *GitNotesService) GetOrphanNotes(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	orphans, err := v.linkGraph.Orphans()
+	if err != nil {
+		return "", err
+	}
+
+	orphansJSON, err := json.Marshal(orphans)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling orphan notes: %w", err)
+	}
+
+	return string(orphansJSON), nil
+}
+
+// GetLinkGraph exports the notes of the vault and the links between them as
+// nodes and edges JSON
+func (gns *GitNotesService) GetLinkGraph(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Create GitService and SyncManager after successful connection
-	gitService, err := NewGitService(localPath, repoURL)
+	graph, err := v.linkGraph.Export()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Initialize SyncManager
-	gns.syncManager = NewSyncManager(gitService)
+	graphJSON, err := json.Marshal(graph)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling link graph: %w", err)
+	}
 
-	return nil
+	return string(graphJSON), nil
 }
 
-// ValidateConnection tests if the repository connection works
-func (gns *GitNotesService) ValidateConnection(repoURL, token string) error {
-	err := gns.repoService.ValidateConnection(repoURL, token)
+// RebuildSearchIndex discards the search index of the vault and indexes every note again
+func (gns *GitNotesService) RebuildSearchIndex(vaultID string) error {
+	v, err := gns.vault(vaultID)
 	if err != nil {
 		return err
 	}
-	return nil
+	if v.searchIndex == nil {
+		return errors.New("search index not available")
+	}
+
+	return v.searchIndex.Rebuild()
 }
 
-// GetRepositoryStructure returns the directory structure of the repository
-func (gns *GitNotesService) GetRepositoryStructure() (string, error) {
-	if !gns.repoService.IsConnected() {
-		return "", errors.New("not connected to a repository")
+// TriggerManualSync performs a manual synchronization with the remote repository
+func (gns *GitNotesService) TriggerManualSync(vaultID string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
 
-	rootNode, err := gns.fileService.GetRepositoryStructure()
+	// Use the SyncManager to trigger a sync with detailed status tracking
+	_, err = v.syncManager.TriggerManualSync()
+	return err
+}
+
+// GetSyncStatus returns the current synchronization status
+func (gns *GitNotesService) GetSyncStatus(vaultID string) string {
+	v, err := gns.vault(vaultID)
 	if err != nil {
-		return "", err
+		return "Disconnected"
 	}
 
-	// Convert the file tree to JSON
-	jsonData, err := json.Marshal(rootNode)
+	return v.syncManager.GetSyncStatus()
+}
+
+// GetSyncHistory returns the synchronization history as JSON
+func (gns *GitNotesService) GetSyncHistory(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling file tree: %w", err)
+		return "[]", nil
 	}
 
-	return string(jsonData), nil
-}
+	history := v.syncManager.GetSyncHistory()
 
-// GetFileContent reads and returns the content of a file
-func (gns *GitNotesService) GetFileContent(filePath string) (string, error) {
-	if !gns.repoService.IsConnected() {
-		return "", errors.New("not connected to a repository")
+	// Convert to JSON
+	historyJSON, err := json.Marshal(history)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling sync history: %w", err)
 	}
 
-	return gns.fileService.GetFileContent(filePath)
+	return string(historyJSON), nil
 }
 
-// WriteFileContent writes content to a file
-func (gns *GitNotesService) WriteFileContent(filePath string, content string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+// QuerySyncHistory returns persisted sync history as JSON, newest first
+// since and until are RFC 3339 timestamps and statuses is a comma-separated list;
+// empty values don't filter. file limits results to syncs that changed or conflicted on it.
+func (gns *GitNotesService) QuerySyncHistory(vaultID string, since, until, statuses, file string, offset, limit int) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
 	}
 
-	return gns.fileService.WriteFileContent(filePath, content)
-}
+	query := SyncHistoryQuery{
+		File:   file,
+		Offset: offset,
+		Limit:  limit,
+	}
 
-// GetChildrenOfPath gets the direct children of a directory
-func (gns *GitNotesService) GetChildrenOfPath(dirPath string) (string, error) {
-	if !gns.repoService.IsConnected() {
-		return "", errors.New("not connected to a repository")
+	if since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return "", fmt.Errorf("invalid since time: %w", err)
+		}
+	}
+	if until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return "", fmt.Errorf("invalid until time: %w", err)
+		}
+	}
+	for _, status := range strings.Split(statuses, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			query.Statuses = append(query.Statuses, SyncStatus(status))
+		}
 	}
 
-	children, err := gns.fileService.GetChildrenOfPath(dirPath)
+	page, err := v.syncManager.QuerySyncHistory(query)
 	if err != nil {
 		return "", err
 	}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(children)
+	pageJSON, err := json.Marshal(page)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling children: %w", err)
+		return "", fmt.Errorf("error marshaling sync history: %w", err)
 	}
 
-	return string(jsonData), nil
-}
-
-// IsMarkdownFile checks if a file is a Markdown file
-func (gns *GitNotesService) IsMarkdownFile(filePath string) bool {
-	return gns.fileService.IsMarkdownFile(filePath)
+	return string(pageJSON), nil
 }
 
-// CreateFile creates a new file with the given content
-func (gns *GitNotesService) CreateFile(filePath string, content string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+// StartAutomaticSync starts automatic synchronization with the remote repository
+// Local edits are synced once writes settle for the configured debounce period,
+// and the remote is checked for changes every fetch interval. A positive
+// intervalSeconds overrides the configured fetch interval.
+func (gns *GitNotesService) StartAutomaticSync(vaultID string, intervalSeconds int) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
 
-	return gns.fileService.CreateFile(filePath, content)
-}
+	v.syncMu.Lock()
+	defer v.syncMu.Unlock()
 
-// CreateDirectory creates a new directory
-func (gns *GitNotesService) CreateDirectory(dirPath string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+	// Don't start if it's already running
+	if v.syncActive {
+		return nil
 	}
 
-	return gns.fileService.CreateDirectory(dirPath)
+	if err := gns.startAutomaticSyncLocked(v, intervalSeconds); err != nil {
+		return err
+	}
+	return gns.setVaultAutoSync(v.config.ID, true, intervalSeconds)
 }
 
-// DeleteFile deletes a file
-func (gns *GitNotesService) DeleteFile(filePath string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+// startAutomaticSyncLocked starts the watcher of a vault. The caller must hold v.syncMu.
+func (gns *GitNotesService) startAutomaticSyncLocked(v *vault, intervalSeconds int) error {
+	if v.closed {
+		return ErrServiceShutDown
 	}
 
-	return gns.fileService.DeleteFile(filePath)
-}
+	intervals := gns.loadSyncIntervals(v)
+	if intervalSeconds > 0 {
+		intervals.FetchIntervalSeconds = intervalSeconds
+	}
 
-// TriggerManualSync performs a manual synchronization with the remote repository
-func (gns *GitNotesService) TriggerManualSync() error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+	// Start watching the repository
+	watcher := NewSyncWatcher(v.repoService.GetRepositoryPath(), v.syncManager, intervals)
+	if err := watcher.Start(); err != nil {
+		return err
 	}
 
-	if gns.syncManager == nil {
-		return errors.New("sync manager not initialized")
+	v.syncWatcher = watcher
+	v.syncActive = true
+
+	return nil
+}
+
+// StopAutomaticSync stops automatic synchronization
+func (gns *GitNotesService) StopAutomaticSync(vaultID string) {
+	if v, err := gns.vault(vaultID); err == nil {
+		gns.stopAutomaticSync(v)
+		if err := gns.setVaultAutoSync(v.config.ID, false, 0); err != nil {
+			fmt.Printf("Warning: Unable to save automatic sync setting: %v\n", err)
+		}
 	}
+}
 
-	// Use the SyncManager to trigger a sync with detailed status tracking
-	_, err := gns.syncManager.TriggerManualSync()
-	return err
+// setVaultAutoSync records whether automatic sync of the vault restarts with the app,
+// and with which fetch interval
+func (gns *GitNotesService) setVaultAutoSync(vaultID string, enabled bool, intervalSeconds int) error {
+	return gns.updateVaultRegistry(func(vaults []VaultConfig, activeID string) ([]VaultConfig, string, error) {
+		if idx := findVaultConfig(vaults, vaultID); idx >= 0 {
+			vaults[idx].AutoSync = enabled
+			vaults[idx].SyncInterval = intervalSeconds
+		}
+		return vaults, activeID, nil
+	})
 }
 
-// GetSyncStatus returns the current synchronization status
-func (gns *GitNotesService) GetSyncStatus() string {
-	if gns.syncManager == nil {
-		if !gns.repoService.IsConnected() {
-			return "Disconnected"
+// restoreAutomaticSync restarts automatic sync of the vaults it was on for when the
+// application last quit, in the background since each vault is connected first
+func (gns *GitNotesService) restoreAutomaticSync(vaults []VaultConfig) {
+	for _, config := range vaults {
+		if !config.AutoSync {
+			continue
 		}
-		return "Connected"
+		gns.restoring.Add(1)
+		go func(config VaultConfig) {
+			defer gns.restoring.Done()
+			v, err := gns.vault(config.ID)
+			if err == nil {
+				v.syncMu.Lock()
+				if !v.syncActive {
+					err = gns.startAutomaticSyncLocked(v, config.SyncInterval)
+				}
+				v.syncMu.Unlock()
+			}
+			if err != nil && !errors.Is(err, ErrServiceShutDown) {
+				fmt.Printf("Warning: Unable to restart automatic sync of %s: %v\n", config.Name, err)
+			}
+		}(config)
 	}
+}
 
-	return gns.syncManager.GetSyncStatus()
+// stopAutomaticSync stops the watcher of a vault
+func (gns *GitNotesService) stopAutomaticSync(v *vault) {
+	v.syncMu.Lock()
+	defer v.syncMu.Unlock()
+
+	gns.stopAutomaticSyncLocked(v)
 }
 
-// GetSyncHistory returns the synchronization history as JSON
-func (gns *GitNotesService) GetSyncHistory() (string, error) {
-	if gns.syncManager == nil {
-		return "[]", nil
+// stopAutomaticSyncLocked stops the watcher of a vault and waits for a running sync.
+// The caller must hold v.syncMu.
+func (gns *GitNotesService) stopAutomaticSyncLocked(v *vault) {
+	v.stopSyncLocked()
+}
+
+// OnShutdown closes every vault when the application quits. The watchers first sync
+// edits still waiting for the debounce, and the search indexes are saved so the next
+// start doesn't re-read the notes. Automatic sync keeps its setting for the next start.
+func (gns *GitNotesService) OnShutdown() error {
+	gns.mu.Lock()
+	gns.shutDown = true
+	gns.mu.Unlock()
+	gns.restoring.Wait()
+
+	var wg sync.WaitGroup
+	for _, v := range gns.openVaults() {
+		wg.Add(1)
+		go func(v *vault) {
+			defer wg.Done()
+			v.close()
+		}(v)
 	}
+	wg.Wait()
 
-	history := gns.syncManager.GetSyncHistory()
+	return nil
+}
 
-	// Convert to JSON
-	historyJSON, err := json.Marshal(history)
+// GetSyncIntervals returns the automatic sync intervals for the vault as JSON
+func (gns *GitNotesService) GetSyncIntervals(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling sync history: %w", err)
+		return "", err
 	}
 
-	return string(historyJSON), nil
+	intervalsJSON, err := json.Marshal(gns.loadSyncIntervals(v))
+	if err != nil {
+		return "", fmt.Errorf("error marshaling sync intervals: %w", err)
+	}
+
+	return string(intervalsJSON), nil
 }
 
-// StartAutomaticSync starts automatic synchronization with the remote repository
-func (gns *GitNotesService) StartAutomaticSync(intervalSeconds int) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
+// SetSyncIntervals sets the debounce and fetch intervals for the vault.
+// A running automatic sync is restarted with the new intervals.
+func (gns *GitNotesService) SetSyncIntervals(vaultID string, debounceSeconds, fetchIntervalSeconds int) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
-
-	if gns.syncManager == nil {
-		return errors.New("sync manager not initialized")
+	if debounceSeconds <= 0 || fetchIntervalSeconds <= 0 {
+		return errors.New("sync intervals must be positive")
 	}
 
-	// Don't start if it's already running
-	if gns.syncActive {
+	// Intervals are stored per repository, keyed by the local path
+	err = gns.updateSettings(func(settings map[string]interface{}) error {
+		allIntervals, ok := settings["syncIntervals"].(map[string]interface{})
+		if !ok {
+			allIntervals = make(map[string]interface{})
+		}
+		allIntervals[v.repoService.GetRepositoryPath()] = SyncIntervals{
+			DebounceSeconds:      debounceSeconds,
+			FetchIntervalSeconds: fetchIntervalSeconds,
+		}
+		settings["syncIntervals"] = allIntervals
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Default interval if not provided
-	if intervalSeconds <= 0 {
-		intervalSeconds = 300 // 5 minutes
+	// Restart the watcher so the new intervals take effect
+	v.syncMu.Lock()
+	defer v.syncMu.Unlock()
+	if v.syncActive {
+		gns.stopAutomaticSyncLocked(v)
+		return gns.startAutomaticSyncLocked(v, 0)
 	}
 
-	// Start the sync loop in a goroutine
-	gns.stopSync = make(chan struct{})
-	gns.syncActive = true
+	return nil
+}
 
-	go func() {
-		ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
-		defer ticker.Stop()
+// loadSyncIntervals returns the stored intervals for the vault, or the defaults
+func (gns *GitNotesService) loadSyncIntervals(v *vault) SyncIntervals {
+	intervals := DefaultSyncIntervals()
 
-		for {
-			select {
-			case <-ticker.C:
-				// Perform sync using the SyncManager
-				_, err := gns.syncManager.TriggerManualSync()
-				if err != nil {
-					// Log the error but continue the sync loop
-					fmt.Printf("Auto-sync error: %v\n", err)
-				}
-			case <-gns.stopSync:
-				return
-			}
-		}
-	}()
+	settings := gns.loadSettingsMap()
+	allIntervals, ok := settings["syncIntervals"].(map[string]interface{})
+	if !ok {
+		return intervals
+	}
 
-	return nil
-}
+	repoIntervals, ok := allIntervals[v.repoService.GetRepositoryPath()].(map[string]interface{})
+	if !ok {
+		return intervals
+	}
 
-// StopAutomaticSync stops automatic synchronization
-func (gns *GitNotesService) StopAutomaticSync() {
-	if gns.syncActive {
-		close(gns.stopSync)
-		gns.syncActive = false
+	if debounce, ok := repoIntervals["debounceSeconds"].(float64); ok && debounce > 0 {
+		intervals.DebounceSeconds = int(debounce)
+	}
+	if fetchInterval, ok := repoIntervals["fetchIntervalSeconds"].(float64); ok && fetchInterval > 0 {
+		intervals.FetchIntervalSeconds = int(fetchInterval)
 	}
-}
 
-// IsAutoSyncActive returns whether automatic synchronization is active
-func (gns *GitNotesService) IsAutoSyncActive() bool {
-	return gns.syncActive
+	return intervals
 }
 
-// DetectConflicts checks for and returns any Git merge conflicts
-func (gns *GitNotesService) DetectConflicts() (string, error) {
-	if !gns.repoService.IsConnected() {
-		return "", errors.New("not connected to a repository")
+// IsAutoSyncActive returns whether automatic synchronization is active
+func (gns *GitNotesService) IsAutoSyncActive(vaultID string) bool {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return false
 	}
 
-	if gns.syncManager == nil {
-		return "", errors.New("sync manager not initialized")
+	return v.isSyncActive()
+}
+
+// DetectConflicts checks the index for merge conflicts and returns them as JSON,
+// with the base, ours and theirs blob IDs and the conflict type of each file
+func (gns *GitNotesService) DetectConflicts(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
 	}
 
-	conflicts, err := gns.syncManager.DetectConflicts()
+	conflicts, err := v.syncManager.DetectConflicts()
 	if err != nil {
 		return "", err
 	}
@@ -298,16 +1105,13 @@ func (gns *GitNotesService) DetectConflicts() (string, error) {
 }
 
 // GetConflictDetails provides detailed information about any detected conflicts
-func (gns *GitNotesService) GetConflictDetails() (string, error) {
-	if !gns.repoService.IsConnected() {
-		return "", errors.New("not connected to a repository")
-	}
-
-	if gns.syncManager == nil {
-		return "", errors.New("sync manager not initialized")
+func (gns *GitNotesService) GetConflictDetails(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
 	}
 
-	details := gns.syncManager.GetConflictDetails()
+	details := v.syncManager.GetConflictDetails()
 
 	// Convert to JSON
 	detailsJSON, err := json.Marshal(details)
@@ -319,16 +1123,13 @@ func (gns *GitNotesService) GetConflictDetails() (string, error) {
 }
 
 // AbortSyncWithConflicts aborts a sync operation that has conflicts
-func (gns *GitNotesService) AbortSyncWithConflicts() error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
-	}
-
-	if gns.syncManager == nil {
-		return errors.New("sync manager not initialized")
+func (gns *GitNotesService) AbortSyncWithConflicts(vaultID string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
 
-	return gns.syncManager.AbortSync()
+	return v.syncManager.AbortSync()
 }
 
 // SetConflictResolutionStrategy sets the conflict resolution strategy
@@ -337,12 +1138,11 @@ func (gns *GitNotesService) AbortSyncWithConflicts() error {
 // - "ours": Automatically use our/local changes
 // - "theirs": Automatically use their/remote changes
 // - "both": Keep both sets of changes with conflict markers
-func (gns *GitNotesService) SetConflictResolutionStrategy(strategy string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("repository not connected")
-	}
-	if gns.syncManager == nil {
-		return errors.New("sync manager not initialized")
+// - "copy": Keep their changes and save ours alongside as a conflicted copy
+func (gns *GitNotesService) SetConflictResolutionStrategy(vaultID string, strategy string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
 
 	// Validate and convert the strategy string
@@ -356,22 +1156,21 @@ func (gns *GitNotesService) SetConflictResolutionStrategy(strategy string) error
 		conflictStrategy = ConflictStrategyTheirs
 	case "both":
 		conflictStrategy = ConflictStrategyBoth
+	case "copy":
+		conflictStrategy = ConflictStrategyCopy
 	default:
 		return fmt.Errorf("invalid conflict resolution strategy: %s", strategy)
 	}
 
-	gns.syncManager.SetConflictStrategy(conflictStrategy)
+	v.syncManager.SetConflictStrategy(conflictStrategy)
 	return nil
 }
 
 // ResolveConflictsWithStrategy resolves conflicts using the specified strategy
-func (gns *GitNotesService) ResolveConflictsWithStrategy(strategy string) error {
-	if !gns.repoService.IsConnected() {
-		return errors.New("not connected to a repository")
-	}
-
-	if gns.syncManager == nil {
-		return errors.New("sync manager not initialized")
+func (gns *GitNotesService) ResolveConflictsWithStrategy(vaultID string, strategy string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
 	}
 
 	// Validate and convert the strategy string
@@ -385,11 +1184,13 @@ func (gns *GitNotesService) ResolveConflictsWithStrategy(strategy string) error
 		conflictStrategy = ConflictStrategyTheirs
 	case "both":
 		conflictStrategy = ConflictStrategyBoth
+	case "copy":
+		conflictStrategy = ConflictStrategyCopy
 	default:
 		return fmt.Errorf("invalid conflict resolution strategy: %s", strategy)
 	}
 
-	return gns.syncManager.ResolveConflictWithStrategy(conflictStrategy)
+	return v.syncManager.ResolveConflictWithStrategy(conflictStrategy)
 }
 
 // GetSettings returns the current application settings
@@ -408,13 +1209,18 @@ func (gns *GitNotesService) GetSettings() (string, error) {
 		settingsMap = make(map[string]interface{})
 	}
 
-	// Update with current runtime values
-	if gns.repoService.IsConnected() {
-		settingsMap["repoURL"] = gns.repoService.repoURL
-		settingsMap["localRepoPath"] = gns.repoService.localRepoPath
-		settingsMap["isConnected"] = gns.repoService.isConnected
-		settingsMap["syncActive"] = gns.syncActive
+	// Update with the runtime values of the active vault
+	gns.mu.Lock()
+	active := gns.vaults[gns.activeVaultID]
+	gns.mu.Unlock()
+	if active != nil && active.repoService.IsConnected() {
+		settingsMap["repoURL"] = active.repoService.repoURL
+		settingsMap["localRepoPath"] = active.repoService.localRepoPath
+		settingsMap["isConnected"] = active.repoService.isConnected
+		settingsMap["syncActive"] = active.isSyncActive()
+		settingsMap["activeVault"] = active.config.ID
 	}
+	settingsMap["credentialBackend"] = gns.GetCredentialBackend()
 
 	jsonData, err := json.Marshal(settingsMap)
 	if err != nil {
@@ -425,7 +1231,43 @@ func (gns *GitNotesService) GetSettings() (string, error) {
 }
 
 // SaveSettings saves the application settings to a file
+// The settings are merged into the stored ones so keys managed by the backend,
+// such as the vault registry, are kept. A "token" field is moved into the
+// credential store instead of being written to disk. While the store is locked
+// nothing is saved and the error wraps ErrCredentialStoreLocked, so the caller can
+// unlock it with UnlockCredentialStore and save again.
 func (gns *GitNotesService) SaveSettings(settings string) error {
+	var settingsMap map[string]interface{}
+	if err := json.Unmarshal([]byte(settings), &settingsMap); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+
+	return gns.updateSettings(func(merged map[string]interface{}) error {
+		for key, value := range settingsMap {
+			merged[key] = value
+		}
+		return nil
+	})
+}
+
+// writeSettings writes the settings JSON to the settings file
+// A "token" field is moved into the credential store instead of being written to disk.
+func (gns *GitNotesService) writeSettings(settings string) error {
+	var settingsMap map[string]interface{}
+	if err := json.Unmarshal([]byte(settings), &settingsMap); err == nil {
+		changed, err := gns.moveTokenToCredentialStore(settingsMap)
+		if err != nil {
+			return fmt.Errorf("failed to store token securely: %w", err)
+		}
+		if changed {
+			settingsJSON, err := json.Marshal(settingsMap)
+			if err != nil {
+				return fmt.Errorf("error marshaling settings: %w", err)
+			}
+			settings = string(settingsJSON)
+		}
+	}
+
 	// Get the config directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -439,7 +1281,7 @@ func (gns *GitNotesService) SaveSettings(settings string) error {
 
 	// Save settings to file
 	settingsFile := filepath.Join(configDir, "settings.json")
-	if err := os.WriteFile(settingsFile, []byte(settings), 0600); err != nil {
+	if err := atomicWriteFile(settingsFile, []byte(settings), 0600); err != nil {
 		return fmt.Errorf("error writing settings file: %w", err)
 	}
 
@@ -471,3 +1313,217 @@ func (gns *GitNotesService) LoadSettings() (string, error) {
 
 	return string(data), nil
 }
+
+// GetCredentialBackend returns the name of the credential backend in use
+func (gns *GitNotesService) GetCredentialBackend() string {
+	return gns.credService.BackendName()
+}
+
+// UnlockCredentialStore unlocks the encrypted file credential backend with a passphrase.
+// Once unlocked, any plaintext token still in the settings file is migrated.
+func (gns *GitNotesService) UnlockCredentialStore(passphrase string) error {
+	fileBackend, ok := gns.credService.Backend().(*EncryptedFileBackend)
+	if !ok {
+		return fmt.Errorf("credential backend %s does not use a passphrase", gns.GetCredentialBackend())
+	}
+
+	if err := fileBackend.Unlock(passphrase); err != nil {
+		return err
+	}
+
+	return gns.migrateSettingsToken()
+}
+
+// migrateSettingsToken moves a plaintext token from settings.json into the credential store
+func (gns *GitNotesService) migrateSettingsToken() error {
+	gns.settingsMu.Lock()
+	defer gns.settingsMu.Unlock()
+
+	settings := gns.loadSettingsMap()
+
+	changed, err := gns.moveTokenToCredentialStore(settings)
+	if err != nil || !changed {
+		return err
+	}
+
+	return gns.saveSettingsMap(settings)
+}
+
+// moveTokenToCredentialStore stores the settings "token" under the settings repository URL
+// and removes it from the map. It reports whether the map was changed.
+func (gns *GitNotesService) moveTokenToCredentialStore(settings map[string]interface{}) (bool, error) {
+	token, ok := settings["token"].(string)
+	if !ok {
+		return false, nil
+	}
+
+	if token != "" {
+		repoURL, _ := settings["repoURL"].(string)
+		if repoURL == "" {
+			return false, errors.New("settings contain a token but no repository URL")
+		}
+
+		if err := gns.credService.StoreCredential(repoURL, token); err != nil {
+			return false, err
+		}
+	}
+
+	delete(settings, "token")
+	return true, nil
+}
+
+// loadSettingsMap loads the stored settings as a map, returning an empty map if none exist
+func (gns *GitNotesService) loadSettingsMap() map[string]interface{} {
+	settings := make(map[string]interface{})
+
+	settingsJSON, err := gns.LoadSettings()
+	if err != nil || settingsJSON == "{}" {
+		return settings
+	}
+
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return make(map[string]interface{})
+	}
+
+	return settings
+}
+
+// GetCommitMessageTemplate returns the template used for auto-commit messages
+func (gns *GitNotesService) GetCommitMessageTemplate() string {
+	if template, ok := gns.loadSettingsMap()["commitMessageTemplate"].(string); ok && template != "" {
+		return template
+	}
+	return DefaultCommitMessageTemplate
+}
+
+// SetCommitMessageTemplate sets the Go text/template used for auto-commit messages.
+// An empty template restores the default.
+func (gns *GitNotesService) SetCommitMessageTemplate(template string) error {
+	generator, err := NewCommitMessageGenerator(template)
+	if err != nil {
+		return err
+	}
+
+	err = gns.updateSettings(func(settings map[string]interface{}) error {
+		settings["commitMessageTemplate"] = template
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		v.syncManager.SetCommitMessageGenerator(generator)
+	}
+
+	return nil
+}
+
+// GetGitExecutor returns the executor that merges pulls and resolves conflicts,
+// ExecutorGoGit or ExecutorCLI
+func (gns *GitNotesService) GetGitExecutor() string {
+	if name, ok := gns.loadSettingsMap()["gitExecutor"].(string); ok && name != "" {
+		return name
+	}
+	return ExecutorGoGit
+}
+
+// SetGitExecutor chooses the executor that merges pulls and resolves conflicts in
+// every vault: ExecutorGoGit, or ExecutorCLI to use the installed git. Fetch, push
+// and commits always use go-git.
+func (gns *GitNotesService) SetGitExecutor(name string) error {
+	// Check the choice before saving it, e.g. that git is installed
+	if _, err := NewGitExecutor(name, "", nil); err != nil {
+		return err
+	}
+
+	err := gns.updateSettings(func(settings map[string]interface{}) error {
+		settings["gitExecutor"] = name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		if err := v.syncManager.gitService.UseExecutor(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateSettings loads the stored settings, applies change and saves them. The
+// settings lock is held throughout so concurrent updates don't drop each other's keys.
+func (gns *GitNotesService) updateSettings(change func(settings map[string]interface{}) error) error {
+	gns.settingsMu.Lock()
+	defer gns.settingsMu.Unlock()
+
+	settings := gns.loadSettingsMap()
+	if err := change(settings); err != nil {
+		return err
+	}
+	return gns.saveSettingsMap(settings)
+}
+
+// saveSettingsMap replaces the settings file with the settings map. Updates of
+// stored settings go through updateSettings.
+func (gns *GitNotesService) saveSettingsMap(settings map[string]interface{}) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error marshaling settings: %w", err)
+	}
+
+	return gns.writeSettings(string(settingsJSON))
+}
+
+// applyCommitSettings configures the commit author and signing key from the settings map
+func (gns *GitNotesService) applyCommitSettings(gitService *GitService, settings map[string]interface{}) error {
+	identity := CommitIdentity{}
+	if name, ok := settings["commitAuthorName"].(string); ok {
+		identity.Name = name
+	}
+	if email, ok := settings["commitAuthorEmail"].(string); ok {
+		identity.Email = email
+	}
+	gitService.SetCommitIdentity(identity)
+
+	options := CommitSigningOptions{}
+	if format, ok := settings["commitSigningFormat"].(string); ok {
+		options.Format = SigningFormat(format)
+	}
+	if keyPath, ok := settings["commitSigningKey"].(string); ok {
+		options.KeyPath = keyPath
+	}
+	if options.Format == SigningFormatNone {
+		gitService.SetCommitSigner(nil)
+		return nil
+	}
+
+	// The passphrase is optional and kept in the credential store keyed by the key path
+	passphrase, _ := gns.credService.GetCredential(signingPassphraseAccount(options.KeyPath))
+
+	signer, err := NewCommitSigner(options, passphrase)
+	if err != nil {
+		return err
+	}
+	gitService.SetCommitSigner(signer)
+
+	return nil
+}
+
+// sshOptionsFromSettings extracts the SSH authentication options from the settings map
+func sshOptionsFromSettings(settings map[string]interface{}) SSHAuthOptions {
+	options := SSHAuthOptions{}
+	if keyPath, ok := settings["sshKeyPath"].(string); ok {
+		options.KeyPath = keyPath
+	}
+	if knownHostsPath, ok := settings["sshKnownHostsPath"].(string); ok {
+		options.KnownHostsPath = knownHostsPath
+	}
+	if useAgent, ok := settings["sshUseAgent"].(bool); ok {
+		options.UseAgent = useAgent
+	}
+	return options
+}
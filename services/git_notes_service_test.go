@@ -0,0 +1,361 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestGitNotesService returns a service whose settings live in a temp home directory
+func newTestGitNotesService(t *testing.T) *GitNotesService {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	return NewGitNotesService()
+}
+
+func TestConcurrentSettingsUpdates(t *testing.T) {
+	gns := newTestGitNotesService(t)
+
+	updates := []func(i int) error{
+		func(i int) error { return gns.SaveSettings(fmt.Sprintf(`{"frontend%d": true}`, i)) },
+		func(i int) error { return gns.SetProtectedPaths([]string{fmt.Sprintf("private%d", i)}) },
+		func(i int) error { return gns.SetTrashRetentionDays(i + 1) },
+		func(i int) error { return gns.SetCommitIdentity(fmt.Sprintf("Author %d", i), "author@example.com") },
+		func(i int) error { return gns.SetCommitMessageTemplate(fmt.Sprintf("Update %d", i)) },
+	}
+
+	const rounds = 10
+	var wg sync.WaitGroup
+	for _, update := range updates {
+		for i := 0; i < rounds; i++ {
+			wg.Add(1)
+			go func(update func(int) error, i int) {
+				defer wg.Done()
+				if err := update(i); err != nil {
+					t.Errorf("update settings: %v", err)
+				}
+			}(update, i)
+		}
+	}
+	wg.Wait()
+
+	// Every update must survive the others
+	settings := gns.loadSettingsMap()
+	for i := 0; i < rounds; i++ {
+		if settings[fmt.Sprintf("frontend%d", i)] != true {
+			t.Errorf("frontend%d was lost", i)
+		}
+	}
+	for _, key := range []string{"protectedPaths", "trashRetentionDays", "commitAuthorName", "commitMessageTemplate"} {
+		if _, ok := settings[key]; !ok {
+			t.Errorf("%s was lost", key)
+		}
+	}
+}
+
+func TestConcurrentFirstVaultUse(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+
+	// The vault isn't cloned yet, so each connection would clone it
+	config := VaultConfig{ID: "notes", Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}
+	t.Cleanup(func() { gns.OnShutdown() })
+	err := gns.updateVaultRegistry(func([]VaultConfig, string) ([]VaultConfig, string, error) {
+		return []VaultConfig{config}, config.ID, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 8
+	vaults := make([]*vault, callers)
+	var wg sync.WaitGroup
+	for i := range vaults {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := gns.vault(config.ID)
+			if err != nil {
+				t.Errorf("open vault: %v", err)
+			}
+			vaults[i] = v
+		}(i)
+	}
+	wg.Wait()
+
+	for _, v := range vaults[1:] {
+		if v != vaults[0] {
+			t.Fatal("vault connected more than once")
+		}
+	}
+
+	registry, err := gns.ListVaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []VaultInfo
+	if err := json.Unmarshal([]byte(registry), &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || !infos[0].Connected {
+		t.Fatalf("vaults = %+v, want the connected vault", infos)
+	}
+}
+
+func TestShutdownSyncsPendingEdits(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+	config := VaultConfig{ID: "notes", Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}
+	err := gns.updateVaultRegistry(func([]VaultConfig, string) ([]VaultConfig, string, error) {
+		return []VaultConfig{config}, config.ID, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gns.SetCommitIdentity(testSignature.Name, testSignature.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := gns.StartAutomaticSync(config.ID, 3600); err != nil {
+		t.Fatalf("start automatic sync: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(config.LocalPath, "note.md"), "# Note\n\nlast edit\n")
+	time.Sleep(eventDelay)
+	if err := gns.OnShutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if gns.IsAutoSyncActive(config.ID) {
+		t.Error("automatic sync still active after shutdown")
+	}
+	if got := mustRunGit(t, remoteDir, "show", "master:note.md"); got != "# Note\n\nlast edit" {
+		t.Fatalf("remote note.md = %q, want the edit made before quitting", got)
+	}
+}
+
+func TestAutomaticSyncRestartsWithEveryVault(t *testing.T) {
+	gns := newTestGitNotesService(t)
+	var configs []VaultConfig
+	for _, id := range []string{"work", "personal", "manual"} {
+		remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+		configs = append(configs, VaultConfig{ID: id, Name: id, RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), id)})
+	}
+	err := gns.updateVaultRegistry(func([]VaultConfig, string) ([]VaultConfig, string, error) {
+		return configs, "work", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"work", "personal", "manual"} {
+		if err := gns.StartAutomaticSync(id, 3600); err != nil {
+			t.Fatalf("start automatic sync of %s: %v", id, err)
+		}
+	}
+	gns.StopAutomaticSync("manual")
+	if err := gns.OnShutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	// The next start reconnects the vaults that were syncing, active or not
+	restarted := NewGitNotesService()
+	t.Cleanup(func() { restarted.OnShutdown() })
+	restarted.restoring.Wait()
+	for id, want := range map[string]bool{"work": true, "personal": true, "manual": false} {
+		if got := restarted.IsAutoSyncActive(id); got != want {
+			t.Errorf("automatic sync of %s active = %v, want %v", id, got, want)
+		}
+	}
+
+	restarted.mu.Lock()
+	v := restarted.vaults["personal"]
+	restarted.mu.Unlock()
+	v.syncMu.Lock()
+	interval := v.syncWatcher.fetchInterval
+	v.syncMu.Unlock()
+	if interval != time.Hour {
+		t.Errorf("fetch interval = %d, want the one automatic sync was started with", interval)
+	}
+}
+
+func TestReplacedVaultIsClosed(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+	config, err := gns.addVault(VaultConfig{Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}, "")
+	if err != nil {
+		t.Fatalf("add vault: %v", err)
+	}
+	t.Cleanup(func() { gns.OnShutdown() })
+	if err := gns.StartAutomaticSync(config.ID, 3600); err != nil {
+		t.Fatalf("start automatic sync: %v", err)
+	}
+	gns.mu.Lock()
+	previous := gns.vaults[config.ID]
+	gns.mu.Unlock()
+
+	// Adding the vault again replaces the connected one, which keeps syncing
+	if _, err := gns.addVault(config, ""); err != nil {
+		t.Fatalf("add vault again: %v", err)
+	}
+	if previous.isSyncActive() {
+		t.Error("automatic sync of the replaced vault still active")
+	}
+	previous.syncMu.Lock()
+	err = gns.startAutomaticSyncLocked(previous, 0)
+	previous.syncMu.Unlock()
+	if !errors.Is(err, ErrServiceShutDown) {
+		t.Errorf("start on the replaced vault = %v, want ErrServiceShutDown", err)
+	}
+	if !gns.IsAutoSyncActive(config.ID) {
+		t.Error("automatic sync of the new vault not active")
+	}
+}
+
+func TestNoVaultConnectsAfterShutdown(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+	config := VaultConfig{ID: "notes", Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}
+	err := gns.updateVaultRegistry(func([]VaultConfig, string) ([]VaultConfig, string, error) {
+		return []VaultConfig{config}, config.ID, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gns.OnShutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if _, err := gns.vault(config.ID); !errors.Is(err, ErrServiceShutDown) {
+		t.Fatalf("vault after shutdown = %v, want ErrServiceShutDown", err)
+	}
+	if len(gns.openVaults()) != 0 {
+		t.Fatal("a vault connected after shutdown")
+	}
+}
+
+func TestSaveTokenWithLockedCredentialStore(t *testing.T) {
+	gns := newTestGitNotesService(t)
+	t.Setenv(credentialPassphraseEnv, "")
+	backend := NewEncryptedFileBackend(filepath.Join(t.TempDir(), "credentials.enc"))
+	gns.credService = NewCredentialServiceWithBackend(backend)
+	if err := gns.SaveSettings(`{"theme": "light"}`); err != nil {
+		t.Fatalf("save settings without a token: %v", err)
+	}
+
+	const repoURL = "https://example.com/notes.git"
+	settings := fmt.Sprintf(`{"repoURL": %q, "token": "secret", "theme": "dark"}`, repoURL)
+	if err := gns.SaveSettings(settings); !errors.Is(err, ErrCredentialStoreLocked) {
+		t.Fatalf("save with a locked store = %v, want ErrCredentialStoreLocked", err)
+	}
+	stored := gns.loadSettingsMap()
+	if _, ok := stored["token"]; ok {
+		t.Fatal("token written to the settings file")
+	}
+	if stored["theme"] != "light" {
+		t.Fatalf("theme = %v, want the settings unchanged", stored["theme"])
+	}
+
+	// Unlocking lets the same settings be saved again
+	if err := gns.UnlockCredentialStore("passphrase"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := gns.SaveSettings(settings); err != nil {
+		t.Fatalf("save after unlocking: %v", err)
+	}
+	stored = gns.loadSettingsMap()
+	if _, ok := stored["token"]; ok || stored["theme"] != "dark" {
+		t.Fatalf("settings = %v, want the theme saved without the token", stored)
+	}
+	if token, err := backend.Get(repoURL); err != nil || token != "secret" {
+		t.Fatalf("stored token = %q, %v, want the saved token", token, err)
+	}
+}
+
+func TestDiffsReadNotesThroughSandbox(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+	config, err := gns.addVault(VaultConfig{Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}, "")
+	if err != nil {
+		t.Fatalf("add vault: %v", err)
+	}
+	t.Cleanup(func() { gns.OnShutdown() })
+
+	outside := filepath.Join(t.TempDir(), "secret.md")
+	writeTestFile(t, outside, "secret\n")
+	if err := os.Symlink(outside, filepath.Join(config.LocalPath, "link.md")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	writeTestFile(t, filepath.Join(config.LocalPath, "note.md"), "# Note\n\nedit\n")
+	for _, relPath := range []string{"note.md", "link.md"} {
+		if err := gns.SaveDraft(config.ID, relPath, "draft\n", ""); err != nil {
+			t.Fatalf("save draft of %s: %v", relPath, err)
+		}
+	}
+
+	if diff, err := gns.GetFileDiff(config.ID, "note.md", "HEAD", "", ""); err != nil || !strings.Contains(diff, "edit") {
+		t.Errorf("GetFileDiff(note.md) = %q, %v, want the working copy edit", diff, err)
+	}
+	if diff, err := gns.GetDraftDiff(config.ID, "note.md", ""); err != nil || !strings.Contains(diff, "edit") {
+		t.Errorf("GetDraftDiff(note.md) = %q, %v, want the note on disk", diff, err)
+	}
+
+	// The link passes the lexical check but leads outside the vault
+	if diff, err := gns.GetFileDiff(config.ID, "link.md", "HEAD", "", ""); !errors.Is(err, ErrPathOutsideVault) {
+		t.Errorf("GetFileDiff(link.md) = %q, %v, want ErrPathOutsideVault", diff, err)
+	}
+	if diff, err := gns.GetDraftDiff(config.ID, "link.md", ""); !errors.Is(err, ErrPathOutsideVault) {
+		t.Errorf("GetDraftDiff(link.md) = %q, %v, want ErrPathOutsideVault", diff, err)
+	}
+	if diff, err := gns.GetFileDiff(config.ID, ".git/config", "HEAD", "", ""); !errors.Is(err, ErrProtectedPath) {
+		t.Errorf("GetFileDiff(.git/config) = %q, %v, want ErrProtectedPath", diff, err)
+	}
+}
+
+func TestSetGitExecutor(t *testing.T) {
+	requireGit(t)
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gns := newTestGitNotesService(t)
+	config, err := gns.addVault(VaultConfig{Name: "notes", RepoURL: remoteDir, LocalPath: filepath.Join(t.TempDir(), "notes")}, "")
+	if err != nil {
+		t.Fatalf("add vault: %v", err)
+	}
+	t.Cleanup(func() { gns.OnShutdown() })
+
+	if got := gns.GetGitExecutor(); got != ExecutorGoGit {
+		t.Fatalf("default executor = %q, want %q", got, ExecutorGoGit)
+	}
+	if err := gns.SetGitExecutor(ExecutorCLI); err != nil {
+		t.Fatalf("set executor: %v", err)
+	}
+	if err := gns.SetGitExecutor("svn"); err == nil {
+		t.Fatal("setting an unknown executor succeeded")
+	}
+	if got := gns.GetGitExecutor(); got != ExecutorCLI {
+		t.Fatalf("executor = %q, want %q", got, ExecutorCLI)
+	}
+
+	v, err := gns.vault(config.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.syncManager.gitService.gitExecutor().(*CLIGitExecutor); !ok {
+		t.Fatalf("open vault executor = %T, want the git CLI", v.syncManager.gitService.gitExecutor())
+	}
+
+	// A vault connected later uses the saved choice
+	restarted := NewGitNotesService()
+	t.Cleanup(func() { restarted.OnShutdown() })
+	v, err = restarted.vault(config.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.syncManager.gitService.gitExecutor().(*CLIGitExecutor); !ok {
+		t.Fatalf("reconnected vault executor = %T, want the git CLI", v.syncManager.gitService.gitExecutor())
+	}
+}
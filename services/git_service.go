@@ -1,17 +1,21 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 // Custom error types for specific Git error scenarios
@@ -45,11 +49,24 @@ func (e *GitError) Unwrap() error {
 
 // GitService handles Git operations for a repository
 type GitService struct {
-	repoPath    string
-	repository  *git.Repository
-	credService *CredentialService
-	repoURL     string
-	lastError   *GitError // Store the last error for error detail retrieval
+	repoPath     string
+	repository   *git.Repository
+	authProvider AuthProvider
+	authMode     AuthMode // Mode used by the most recent network operation
+	repoURL      string
+	lastError    *GitError // Store the last error for error detail retrieval
+	emitter      EventEmitter
+	commitMu     sync.Mutex     // Guards identity and signer, which settings change while syncs commit
+	identity     CommitIdentity // Author identity from GitNotes settings, overrides git config
+	signer       git.Signer     // Optional commit signer
+	executorMu   sync.Mutex     // Guards executor, which the settings change while syncs run
+	executor     GitExecutor    // Runs pull merges and the index and worktree operations of conflict resolution
+}
+
+// CommitIdentity is the author name and email used for commits
+type CommitIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 // NewGitService creates a new GitService for the given repository path
@@ -68,28 +85,127 @@ func NewGitService(repoPath string, repoURL string) (*GitService, error) {
 	}
 
 	return &GitService{
-		repoPath:    repoPath,
-		repository:  repo,
-		credService: NewCredentialService(),
-		repoURL:     repoURL,
+		repoPath:     repoPath,
+		repository:   repo,
+		authProvider: NewAuthProvider(NewCredentialService()),
+		authMode:     AuthModeNone,
+		repoURL:      repoURL,
+		executor:     NewGoGitExecutor(repoPath, repo, 0),
 	}, nil
 }
 
+// SetAuthProvider replaces the provider used to authenticate network operations
+func (gs *GitService) SetAuthProvider(provider AuthProvider) {
+	gs.authProvider = provider
+}
+
+// SetEventEmitter sets the emitter used to report transfer progress
+func (gs *GitService) SetEventEmitter(emitter EventEmitter) {
+	gs.emitter = emitter
+}
+
+// SetCommitIdentity sets the author identity from GitNotes settings.
+// Empty fields fall back to the repository and global git config.
+func (gs *GitService) SetCommitIdentity(identity CommitIdentity) {
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	gs.identity = identity
+}
+
+// SetExecutor sets the executor for pull merges and conflict resolution, nil restores
+// the go-git executor
+func (gs *GitService) SetExecutor(executor GitExecutor) {
+	if executor == nil {
+		executor = NewGoGitExecutor(gs.repoPath, gs.repository, 0)
+	}
+
+	gs.executorMu.Lock()
+	defer gs.executorMu.Unlock()
+
+	gs.executor = executor
+}
+
+// UseExecutor sets the executor by name, ExecutorGoGit or ExecutorCLI. An empty name
+// selects go-git.
+func (gs *GitService) UseExecutor(name string) error {
+	executor, err := NewGitExecutor(name, gs.repoPath, gs.repository)
+	if err != nil {
+		return err
+	}
+	gs.SetExecutor(executor)
+	return nil
+}
+
+// gitExecutor returns the executor for pull merges and conflict resolution
+func (gs *GitService) gitExecutor() GitExecutor {
+	gs.executorMu.Lock()
+	defer gs.executorMu.Unlock()
+
+	return gs.executor
+}
+
+// SetCommitSigner sets the signer applied to every commit, nil disables signing
+func (gs *GitService) SetCommitSigner(signer git.Signer) {
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	gs.signer = signer
+}
+
+// commitSigner returns the signer applied to commits, nil when signing is off
+func (gs *GitService) commitSigner() git.Signer {
+	gs.commitMu.Lock()
+	defer gs.commitMu.Unlock()
+
+	return gs.signer
+}
+
+// CommitIdentity returns the identity commits are made with, resolved from GitNotes
+// settings, then .git/config, then the global git config
+func (gs *GitService) CommitIdentity() CommitIdentity {
+	gs.commitMu.Lock()
+	identity := gs.identity
+	gs.commitMu.Unlock()
+
+	if identity.Name == "" || identity.Email == "" {
+		// The global scope merges ~/.gitconfig under the repository config
+		cfg, err := gs.repository.ConfigScoped(config.GlobalScope)
+		if err == nil {
+			if identity.Name == "" {
+				identity.Name = cfg.User.Name
+			}
+			if identity.Email == "" {
+				identity.Email = cfg.User.Email
+			}
+		}
+	}
+
+	if identity.Name == "" {
+		identity.Name = "GitNotes"
+	}
+	if identity.Email == "" {
+		identity.Email = "gitnotes@example.com"
+	}
+
+	return identity
+}
+
 // getAuth retrieves authentication credentials for Git operations
-func (gs *GitService) getAuth() (*http.BasicAuth, error) {
-	// Get stored credentials for this repository
-	token, err := gs.credService.GetCredential(gs.repoURL)
+// A nil auth method with a nil error means the remote is accessed without credentials.
+func (gs *GitService) getAuth() (transport.AuthMethod, error) {
+	auth, mode, err := gs.authProvider.AuthMethod(gs.repoURL)
+	gs.authMode = mode
 	if err != nil {
-		return nil, &GitError{
-			Op:  "get_credentials",
-			Err: err,
+		var gitErr *GitError
+		if errors.As(err, &gitErr) {
+			gs.lastError = gitErr
+			return nil, gitErr
 		}
+		return nil, gs.classifyError("get_credentials", err)
 	}
 
-	return &http.BasicAuth{
-		Username: "github-token", // This can be any string when using a token
-		Password: token,
-	}, nil
+	return auth, nil
 }
 
 // classifyError attempts to identify common Git error types from error messages
@@ -98,6 +214,16 @@ func (gs *GitService) classifyError(op string, err error) *GitError {
 		return nil
 	}
 
+	gitErr := classifyGitError(op, gs.authMode, err)
+
+	// Store the error for later retrieval
+	gs.lastError = gitErr
+	return gitErr
+}
+
+// classifyGitError maps a go-git error to one of the typed Git errors.
+// The auth mode is used to give a specific hint for authentication failures.
+func classifyGitError(op string, mode AuthMode, err error) *GitError {
 	errMsg := err.Error()
 	gitErr := &GitError{
 		Op:  op,
@@ -105,11 +231,16 @@ func (gs *GitService) classifyError(op string, err error) *GitError {
 	}
 
 	// Check for authentication failures
-	if strings.Contains(errMsg, "authentication required") ||
+	if strings.Contains(errMsg, "knownhosts:") {
+		gitErr.Err = ErrAuthenticationFailed
+		gitErr.Details = "Host key verification failed. The remote host is missing from known_hosts or its key has changed."
+	} else if strings.Contains(errMsg, "authentication required") ||
 		strings.Contains(errMsg, "authentication failed") ||
+		strings.Contains(errMsg, "unable to authenticate") ||
+		strings.Contains(errMsg, "passphrase protected") ||
 		strings.Contains(errMsg, "401") {
 		gitErr.Err = ErrAuthenticationFailed
-		gitErr.Details = "Authentication failed. Check your Personal Access Token."
+		gitErr.Details = authFailureDetails(mode)
 	} else if strings.Contains(errMsg, "connect:") ||
 		strings.Contains(errMsg, "timeout") ||
 		strings.Contains(errMsg, "tls") ||
@@ -129,8 +260,6 @@ func (gs *GitService) classifyError(op string, err error) *GitError {
 		gitErr.Details = "Local changes prevent this operation. Commit or stash your changes first."
 	}
 
-	// Store the error for later retrieval
-	gs.lastError = gitErr
 	return gitErr
 }
 
@@ -175,12 +304,14 @@ func (gs *GitService) CommitChanges(message string) error {
 	}
 
 	// Commit the changes
+	identity := gs.CommitIdentity()
 	_, err = w.Commit(message, &git.CommitOptions{
 		Author: &object.Signature{
-			Name:  "GitNotes",
-			Email: "gitnotes@example.com",
+			Name:  identity.Name,
+			Email: identity.Email,
 			When:  time.Now(),
 		},
+		Signer: gs.commitSigner(),
 	})
 	if err != nil {
 		return gs.classifyError("commit_changes", err)
@@ -200,23 +331,42 @@ func (gs *GitService) PullChanges() error {
 	// Get authentication
 	auth, err := gs.getAuth()
 	if err != nil {
-		// Try to proceed without auth for public repos
-		fmt.Printf("Warning: %v\n", err)
+		return err
 	}
 
+	// Pull the branch tracked by the checked out branch
+	upstream, err := gs.Upstream()
+	if err != nil {
+		return err
+	}
+
+	pullOptions := upstream.pullOptions()
+	pullOptions.Auth = auth
+	pullOptions.Progress = newProgressWriter("pull", gs.emitter)
+
+	// A branch that was never pushed has no tracking ref yet
+	_, trackingErr := gs.repository.Reference(upstream.trackingRef(), true)
+	neverPushed := errors.Is(trackingErr, plumbing.ErrReferenceNotFound)
+
 	// Pull the latest changes
-	err = w.Pull(&git.PullOptions{
-		Auth:          auth,
-		RemoteName:    "origin",
-		ReferenceName: "", // Use default branch
-		Progress:      os.Stdout,
-	})
+	err = w.Pull(pullOptions)
 
 	// Handle already up-to-date case
 	if err == git.NoErrAlreadyUpToDate {
 		return nil
 	}
 
+	// The upstream branch doesn't exist until a new branch is first pushed. Once it
+	// was pushed, a missing upstream branch is an error, e.g. it was deleted remotely.
+	if errors.Is(err, plumbing.ErrReferenceNotFound) && neverPushed {
+		return nil
+	}
+
+	// go-git only fast-forwards, diverged histories are merged by the executor
+	if errors.Is(err, git.ErrNonFastForwardUpdate) {
+		return gs.mergeUpstream(upstream)
+	}
+
 	if err != nil {
 		return gs.classifyError("pull_changes", err)
 	}
@@ -224,20 +374,45 @@ func (gs *GitService) PullChanges() error {
 	return nil
 }
 
+// mergeUpstream merges the fetched upstream branch into HEAD. A clean merge is
+// committed, conflicts are left in the index and reported as ErrMergeConflict.
+func (gs *GitService) mergeUpstream(upstream Upstream) error {
+	conflicts, err := gs.gitExecutor().Merge(context.Background(), upstream.trackingRef())
+	if err != nil {
+		return gs.executorError("pull_changes", err)
+	}
+
+	if len(conflicts) > 0 {
+		gs.lastError = &GitError{
+			Op:      "pull_changes",
+			Err:     ErrMergeConflict,
+			Details: fmt.Sprintf("Merge conflicts in %d files. Manual resolution required.", len(conflicts)),
+		}
+		return gs.lastError
+	}
+
+	return gs.CommitMerge("")
+}
+
 // PushChanges pushes local commits to the remote repository
 func (gs *GitService) PushChanges() error {
 	// Get authentication
 	auth, err := gs.getAuth()
 	if err != nil {
-		// Try to proceed without auth for public repos
-		fmt.Printf("Warning: %v\n", err)
+		return err
+	}
+
+	// Push the checked out branch to its upstream
+	upstream, err := gs.Upstream()
+	if err != nil {
+		return err
 	}
 
-	// Push to remote
 	err = gs.repository.Push(&git.PushOptions{
 		Auth:       auth,
-		RemoteName: "origin",
-		Progress:   os.Stdout,
+		RemoteName: upstream.Remote,
+		RefSpecs:   []config.RefSpec{upstream.pushRefSpec()},
+		Progress:   newProgressWriter("push", gs.emitter),
 	})
 
 	// Handle already up-to-date case
@@ -252,6 +427,141 @@ func (gs *GitService) PushChanges() error {
 	return nil
 }
 
+// StagedChanges returns the changes staged for the next commit with the first heading
+// of each Markdown note. Deleted and added files with identical content are reported as renames.
+func (gs *GitService) StagedChanges() ([]CommitFileChange, error) {
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return nil, gs.classifyError("staged_changes", err)
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, gs.classifyError("staged_changes", err)
+	}
+
+	idx, err := gs.repository.Storer.Index()
+	if err != nil {
+		return nil, gs.classifyError("staged_changes", err)
+	}
+
+	// The HEAD tree doesn't exist before the first commit
+	var headTree *object.Tree
+	if head, err := gs.repository.Head(); err == nil {
+		if commit, err := gs.repository.CommitObject(head.Hash()); err == nil {
+			headTree, _ = commit.Tree()
+		}
+	}
+
+	paths := make([]string, 0, len(status))
+	for filePath := range status {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	type deletion struct {
+		index int
+		hash  plumbing.Hash
+	}
+
+	changes := make([]CommitFileChange, 0, len(paths))
+	addedByHash := make(map[plumbing.Hash][]int) // Additions of each content, in path order
+	deletions := make([]deletion, 0)
+	for _, filePath := range paths {
+		switch status[filePath].Staging {
+		case git.Added:
+			if entry, err := idx.Entry(filePath); err == nil {
+				addedByHash[entry.Hash] = append(addedByHash[entry.Hash], len(changes))
+			}
+			changes = append(changes, CommitFileChange{Action: ChangeAdded, Path: filePath})
+		case git.Modified:
+			changes = append(changes, CommitFileChange{Action: ChangeModified, Path: filePath})
+		case git.Deleted:
+			if headTree != nil {
+				if file, err := headTree.File(filePath); err == nil {
+					deletions = append(deletions, deletion{index: len(changes), hash: file.Hash})
+				}
+			}
+			changes = append(changes, CommitFileChange{Action: ChangeDeleted, Path: filePath})
+		}
+	}
+
+	// Pair deletions with additions of the same content as renames, both in path
+	// order so files with identical content pair up the same way every time
+	renamedFrom := make(map[int]bool)
+	for _, deleted := range deletions {
+		added := addedByHash[deleted.hash]
+		if len(added) == 0 {
+			continue
+		}
+		changes[added[0]].Action = ChangeRenamed
+		changes[added[0]].OldPath = changes[deleted.index].Path
+		renamedFrom[deleted.index] = true
+		addedByHash[deleted.hash] = added[1:]
+	}
+
+	result := make([]CommitFileChange, 0, len(changes))
+	for i, change := range changes {
+		if renamedFrom[i] {
+			continue
+		}
+
+		if isMarkdownPath(change.Path) {
+			var content []byte
+			if change.Action == ChangeDeleted {
+				if headTree != nil {
+					if file, err := headTree.File(change.Path); err == nil {
+						text, _ := file.Contents()
+						content = []byte(text)
+					}
+				}
+			} else {
+				content, _ = os.ReadFile(filepath.Join(gs.repoPath, change.Path))
+			}
+			change.Title = firstHeading(content)
+		}
+
+		result = append(result, change)
+	}
+
+	return result, nil
+}
+
+// StageMoves stages moved files as renames by replacing the old paths in the index with
+// the new ones. Files that weren't tracked are left for the next sync to add.
+func (gs *GitService) StageMoves(moves []FileMove) error {
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return gs.classifyError("stage_moves", err)
+	}
+
+	idx, err := gs.repository.Storer.Index()
+	if err != nil {
+		return gs.classifyError("stage_moves", err)
+	}
+
+	tracked := make([]FileMove, 0, len(moves))
+	for _, move := range moves {
+		if _, err := idx.Remove(move.OldPath); err == nil {
+			tracked = append(tracked, move)
+		}
+	}
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	if err := gs.repository.Storer.SetIndex(idx); err != nil {
+		return gs.classifyError("stage_moves", err)
+	}
+	for _, move := range tracked {
+		if _, err := w.Add(move.NewPath); err != nil {
+			return gs.classifyError("stage_moves", err)
+		}
+	}
+
+	return nil
+}
+
 // HasLocalChanges checks if there are uncommitted changes in the repository
 func (gs *GitService) HasLocalChanges() (bool, error) {
 	// Get the worktree
@@ -270,90 +580,235 @@ func (gs *GitService) HasLocalChanges() (bool, error) {
 	return !status.IsClean(), nil
 }
 
-// DetectConflicts checks for merge conflicts in the repository
-func (gs *GitService) DetectConflicts() ([]string, error) {
-	// Get the worktree
+// HeadCommit returns the hash of the commit HEAD currently points to
+func (gs *GitService) HeadCommit() (plumbing.Hash, error) {
+	head, err := gs.repository.Head()
+	if err != nil {
+		return plumbing.ZeroHash, gs.classifyError("head_commit", err)
+	}
+
+	return head.Hash(), nil
+}
+
+// ChangedFiles returns the paths that differ between two commits
+func (gs *GitService) ChangedFiles(from, to plumbing.Hash) ([]string, error) {
+	if from.IsZero() || to.IsZero() || from == to {
+		return nil, nil
+	}
+
+	fromCommit, err := gs.repository.CommitObject(from)
+	if err != nil {
+		return nil, gs.classifyError("changed_files", err)
+	}
+	toCommit, err := gs.repository.CommitObject(to)
+	if err != nil {
+		return nil, gs.classifyError("changed_files", err)
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, gs.classifyError("changed_files", err)
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, gs.classifyError("changed_files", err)
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, gs.classifyError("changed_files", err)
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		// Deleted files only have a From name
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		files = append(files, name)
+	}
+
+	return files, nil
+}
+
+// SnapshotUntrackedFiles returns the content of every untracked file in the worktree
+// so it can be restored if a pull has to be rolled back
+func (gs *GitService) SnapshotUntrackedFiles() (map[string][]byte, error) {
 	w, err := gs.repository.Worktree()
 	if err != nil {
-		return nil, gs.classifyError("detect_conflicts", err)
+		return nil, gs.classifyError("snapshot_untracked", err)
 	}
 
-	// Get the status
 	status, err := w.Status()
 	if err != nil {
-		return nil, gs.classifyError("detect_conflicts", err)
+		return nil, gs.classifyError("snapshot_untracked", err)
 	}
 
-	// Check for conflict markers in the status
-	conflictedFiles := []string{}
-	for filePath := range status {
-		// Read the file content to check for conflict markers
-		fullPath := filepath.Join(gs.repoPath, filePath)
-		content, err := os.ReadFile(fullPath)
+	snapshot := make(map[string][]byte)
+	for filePath, fileStatus := range status {
+		if fileStatus.Worktree != git.Untracked {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(gs.repoPath, filePath))
 		if err != nil {
 			// Skip files we can't read
 			continue
 		}
+		snapshot[filePath] = content
+	}
+
+	return snapshot, nil
+}
+
+// AbortMerge rolls the repository back to the given commit, the equivalent of
+// git merge --abort / git rebase --abort. The index and worktree are hard reset,
+// any merge or rebase state left in .git is removed and the untracked files
+// captured before the pull are written back.
+func (gs *GitService) AbortMerge(commit plumbing.Hash, untracked map[string][]byte) error {
+	if commit.IsZero() {
+		return gs.classifyError("abort_merge", errors.New("no pre-pull commit recorded"))
+	}
+
+	w, err := gs.repository.Worktree()
+	if err != nil {
+		return gs.classifyError("abort_merge", err)
+	}
+
+	err = w.Reset(&git.ResetOptions{
+		Commit: commit,
+		Mode:   git.HardReset,
+	})
+	if err != nil {
+		return gs.classifyError("abort_merge", err)
+	}
+
+	// Clear merge and rebase state left behind by the git CLI
+	gitDir := filepath.Join(gs.repoPath, ".git")
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "AUTO_MERGE", "ORIG_HEAD"} {
+		if err := os.Remove(filepath.Join(gitDir, name)); err != nil && !os.IsNotExist(err) {
+			return gs.classifyError("abort_merge", err)
+		}
+	}
+	for _, name := range []string{"rebase-merge", "rebase-apply"} {
+		if err := os.RemoveAll(filepath.Join(gitDir, name)); err != nil {
+			return gs.classifyError("abort_merge", err)
+		}
+	}
 
-		// Check for standard Git conflict markers
-		fileContent := string(content)
-		if strings.Contains(fileContent, "<<<<<<<") &&
-			strings.Contains(fileContent, "=======") &&
-			strings.Contains(fileContent, ">>>>>>>") {
-			conflictedFiles = append(conflictedFiles, filePath)
+	// Restore the untracked notes exactly as they were before the pull
+	for filePath, content := range untracked {
+		fullPath := filepath.Join(gs.repoPath, filePath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return gs.classifyError("abort_merge", err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return gs.classifyError("abort_merge", err)
 		}
 	}
 
-	return conflictedFiles, nil
+	return nil
 }
 
-// ResolveConflictsWithStrategy resolves git conflicts using the specified strategy
-// strategy can be "ours" or "theirs"
-func (gs *GitService) ResolveConflictsWithStrategy(strategy string) error {
-	if strategy != "ours" && strategy != "theirs" {
-		return fmt.Errorf("invalid conflict resolution strategy: %s (must be 'ours' or 'theirs')", strategy)
+// SetOrigHead records the commit in .git/ORIG_HEAD, as git does before a merge, so
+// the merge can still be aborted after a restart
+func (gs *GitService) SetOrigHead(commit plumbing.Hash) error {
+	origHead := filepath.Join(gs.repoPath, ".git", "ORIG_HEAD")
+	if err := os.WriteFile(origHead, []byte(commit.String()+"\n"), 0644); err != nil {
+		return gs.classifyError("set_orig_head", err)
 	}
+	return nil
+}
 
-	// Use git command to find conflicted files
-	cmdList := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
-	cmdList.Dir = gs.repoPath
-	output, err := cmdList.Output()
+// OrigHead returns the commit recorded in .git/ORIG_HEAD, or the zero hash when
+// there is none
+func (gs *GitService) OrigHead() plumbing.Hash {
+	content, err := os.ReadFile(filepath.Join(gs.repoPath, ".git", "ORIG_HEAD"))
 	if err != nil {
-		return fmt.Errorf("failed to list conflicted files: %w", err)
+		return plumbing.ZeroHash
+	}
+	commit := plumbing.NewHash(strings.TrimSpace(string(content)))
+	if _, err := gs.repository.CommitObject(commit); err != nil {
+		return plumbing.ZeroHash
 	}
+	return commit
+}
 
-	// Parse the output to get the list of conflicted files
-	conflictedFiles := []string{}
-	if len(output) > 0 {
-		conflictedFiles = strings.Split(strings.TrimSpace(string(output)), "\n")
+// MergeInProgress reports whether a merge is waiting to be committed or aborted
+func (gs *GitService) MergeInProgress() bool {
+	_, err := os.Stat(filepath.Join(gs.repoPath, ".git", "MERGE_HEAD"))
+	return err == nil
+}
+
+// DetectConflicts returns the paths of files with unmerged stages in the index
+func (gs *GitService) DetectConflicts() ([]string, error) {
+	conflicts, err := gs.Conflicts()
+	if err != nil {
+		return nil, err
 	}
 
-	if len(conflictedFiles) == 0 {
-		return nil // No conflicts to resolve
+	conflictedFiles := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		conflictedFiles = append(conflictedFiles, conflict.Path)
 	}
 
-	// Prepare git command for checkout
-	cmd := exec.Command("git", "checkout", fmt.Sprintf("--%s", strategy), "--")
-	cmd.Dir = gs.repoPath
+	return conflictedFiles, nil
+}
 
-	// Add conflicted files to the command
-	cmd.Args = append(cmd.Args, conflictedFiles...)
+// ResolveConflictsWithStrategy resolves git conflicts using the specified strategy
+// strategy can be "ours" or "theirs"
+func (gs *GitService) ResolveConflictsWithStrategy(ctx context.Context, strategy string) error {
+	if strategy != ConflictSideOurs && strategy != ConflictSideTheirs {
+		return fmt.Errorf("invalid conflict resolution strategy: %s (must be 'ours' or 'theirs')", strategy)
+	}
 
-	// Execute git checkout command
-	checkoutOutput, err := cmd.CombinedOutput()
+	executor := gs.gitExecutor()
+	conflictedFiles, err := executor.UnmergedFiles(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to execute git checkout --%s: %w\nOutput: %s", strategy, err, string(checkoutOutput))
+		return gs.executorError("resolve_conflicts", err)
+	}
+	if len(conflictedFiles) == 0 {
+		return nil // No conflicts to resolve
+	}
+
+	if err := executor.CheckoutSide(ctx, strategy, conflictedFiles); err != nil {
+		return gs.executorError("resolve_conflicts", err)
 	}
 
 	// Now stage the resolved files
-	cmdAdd := exec.Command("git", "add", "--")
-	cmdAdd.Dir = gs.repoPath
-	cmdAdd.Args = append(cmdAdd.Args, conflictedFiles...)
+	if err := executor.Add(ctx, conflictedFiles); err != nil {
+		return gs.executorError("resolve_conflicts", err)
+	}
+
+	return nil
+}
 
-	addOutput, err := cmdAdd.CombinedOutput()
+// StageConflictedFiles stages the conflicted files as they are in the working tree,
+// conflict markers included, and returns their paths
+func (gs *GitService) StageConflictedFiles(ctx context.Context) ([]string, error) {
+	executor := gs.gitExecutor()
+	conflictedFiles, err := executor.UnmergedFiles(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to stage resolved files: %w\nOutput: %s", err, string(addOutput))
+		return nil, gs.executorError("stage_conflicts", err)
+	}
+	if len(conflictedFiles) == 0 {
+		return conflictedFiles, nil
 	}
 
-	return nil
+	if err := executor.Add(ctx, conflictedFiles); err != nil {
+		return nil, gs.executorError("stage_conflicts", err)
+	}
+
+	return conflictedFiles, nil
+}
+
+// executorError records an executor failure as the last error, keeping its details
+func (gs *GitService) executorError(op string, err error) error {
+	var gitErr *GitError
+	if errors.As(err, &gitErr) {
+		gs.lastError = gitErr
+		return gitErr
+	}
+	return gs.classifyError(op, err)
 }
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCommitWhileIdentityChanges(t *testing.T) {
+	requireGit(t)
+	gitService := cloneTestRemote(t, newTestRemote(t, map[string]string{"note.md": "# Note\n"}))
+	gitService.SetCommitIdentity(CommitIdentity{Name: "Author", Email: "author@example.com"})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			gitService.SetCommitIdentity(CommitIdentity{Name: fmt.Sprintf("Author %d", i), Email: "author@example.com"})
+			gitService.SetCommitSigner(nil)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		writeTestFile(t, filepath.Join(gitService.repoPath, "note.md"), fmt.Sprintf("# Note\n\nedit %d\n", i))
+		if err := gitService.StageChanges(); err != nil {
+			t.Fatalf("stage: %v", err)
+		}
+		if err := gitService.CommitChanges(fmt.Sprintf("Edit %d", i)); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	authors := mustRunGit(t, gitService.repoPath, "log", "-5", "--format=%an <%ae>")
+	for _, author := range strings.Split(authors, "\n") {
+		if !strings.HasPrefix(author, "Author") || !strings.HasSuffix(author, " <author@example.com>") {
+			t.Fatalf("commit author = %q, want one of the identities set", author)
+		}
+	}
+}
+
+func TestStagedChangesPairsRenamesInPathOrder(t *testing.T) {
+	requireGit(t)
+	files := map[string]string{"a.md": "same\n", "b.md": "same\n", "c.md": "same\n", "keep.md": "# Keep\n"}
+
+	// Map iteration order varies, so pair the same files several times
+	for i := 0; i < 10; i++ {
+		gitService := cloneTestRemote(t, newTestRemote(t, files))
+		for _, name := range []string{"a.md", "b.md", "c.md"} {
+			if err := os.Remove(filepath.Join(gitService.repoPath, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range []string{"x.md", "y.md"} {
+			writeTestFile(t, filepath.Join(gitService.repoPath, name), "same\n")
+		}
+		if err := gitService.StageChanges(); err != nil {
+			t.Fatalf("stage: %v", err)
+		}
+
+		changes, err := gitService.StagedChanges()
+		if err != nil {
+			t.Fatalf("staged changes: %v", err)
+		}
+		want := []CommitFileChange{
+			{Action: ChangeDeleted, Path: "c.md"},
+			{Action: ChangeRenamed, Path: "x.md", OldPath: "a.md"},
+			{Action: ChangeRenamed, Path: "y.md", OldPath: "b.md"},
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Fatalf("staged changes = %+v, want %+v", changes, want)
+		}
+	}
+}
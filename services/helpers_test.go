@@ -0,0 +1,131 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testSignature is the author of commits made by tests
+var testSignature = &object.Signature{Name: "Test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+
+// requireGit skips tests that need the git binary when it isn't installed
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+}
+
+// initTestRepo creates a repository with one commit holding the files
+func initTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("init repository: %v", err)
+	}
+	commitTestFiles(t, repo, dir, files, "Initial commit")
+	return dir
+}
+
+// commitTestFiles writes files into a repository and commits them
+func commitTestFiles(t *testing.T, repo *git.Repository, dir string, files map[string]string, message string) {
+	t.Helper()
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("open worktree: %v", err)
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+		if _, err := w.Add(name); err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+	}
+	if _, err := w.Commit(message, &git.CommitOptions{Author: testSignature}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+}
+
+// writeTestFile writes a file, creating its directory
+func writeTestFile(t *testing.T, filePath string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", filePath, err)
+	}
+}
+
+// readTestFile returns the content of a file
+func readTestFile(t *testing.T, filePath string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("read %s: %v", filePath, err)
+	}
+	return string(content)
+}
+
+// runGit runs the git binary in dir as the test author and returns its combined output
+func runGit(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+testSignature.Name, "GIT_AUTHOR_EMAIL="+testSignature.Email,
+		"GIT_COMMITTER_NAME="+testSignature.Name, "GIT_COMMITTER_EMAIL="+testSignature.Email,
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir())
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// mustRunGit runs the git binary in dir and fails the test when it fails
+func mustRunGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	output, err := runGit(t, dir, args...)
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(output)
+}
+
+// newTestRemote creates a bare repository with one commit holding the files
+func newTestRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	remoteDir := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainClone(remoteDir, true, &git.CloneOptions{URL: initTestRepo(t, files)}); err != nil {
+		t.Fatalf("create remote: %v", err)
+	}
+	return remoteDir
+}
+
+// cloneTestRemote clones the remote and returns a GitService for the clone
+func cloneTestRemote(t *testing.T, remoteDir string) *GitService {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := git.PlainClone(dir, false, &git.CloneOptions{URL: remoteDir}); err != nil {
+		t.Fatalf("clone remote: %v", err)
+	}
+	gitService, err := NewGitService(dir, remoteDir)
+	if err != nil {
+		t.Fatalf("open clone: %v", err)
+	}
+	gitService.SetCommitIdentity(CommitIdentity{Name: testSignature.Name, Email: testSignature.Email})
+	return gitService
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Link styles
+const (
+	LinkKindWiki     = "wiki"     // [[Note Name]]
+	LinkKindMarkdown = "markdown" // [text](relative/path.md)
+)
+
+var (
+	// [[Note]], [[Note#Heading]], [[Note|Alias]] and embeds like ![[Note]]
+	wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|#\n]*)(#[^\[\]|\n]*)?(\|[^\[\]\n]*)?\]\]`)
+	// [text](target) and [text](<target with spaces> "title")
+	markdownLinkPattern = regexp.MustCompile(`\]\((<[^>\n]+>|[^)\s]+)(?:\s+"[^"\n]*")?\)`)
+	// Inline code spans, which never contain links
+	inlineCodePattern = regexp.MustCompile("`[^`\n]*`")
+	// URL schemes such as https: and mailto:
+	urlSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// NoteLink is a link from one note to another
+type NoteLink struct {
+	Source   string `json:"source"`            // Slash-separated path of the linking note
+	Target   string `json:"target,omitempty"`  // Resolved path of the linked note, empty when unresolved
+	Raw      string `json:"raw"`               // Link target as written
+	Kind     string `json:"kind"`              // LinkKindWiki or LinkKindMarkdown
+	Heading  string `json:"heading,omitempty"` // Heading or fragment the link points to
+	Line     int    `json:"line"`              // 1-based line of the link in the source
+	Resolved bool   `json:"resolved"`
+}
+
+// LinkGraphNode is a note in the exported link graph
+type LinkGraphNode struct {
+	ID       string `json:"id"` // Slash-separated path, or the raw target of a missing note
+	Title    string `json:"title"`
+	Incoming int    `json:"incoming"`
+	Outgoing int    `json:"outgoing"`
+	Missing  bool   `json:"missing,omitempty"` // Linked to but not in the vault
+}
+
+// LinkGraphEdge is a link between two notes, counted once per source and target
+type LinkGraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Count  int    `json:"count"`
+}
+
+// LinkGraphExport is the whole link graph of a vault
+type LinkGraphExport struct {
+	Nodes []LinkGraphNode `json:"nodes"`
+	Edges []LinkGraphEdge `json:"edges"`
+}
+
+// noteLinks holds the links parsed from a note, before resolution
+type noteLinks struct {
+	Title string
+	Links []NoteLink
+}
+
+// LinkGraph tracks the links between the notes of a vault. It is built on first use
+// and kept up to date through FilesChanged. Wiki links resolve against the note set,
+// so resolved links are recomputed whenever notes are added or removed.
+type LinkGraph struct {
+	root     string
+	mu       sync.Mutex
+	loaded   bool
+	notes    map[string]*noteLinks // By slash-separated relative path
+	byName   map[string][]string   // Lowercased base name without extension -> paths
+	resolved map[string][]NoteLink // Resolved outgoing links, nil when stale
+	incoming map[string][]NoteLink // Resolved links by target, nil when stale
+}
+
+// NewLinkGraph creates a link graph for the notes under root
+func NewLinkGraph(root string) *LinkGraph {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+
+	return &LinkGraph{
+		root:   absRoot,
+		notes:  make(map[string]*noteLinks),
+		byName: make(map[string][]string),
+	}
+}
+
+// Refresh parses every note in the vault again
+func (lg *LinkGraph) Refresh() error {
+	notes := make(map[string]*noteLinks)
+	err := filepath.WalkDir(lg.root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if filePath != lg.root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownPath(filePath) {
+			return nil
+		}
+
+		relPath, ok := relativeVaultPath(lg.root, filePath)
+		if !ok {
+			return nil
+		}
+		links, err := parseNoteLinks(filePath, relPath)
+		if err != nil {
+			fmt.Printf("Warning: Unable to read links of %s: %v\n", relPath, err)
+			return nil
+		}
+		notes[relPath] = links
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scanning vault: %w", err)
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.notes = make(map[string]*noteLinks, len(notes))
+	lg.byName = make(map[string][]string, len(notes))
+	for relPath, links := range notes {
+		lg.putNoteLocked(relPath, links)
+	}
+	lg.loaded = true
+	return nil
+}
+
+// FilesChanged updates the links of written, created and deleted notes
+func (lg *LinkGraph) FilesChanged(paths []string) {
+	lg.mu.Lock()
+	loaded := lg.loaded
+	lg.mu.Unlock()
+
+	// Nothing to update until the graph is first used
+	if !loaded {
+		return
+	}
+
+	for _, filePath := range paths {
+		relPath, ok := relativeVaultPath(lg.root, filePath)
+		if !ok {
+			continue
+		}
+
+		info, err := os.Stat(filePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			lg.removePath(relPath)
+		case err != nil:
+			fmt.Printf("Warning: Unable to read links of %s: %v\n", relPath, err)
+		case info.IsDir():
+			// A directory appeared, e.g. restored by a pull. Pick up its notes.
+			if err := lg.Refresh(); err != nil {
+				fmt.Printf("Warning: Unable to refresh link graph: %v\n", err)
+			}
+		case isMarkdownPath(filePath):
+			links, err := parseNoteLinks(filePath, relPath)
+			if err != nil {
+				fmt.Printf("Warning: Unable to read links of %s: %v\n", relPath, err)
+				continue
+			}
+			lg.mu.Lock()
+			lg.putNoteLocked(relPath, links)
+			lg.mu.Unlock()
+		}
+	}
+}
+
+// OutgoingLinks returns the links in a note, in document order
+func (lg *LinkGraph) OutgoingLinks(filePath string) ([]NoteLink, error) {
+	relPath, err := lg.notePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.resolveLocked()
+	return append([]NoteLink{}, lg.resolved[relPath]...), nil
+}
+
+// Backlinks returns the links pointing to a note from other notes
+func (lg *LinkGraph) Backlinks(filePath string) ([]NoteLink, error) {
+	relPath, err := lg.notePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.resolveLocked()
+	backlinks := make([]NoteLink, 0, len(lg.incoming[relPath]))
+	for _, link := range lg.incoming[relPath] {
+		if link.Source != relPath {
+			backlinks = append(backlinks, link)
+		}
+	}
+	return backlinks, nil
+}
+
+// Orphans returns the notes that neither link to nor are linked from another note
+func (lg *LinkGraph) Orphans() ([]string, error) {
+	if err := lg.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.resolveLocked()
+	linked := make(map[string]bool)
+	for source, links := range lg.resolved {
+		for _, link := range links {
+			if link.Resolved && link.Target != source {
+				linked[source] = true
+				linked[link.Target] = true
+			}
+		}
+	}
+
+	orphans := make([]string, 0)
+	for relPath := range lg.notes {
+		if !linked[relPath] {
+			orphans = append(orphans, relPath)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// Export returns every note and the links between them. Unresolved links appear as
+// edges to missing nodes named after the link target.
+func (lg *LinkGraph) Export() (LinkGraphExport, error) {
+	if err := lg.ensureLoaded(); err != nil {
+		return LinkGraphExport{}, err
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.resolveLocked()
+	nodes := make(map[string]*LinkGraphNode, len(lg.notes))
+	for relPath, links := range lg.notes {
+		nodes[relPath] = &LinkGraphNode{ID: relPath, Title: links.Title}
+	}
+
+	edgeCounts := make(map[[2]string]int)
+	for source, links := range lg.resolved {
+		for _, link := range links {
+			// Links to headings within the same note aren't edges
+			if link.Resolved && link.Target == source {
+				continue
+			}
+
+			target := link.Target
+			if !link.Resolved {
+				target = link.Raw
+				if _, ok := nodes[target]; !ok {
+					nodes[target] = &LinkGraphNode{ID: target, Title: noteTitleFromPath(target), Missing: true}
+				}
+			}
+			edgeCounts[[2]string{source, target}]++
+		}
+	}
+
+	export := LinkGraphExport{
+		Nodes: make([]LinkGraphNode, 0, len(nodes)),
+		Edges: make([]LinkGraphEdge, 0, len(edgeCounts)),
+	}
+	for edge, count := range edgeCounts {
+		nodes[edge[0]].Outgoing++
+		nodes[edge[1]].Incoming++
+		export.Edges = append(export.Edges, LinkGraphEdge{Source: edge[0], Target: edge[1], Count: count})
+	}
+	for _, node := range nodes {
+		export.Nodes = append(export.Nodes, *node)
+	}
+
+	sort.Slice(export.Nodes, func(i, j int) bool { return export.Nodes[i].ID < export.Nodes[j].ID })
+	sort.Slice(export.Edges, func(i, j int) bool {
+		if export.Edges[i].Source != export.Edges[j].Source {
+			return export.Edges[i].Source < export.Edges[j].Source
+		}
+		return export.Edges[i].Target < export.Edges[j].Target
+	})
+	return export, nil
+}
+
+// linksAffectedBy returns the resolved links from or to any of the notes
+func (lg *LinkGraph) linksAffectedBy(relPaths map[string]bool) ([]NoteLink, error) {
+	if err := lg.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.resolveLocked()
+	links := make([]NoteLink, 0)
+	for source, sourceLinks := range lg.resolved {
+		for _, link := range sourceLinks {
+			if link.Resolved && (relPaths[source] || relPaths[link.Target]) {
+				links = append(links, link)
+			}
+		}
+	}
+	return links, nil
+}
+
+// wikiLinkName returns the shortest wiki link name that resolves from the source note
+// to the target: its base name, or its full path when the name is ambiguous
+func (lg *LinkGraph) wikiLinkName(source, target string) string {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	name := noteTitleFromPath(target)
+	if resolved, ok := lg.resolveWikiLocked(source, name); ok && resolved == target {
+		return name
+	}
+	return strings.TrimSuffix(target, path.Ext(target))
+}
+
+// notePath converts a note path into its graph key, building the graph if needed
+func (lg *LinkGraph) notePath(filePath string) (string, error) {
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(lg.root, filepath.FromSlash(filePath))
+	}
+	relPath, ok := relativeVaultPath(lg.root, filePath)
+	if !ok {
+		return "", errors.New("invalid file path")
+	}
+
+	if err := lg.ensureLoaded(); err != nil {
+		return "", err
+	}
+	return relPath, nil
+}
+
+// ensureLoaded builds the graph on first use
+func (lg *LinkGraph) ensureLoaded() error {
+	lg.mu.Lock()
+	loaded := lg.loaded
+	lg.mu.Unlock()
+
+	if loaded {
+		return nil
+	}
+	return lg.Refresh()
+}
+
+// removePath removes a note, or every note under a deleted directory
+func (lg *LinkGraph) removePath(relPath string) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	lg.removeNoteLocked(relPath)
+	dirPrefix := relPath + "/"
+	for notePath := range lg.notes {
+		if strings.HasPrefix(notePath, dirPrefix) {
+			lg.removeNoteLocked(notePath)
+		}
+	}
+}
+
+// putNoteLocked adds or replaces the links of a note
+func (lg *LinkGraph) putNoteLocked(relPath string, links *noteLinks) {
+	if _, ok := lg.notes[relPath]; !ok {
+		name := noteNameKey(relPath)
+		lg.byName[name] = append(lg.byName[name], relPath)
+	}
+	lg.notes[relPath] = links
+	lg.resolved = nil
+	lg.incoming = nil
+}
+
+// removeNoteLocked removes the links of a note
+func (lg *LinkGraph) removeNoteLocked(relPath string) {
+	if _, ok := lg.notes[relPath]; !ok {
+		return
+	}
+	delete(lg.notes, relPath)
+
+	name := noteNameKey(relPath)
+	paths := lg.byName[name]
+	for i, candidate := range paths {
+		if candidate == relPath {
+			paths = append(paths[:i], paths[i+1:]...)
+			break
+		}
+	}
+	if len(paths) == 0 {
+		delete(lg.byName, name)
+	} else {
+		lg.byName[name] = paths
+	}
+
+	lg.resolved = nil
+	lg.incoming = nil
+}
+
+// resolveLocked resolves every link if the graph changed since the last resolution
+func (lg *LinkGraph) resolveLocked() {
+	if lg.resolved != nil {
+		return
+	}
+
+	lg.resolved = make(map[string][]NoteLink, len(lg.notes))
+	lg.incoming = make(map[string][]NoteLink)
+	for source, links := range lg.notes {
+		resolved := make([]NoteLink, 0, len(links.Links))
+		for _, link := range links.Links {
+			link.Target, link.Resolved = lg.resolveTargetLocked(source, link)
+			resolved = append(resolved, link)
+			if link.Resolved {
+				lg.incoming[link.Target] = append(lg.incoming[link.Target], link)
+			}
+		}
+		lg.resolved[source] = resolved
+	}
+
+	// Backlinks are listed by source note and position
+	for _, links := range lg.incoming {
+		sort.Slice(links, func(i, j int) bool {
+			if links[i].Source != links[j].Source {
+				return links[i].Source < links[j].Source
+			}
+			return links[i].Line < links[j].Line
+		})
+	}
+}
+
+// resolveTargetLocked resolves a link to the path of a note in the vault
+func (lg *LinkGraph) resolveTargetLocked(source string, link NoteLink) (string, bool) {
+	switch link.Kind {
+	case LinkKindWiki:
+		return lg.resolveWikiLocked(source, link.Raw)
+	case LinkKindMarkdown:
+		return lg.resolveRelativeLocked(source, link.Raw)
+	}
+	return "", false
+}
+
+// resolveWikiLocked resolves a wiki link by note name. A name with a folder must match
+// the end of the note's path. When several notes share the name, the one closest to
+// the linking note wins.
+func (lg *LinkGraph) resolveWikiLocked(source, name string) (string, bool) {
+	name = strings.Trim(strings.TrimSpace(name), "/")
+	if name == "" {
+		// [[#Heading]] links within the same note
+		return source, true
+	}
+
+	key := strings.ToLower(name)
+	if isMarkdownPath(key) {
+		key = strings.TrimSuffix(key, path.Ext(key))
+	}
+
+	candidates := make([]string, 0, 1)
+	for _, candidate := range lg.byName[path.Base(key)] {
+		withoutExt := strings.ToLower(strings.TrimSuffix(candidate, path.Ext(candidate)))
+		if withoutExt == key || strings.HasSuffix(withoutExt, "/"+key) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sourceDir := path.Dir(source)
+	sort.Slice(candidates, func(i, j int) bool {
+		iLocal, jLocal := path.Dir(candidates[i]) == sourceDir, path.Dir(candidates[j]) == sourceDir
+		if iLocal != jLocal {
+			return iLocal
+		}
+		if len(candidates[i]) != len(candidates[j]) {
+			return len(candidates[i]) < len(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	return candidates[0], true
+}
+
+// resolveRelativeLocked resolves a Markdown link relative to the linking note, or to
+// the vault root when it starts with a slash. The .md extension may be left out.
+func (lg *LinkGraph) resolveRelativeLocked(source, target string) (string, bool) {
+	var resolved string
+	if strings.HasPrefix(target, "/") {
+		resolved = path.Clean(strings.TrimPrefix(target, "/"))
+	} else {
+		resolved = path.Join(path.Dir(source), target)
+	}
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+
+	if _, ok := lg.notes[resolved]; ok {
+		return resolved, true
+	}
+	if path.Ext(resolved) == "" {
+		if _, ok := lg.notes[resolved+".md"]; ok {
+			return resolved + ".md", true
+		}
+	}
+	return "", false
+}
+
+// parseNoteLinks reads a note and extracts its title and links
+func parseNoteLinks(filePath, relPath string) (*noteLinks, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	title := firstHeading(content)
+	if title == "" {
+		title = noteTitleFromPath(relPath)
+	}
+	return &noteLinks{Title: title, Links: extractLinks(relPath, string(content))}, nil
+}
+
+// extractLinks finds the wiki and relative Markdown links in note content, skipping
+// code blocks, inline code and external URLs
+func extractLinks(source, content string) []NoteLink {
+	links := make([]NoteLink, 0)
+	inFence := false
+	fence := ""
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inFence {
+			if strings.HasPrefix(trimmed, fence) {
+				inFence = false
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = true
+			fence = trimmed[:3]
+			continue
+		}
+
+		line = inlineCodePattern.ReplaceAllStringFunc(line, func(code string) string {
+			return strings.Repeat(" ", len(code))
+		})
+
+		for _, match := range wikiLinkPattern.FindAllStringSubmatch(line, -1) {
+			links = append(links, NoteLink{
+				Source:  source,
+				Raw:     strings.TrimSpace(match[1]),
+				Kind:    LinkKindWiki,
+				Heading: strings.TrimSpace(strings.TrimPrefix(match[2], "#")),
+				Line:    i + 1,
+			})
+		}
+
+		for _, match := range markdownLinkPattern.FindAllStringSubmatch(line, -1) {
+			target := strings.TrimSuffix(strings.TrimPrefix(match[1], "<"), ">")
+			if urlSchemePattern.MatchString(target) || strings.HasPrefix(target, "#") {
+				continue
+			}
+
+			heading := ""
+			if idx := strings.Index(target, "#"); idx >= 0 {
+				target, heading = target[:idx], target[idx+1:]
+			}
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+
+			// Only links to notes are part of the graph, not images and attachments
+			if ext := path.Ext(target); ext != "" && !isMarkdownPath(target) {
+				continue
+			}
+
+			links = append(links, NoteLink{
+				Source:  source,
+				Raw:     target,
+				Kind:    LinkKindMarkdown,
+				Heading: heading,
+				Line:    i + 1,
+			})
+		}
+	}
+	return links
+}
+
+// noteNameKey returns the lowercased base name without extension that wiki links use
+func noteNameKey(relPath string) string {
+	base := strings.ToLower(path.Base(relPath))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// noteTitleFromPath returns the base name of a note without its extension
+func noteTitleFromPath(relPath string) string {
+	base := path.Base(relPath)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// List items: -, * and + bullets, numbered items and task list items
+	listItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s`)
+	// Front matter keys start at the beginning of the line
+	frontMatterKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\s*:`)
+)
+
+// MergeDriver merges the versions of a file both sides of a sync changed
+type MergeDriver interface {
+	// Merge returns the merged content and true, or false when the changes conflict.
+	// A side is nil when it has no version of the file, e.g. base when both added it.
+	Merge(path string, base, ours, theirs []byte) ([]byte, bool)
+}
+
+// MarkdownMergeDriver merges Markdown notes block by block. Headings, list items,
+// paragraphs, code blocks and front matter keys are merged against the common
+// ancestor, so edits to different blocks and items added to the same list by
+// both sides merge cleanly. Only a block changed on both sides conflicts.
+type MarkdownMergeDriver struct{}
+
+// NewMarkdownMergeDriver creates a Markdown merge driver
+func NewMarkdownMergeDriver() *MarkdownMergeDriver {
+	return &MarkdownMergeDriver{}
+}
+
+// Merge merges a Markdown note. Other files and notes both sides created are left
+// to the conflict strategy.
+func (d *MarkdownMergeDriver) Merge(path string, base, ours, theirs []byte) ([]byte, bool) {
+	if !isMarkdownPath(path) || base == nil || ours == nil || theirs == nil {
+		return nil, false
+	}
+	if isBinaryContent(base) || isBinaryContent(ours) || isBinaryContent(theirs) {
+		return nil, false
+	}
+
+	// A missing final newline would make the last block differ from the same block
+	// followed by new ones
+	baseBlocks := markdownBlocks(withFinalNewline(string(base)))
+	merged, ok := mergeBlocks(baseBlocks,
+		blockChanges(baseBlocks, markdownBlocks(withFinalNewline(string(ours)))),
+		blockChanges(baseBlocks, markdownBlocks(withFinalNewline(string(theirs)))))
+	if !ok {
+		return nil, false
+	}
+
+	result := strings.Join(merged, "")
+	if !strings.HasSuffix(string(ours), "\n") && !strings.HasSuffix(string(theirs), "\n") {
+		result = strings.TrimSuffix(result, "\n")
+	}
+	return []byte(result), true
+}
+
+// blockChange replaces the base blocks from start to end with new blocks. An insertion
+// has start equal to end.
+type blockChange struct {
+	start, end int
+	blocks     []string
+	theirs     bool
+}
+
+// blockChanges lists the changes one side made to the base blocks
+func blockChanges(base, side []string) []blockChange {
+	matches := matchTokens(base, side)
+
+	changes := make([]blockChange, 0)
+	i, j := 0, 0
+	for {
+		start := i
+		for i < len(base) && matches[i] < 0 {
+			i++
+		}
+		sideEnd := len(side)
+		if i < len(base) {
+			sideEnd = matches[i]
+		}
+		if i > start || sideEnd > j {
+			changes = append(changes, blockChange{start: start, end: i, blocks: side[j:sideEnd]})
+		}
+		if i == len(base) {
+			return changes
+		}
+		j = matches[i] + 1
+		i++
+	}
+}
+
+// mergeBlocks applies both sides' changes to the base blocks. Changes to different
+// blocks merge, even next to each other. It fails when both sides changed the same
+// block differently.
+func mergeBlocks(base []string, ours, theirs []blockChange) ([]string, bool) {
+	for i := range theirs {
+		theirs[i].theirs = true
+	}
+	changes := append(ours, theirs...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].start != changes[j].start {
+			return changes[i].start < changes[j].start
+		}
+		return changes[i].end < changes[j].end
+	})
+
+	merged := make([]string, 0, len(base))
+	pos := 0
+	var prev *blockChange
+	for i := range changes {
+		change := &changes[i]
+		if prev != nil && prev.theirs != change.theirs {
+			sameChange := prev.start == change.start && prev.end == change.end &&
+				strings.Join(prev.blocks, "") == strings.Join(change.blocks, "")
+			if sameChange {
+				continue
+			}
+
+			// Blocks both sides inserted at the same place, such as new list items,
+			// are kept in order: ours first, then theirs
+			if prev.start == prev.end && change.start == change.end && prev.start == change.start {
+				merged = append(merged, insertedOnlyByTheirs(prev.blocks, change.blocks)...)
+				continue
+			}
+			if change.start < prev.end {
+				return nil, false
+			}
+		}
+
+		merged = append(merged, base[pos:change.start]...)
+		merged = append(merged, change.blocks...)
+		pos = change.end
+		prev = change
+	}
+
+	return append(merged, base[pos:]...), true
+}
+
+// insertedOnlyByTheirs returns the blocks theirs inserted that ours didn't also insert
+func insertedOnlyByTheirs(ours, theirs []string) []string {
+	oursBlocks := make(map[string]bool, len(ours))
+	for _, block := range ours {
+		oursBlocks[block] = true
+	}
+
+	blocks := make([]string, 0, len(theirs))
+	for _, block := range theirs {
+		if !oursBlocks[block] {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// markdownBlocks splits a note into blocks that concatenate back to the note: front
+// matter delimiters and keys, headings, list items with their continuation lines,
+// paragraphs, fenced code blocks and runs of blank lines
+func markdownBlocks(text string) []string {
+	lines := splitLines(text)
+	blocks := make([]string, 0, len(lines))
+
+	i := 0
+
+	// Front matter, one block per key including its indented values
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		end := -1
+		for j := 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "---" {
+				end = j
+				break
+			}
+		}
+		if end > 0 {
+			blocks = append(blocks, lines[0])
+			for j := 1; j < end; {
+				k := j + 1
+				for k < end && !frontMatterKeyPattern.MatchString(lines[k]) {
+					k++
+				}
+				blocks = append(blocks, strings.Join(lines[j:k], ""))
+				j = k
+			}
+			blocks = append(blocks, lines[end])
+			i = end + 1
+		}
+	}
+
+	for i < len(lines) {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		j := i + 1
+
+		switch {
+		case trimmed == "":
+			for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+				j++
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				j++
+			}
+			if j < len(lines) {
+				j++
+			}
+		case strings.HasPrefix(trimmed, "#"):
+			// A heading is a block of its own
+		case listItemPattern.MatchString(line):
+			// Continuation lines are indented deeper than the item and aren't items themselves
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			for j < len(lines) {
+				next := lines[j]
+				nextIndent := len(next) - len(strings.TrimLeft(next, " \t"))
+				if strings.TrimSpace(next) == "" || nextIndent <= indent || listItemPattern.MatchString(next) {
+					break
+				}
+				j++
+			}
+		default:
+			// A paragraph runs until a blank line or the start of another block
+			for j < len(lines) {
+				next := strings.TrimSpace(lines[j])
+				if next == "" || strings.HasPrefix(next, "#") || strings.HasPrefix(next, "```") ||
+					strings.HasPrefix(next, "~~~") || listItemPattern.MatchString(lines[j]) {
+					break
+				}
+				j++
+			}
+		}
+
+		blocks = append(blocks, strings.Join(lines[i:j], ""))
+		i = j
+	}
+
+	return blocks
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: []string{}},
+		{
+			name: "heading and paragraphs",
+			text: "# Title\nfirst line\nsecond line\n\n\nnext paragraph\n",
+			want: []string{"# Title\n", "first line\nsecond line\n", "\n\n", "next paragraph\n"},
+		},
+		{
+			name: "list items with continuation lines",
+			text: "- one\n  more of one\n- two\n1. three\n- [ ] task\n",
+			want: []string{"- one\n  more of one\n", "- two\n", "1. three\n", "- [ ] task\n"},
+		},
+		{
+			name: "paragraph ends at a list",
+			text: "intro\n- item\n",
+			want: []string{"intro\n", "- item\n"},
+		},
+		{
+			name: "fenced code keeps blank lines and markers",
+			text: "```go\n# not a heading\n\n- not an item\n```\nafter\n",
+			want: []string{"```go\n# not a heading\n\n- not an item\n```\n", "after\n"},
+		},
+		{
+			name: "unclosed fence runs to the end",
+			text: "~~~\ncode\n",
+			want: []string{"~~~\ncode\n"},
+		},
+		{
+			name: "front matter keys",
+			text: "---\ntitle: Note\ntags:\n  - a\n  - b\n---\nbody\n",
+			want: []string{"---\n", "title: Note\n", "tags:\n  - a\n  - b\n", "---\n", "body\n"},
+		},
+		{
+			name: "unclosed front matter is a paragraph",
+			text: "---\ntitle: Note\n",
+			want: []string{"---\ntitle: Note\n"},
+		},
+		{
+			name: "missing final newline",
+			text: "# Title\n- item",
+			want: []string{"# Title\n", "- item"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownBlocks(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("markdownBlocks(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockChanges(t *testing.T) {
+	base := []string{"a\n", "b\n", "c\n"}
+
+	tests := []struct {
+		name string
+		side []string
+		want []blockChange
+	}{
+		{name: "unchanged", side: []string{"a\n", "b\n", "c\n"}, want: []blockChange{}},
+		{
+			name: "insert at the start",
+			side: []string{"new\n", "a\n", "b\n", "c\n"},
+			want: []blockChange{{start: 0, end: 0, blocks: []string{"new\n"}}},
+		},
+		{
+			name: "append",
+			side: []string{"a\n", "b\n", "c\n", "new\n"},
+			want: []blockChange{{start: 3, end: 3, blocks: []string{"new\n"}}},
+		},
+		{
+			name: "modify",
+			side: []string{"a\n", "B\n", "c\n"},
+			want: []blockChange{{start: 1, end: 2, blocks: []string{"B\n"}}},
+		},
+		{
+			name: "delete",
+			side: []string{"a\n", "c\n"},
+			want: []blockChange{{start: 1, end: 2, blocks: []string{}}},
+		},
+		{
+			name: "separate changes",
+			side: []string{"A\n", "b\n", "c\n", "d\n"},
+			want: []blockChange{
+				{start: 0, end: 1, blocks: []string{"A\n"}},
+				{start: 3, end: 3, blocks: []string{"d\n"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockChanges(base, tt.side); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("blockChanges = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeBlocks(t *testing.T) {
+	base := []string{"- a\n", "- b\n", "- c\n"}
+
+	tests := []struct {
+		name         string
+		ours, theirs []string
+		want         []string // nil when the changes conflict
+	}{
+		{
+			name:   "inserts at the same position",
+			ours:   []string{"- a\n", "- b\n", "- c\n", "- ours\n"},
+			theirs: []string{"- a\n", "- b\n", "- c\n", "- theirs\n"},
+			want:   []string{"- a\n", "- b\n", "- c\n", "- ours\n", "- theirs\n"},
+		},
+		{
+			name:   "same insert on both sides",
+			ours:   []string{"- a\n", "- new\n", "- b\n", "- c\n"},
+			theirs: []string{"- a\n", "- new\n", "- b\n", "- c\n"},
+			want:   []string{"- a\n", "- new\n", "- b\n", "- c\n"},
+		},
+		{
+			name:   "overlapping inserts at the same position",
+			ours:   []string{"- a\n", "- both\n", "- ours\n", "- b\n", "- c\n"},
+			theirs: []string{"- a\n", "- both\n", "- theirs\n", "- b\n", "- c\n"},
+			want:   []string{"- a\n", "- both\n", "- ours\n", "- theirs\n", "- b\n", "- c\n"},
+		},
+		{
+			name:   "adjacent block edits",
+			ours:   []string{"- A\n", "- b\n", "- c\n"},
+			theirs: []string{"- a\n", "- B\n", "- c\n"},
+			want:   []string{"- A\n", "- B\n", "- c\n"},
+		},
+		{
+			name:   "same edit on both sides",
+			ours:   []string{"- a\n", "- B\n", "- c\n"},
+			theirs: []string{"- a\n", "- B\n", "- c\n"},
+			want:   []string{"- a\n", "- B\n", "- c\n"},
+		},
+		{
+			name:   "different edits of a block",
+			ours:   []string{"- a\n", "- ours\n", "- c\n"},
+			theirs: []string{"- a\n", "- theirs\n", "- c\n"},
+		},
+		{
+			name:   "delete against modify",
+			ours:   []string{"- a\n", "- c\n"},
+			theirs: []string{"- a\n", "- B\n", "- c\n"},
+		},
+		{
+			name:   "modify against delete",
+			ours:   []string{"- a\n", "- B\n", "- c\n"},
+			theirs: []string{"- a\n", "- c\n"},
+		},
+		{
+			name:   "delete next to a modify",
+			ours:   []string{"- a\n", "- c\n"},
+			theirs: []string{"- a\n", "- b\n", "- C\n"},
+			want:   []string{"- a\n", "- C\n"},
+		},
+		{
+			name:   "delete on both sides",
+			ours:   []string{"- a\n", "- c\n"},
+			theirs: []string{"- a\n", "- c\n"},
+			want:   []string{"- a\n", "- c\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mergeBlocks(base, blockChanges(base, tt.ours), blockChanges(base, tt.theirs))
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("mergeBlocks = %q, want a conflict", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("mergeBlocks conflicted, want a clean merge")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("mergeBlocks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownMergeDriver(t *testing.T) {
+	frontMatter := "---\ntitle: Note\ntags: [a]\n---\n# Note\n"
+
+	tests := []struct {
+		name               string
+		path               string
+		base, ours, theirs *string
+		want               string
+		ok                 bool
+	}{
+		{
+			name: "paragraphs edited on each side",
+			path: "note.md",
+			base: version("# Note\n\nfirst\n\nsecond\n"), ours: version("# Note\n\nfirst, ours\n\nsecond\n"), theirs: version("# Note\n\nfirst\n\nsecond, theirs\n"),
+			want: "# Note\n\nfirst, ours\n\nsecond, theirs\n", ok: true,
+		},
+		{
+			name: "items appended to the same list",
+			path: "todo.md",
+			base: version("- a\n"), ours: version("- a\n- ours\n"), theirs: version("- a\n- theirs\n"),
+			want: "- a\n- ours\n- theirs\n", ok: true,
+		},
+		{
+			name: "different front matter keys",
+			path: "note.md",
+			base: version(frontMatter), ours: version("---\ntitle: Ours\ntags: [a]\n---\n# Note\n"), theirs: version("---\ntitle: Note\ntags: [a, b]\n---\n# Note\n"),
+			want: "---\ntitle: Ours\ntags: [a, b]\n---\n# Note\n", ok: true,
+		},
+		{
+			name: "same front matter key",
+			path: "note.md",
+			base: version(frontMatter), ours: version("---\ntitle: Ours\ntags: [a]\n---\n# Note\n"), theirs: version("---\ntitle: Theirs\ntags: [a]\n---\n# Note\n"),
+		},
+		{
+			name: "item added after a last line without newline",
+			path: "todo.md",
+			base: version("- a\n- b"), ours: version("- a\n- b\n- c"), theirs: version("- A\n- b"),
+			want: "- A\n- b\n- c", ok: true,
+		},
+		{
+			name: "one side adds the final newline",
+			path: "todo.md",
+			base: version("- a\n- b"), ours: version("- a\n- b\n"), theirs: version("- A\n- b"),
+			want: "- A\n- b\n", ok: true,
+		},
+		{
+			name: "delete against modify",
+			path: "note.md",
+			base: version("# Note\n\nold\n"), ours: version("# Note\n"), theirs: version("# Note\n\nnew\n"),
+		},
+		{
+			name: "not a note",
+			path: "data.txt",
+			base: version("a\n"), ours: version("a\nb\n"), theirs: version("c\na\n"),
+		},
+		{
+			name: "added on both sides",
+			path: "note.md",
+			ours: version("# Ours\n"), theirs: version("# Theirs\n"),
+		},
+	}
+
+	driver := NewMarkdownMergeDriver()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := driver.Merge(tt.path, versionBytes(tt.base), versionBytes(tt.ours), versionBytes(tt.theirs))
+			if ok != tt.ok {
+				t.Fatalf("Merge = %q, %v, want ok %v", got, ok, tt.ok)
+			}
+			if ok && string(got) != tt.want {
+				t.Fatalf("Merge = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// version returns a pointer to s, for versions that may be missing
+func version(s string) *string {
+	return &s
+}
+
+// versionBytes returns the content of a version, nil when it is missing
+func versionBytes(s *string) []byte {
+	if s == nil {
+		return nil
+	}
+	return []byte(*s)
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// mergeChunk is a part of a three-way merge: text that merged cleanly, or a conflict
+// between the two sides with the common ancestor's text
+type mergeChunk struct {
+	Conflict bool
+	Text     string // Merged text, when there is no conflict
+	Base     string
+	Ours     string
+	Theirs   string
+}
+
+// splitLines splits text into lines, keeping the line endings so joining them restores the text
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchTokens pairs up the tokens two sequences have in common, using their longest
+// common subsequence. It returns, for each token of a, the index of its match in b or -1.
+func matchTokens(a, b []string) []int {
+	tokenRunes := make(map[string]rune)
+	encode := func(tokens []string) []rune {
+		encoded := make([]rune, len(tokens))
+		for i, token := range tokens {
+			r, ok := tokenRunes[token]
+			if !ok {
+				r = tokenRune(len(tokenRunes))
+				tokenRunes[token] = r
+			}
+			encoded[i] = r
+		}
+		return encoded
+	}
+	aRunes, bRunes := encode(a), encode(b)
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = wordDiffTimeout
+	diffs := dmp.DiffMainRunes(aRunes, bRunes, false)
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for x := 0; x < n; x++ {
+				matches[i] = j
+				i++
+				j++
+			}
+		case diffmatchpatch.DiffDelete:
+			for x := 0; x < n; x++ {
+				matches[i] = -1
+				i++
+			}
+		case diffmatchpatch.DiffInsert:
+			j += n
+		}
+	}
+	return matches
+}
+
+// merge3 merges two sequences of tokens changed from a common base, like diff3.
+// Tokens both sides kept are stable, runs between them merge cleanly when only one side
+// changed them or both made the same change, and conflict otherwise.
+func merge3(base, ours, theirs []string) []mergeChunk {
+	oursMatch, theirsMatch := matchTokens(base, ours), matchTokens(base, theirs)
+
+	chunks := make([]mergeChunk, 0)
+	emit := func(chunk mergeChunk) {
+		// Join clean text with the previous clean chunk
+		if !chunk.Conflict && len(chunks) > 0 && !chunks[len(chunks)-1].Conflict {
+			chunks[len(chunks)-1].Text += chunk.Text
+			return
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	i, j, k := 0, 0, 0
+	for {
+		// Find the next base token kept by both sides
+		next := i
+		for next < len(base) && (oursMatch[next] < 0 || theirsMatch[next] < 0) {
+			next++
+		}
+		oursEnd, theirsEnd := len(ours), len(theirs)
+		if next < len(base) {
+			oursEnd, theirsEnd = oursMatch[next], theirsMatch[next]
+		}
+
+		if next > i || oursEnd > j || theirsEnd > k {
+			baseText := strings.Join(base[i:next], "")
+			oursText := strings.Join(ours[j:oursEnd], "")
+			theirsText := strings.Join(theirs[k:theirsEnd], "")
+
+			switch {
+			case oursText == baseText:
+				emit(mergeChunk{Text: theirsText})
+			case theirsText == baseText, oursText == theirsText:
+				emit(mergeChunk{Text: oursText})
+			default:
+				emit(mergeChunk{Conflict: true, Base: baseText, Ours: oursText, Theirs: theirsText})
+			}
+		}
+
+		if next == len(base) {
+			break
+		}
+		emit(mergeChunk{Text: base[next]})
+		i, j, k = next+1, oursEnd+1, theirsEnd+1
+	}
+
+	return chunks
+}
+
+// withFinalNewline ends non-empty text with a newline
+func withFinalNewline(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\n"
+
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         []mergeChunk
+	}{
+		{
+			name: "unchanged",
+			ours: base, theirs: base,
+			want: []mergeChunk{{Text: base}},
+		},
+		{
+			name: "changed by ours",
+			ours: "one\nTWO\nthree\nfour\n", theirs: base,
+			want: []mergeChunk{{Text: "one\nTWO\nthree\nfour\n"}},
+		},
+		{
+			name: "changed by theirs",
+			ours: base, theirs: "one\ntwo\nthree\nFOUR\n",
+			want: []mergeChunk{{Text: "one\ntwo\nthree\nFOUR\n"}},
+		},
+		{
+			name: "separate changes",
+			ours: "ONE\ntwo\nthree\nfour\n", theirs: "one\ntwo\nthree\nFOUR\n",
+			want: []mergeChunk{{Text: "ONE\ntwo\nthree\nFOUR\n"}},
+		},
+		{
+			name: "same change on both sides",
+			ours: "one\nTWO\nthree\nfour\n", theirs: "one\nTWO\nthree\nfour\n",
+			want: []mergeChunk{{Text: "one\nTWO\nthree\nfour\n"}},
+		},
+		{
+			name: "different changes of a line",
+			ours: "one\nours\nthree\nfour\n", theirs: "one\ntheirs\nthree\nfour\n",
+			want: []mergeChunk{
+				{Text: "one\n"},
+				{Conflict: true, Base: "two\n", Ours: "ours\n", Theirs: "theirs\n"},
+				{Text: "three\nfour\n"},
+			},
+		},
+		{
+			name: "adjacent line changes",
+			ours: "one\nTWO\nthree\nfour\n", theirs: "one\ntwo\nTHREE\nfour\n",
+			want: []mergeChunk{
+				{Text: "one\n"},
+				{Conflict: true, Base: "two\nthree\n", Ours: "TWO\nthree\n", Theirs: "two\nTHREE\n"},
+				{Text: "four\n"},
+			},
+		},
+		{
+			name: "inserts at the same position",
+			ours: "one\ntwo\nthree\nfour\nours\n", theirs: "one\ntwo\nthree\nfour\ntheirs\n",
+			want: []mergeChunk{
+				{Text: base},
+				{Conflict: true, Ours: "ours\n", Theirs: "theirs\n"},
+			},
+		},
+		{
+			name: "delete against modify",
+			ours: "one\nthree\nfour\n", theirs: "one\nTWO\nthree\nfour\n",
+			want: []mergeChunk{
+				{Text: "one\n"},
+				{Conflict: true, Base: "two\n", Theirs: "TWO\n"},
+				{Text: "three\nfour\n"},
+			},
+		},
+		{
+			name: "delete on one side",
+			ours: base, theirs: "one\nfour\n",
+			want: []mergeChunk{{Text: "one\nfour\n"}},
+		},
+		{
+			name: "everything replaced on both sides",
+			ours: "ours\n", theirs: "theirs\n",
+			want: []mergeChunk{{Conflict: true, Base: base, Ours: "ours\n", Theirs: "theirs\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := merge3(splitLines(base), splitLines(tt.ours), splitLines(tt.theirs))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("merge3 = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := map[string][]string{
+		"":             {},
+		"one":          {"one"},
+		"one\n":        {"one\n"},
+		"one\ntwo":     {"one\n", "two"},
+		"one\n\ntwo\n": {"one\n", "\n", "two\n"},
+	}
+	for text, want := range tests {
+		if got := splitLines(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitLines(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
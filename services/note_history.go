@@ -0,0 +1,162 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// relativePath converts an absolute or vault-relative file path into the slash-separated
+// path git uses
+func (v *vault) relativePath(filePath string) (string, error) {
+	root, err := filepath.Abs(v.config.LocalPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving vault path: %w", err)
+	}
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(root, filepath.FromSlash(filePath))
+	}
+
+	relPath, ok := relativeVaultPath(root, filePath)
+	if !ok {
+		return "", errors.New("invalid file path")
+	}
+	return relPath, nil
+}
+
+// GetFileHistory returns the commits that changed a note as JSON, newest first.
+// The note is followed through renames, each revision has the path it had then.
+func (gns *GitNotesService) GetFileHistory(vaultID string, filePath string, limit int) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	revisions, err := v.syncManager.gitService.FileHistory(relPath, limit)
+	if err != nil {
+		return "", err
+	}
+
+	historyJSON, err := json.Marshal(revisions)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling file history: %w", err)
+	}
+
+	return string(historyJSON), nil
+}
+
+// GetFileVersion returns the content of a note as it was in a commit
+func (gns *GitNotesService) GetFileVersion(vaultID string, filePath string, commit string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := v.syncManager.gitService.FileContentAt(relPath, commit)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetFileDiff compares two versions of a note and returns a FileDiff as JSON.
+// An empty toCommit compares with the working copy. The mode is DiffModeUnified
+// or DiffModeWord.
+func (gns *GitNotesService) GetFileDiff(vaultID string, filePath string, fromCommit string, toCommit string, mode string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if mode == "" {
+		mode = DiffModeUnified
+	}
+	if mode != DiffModeUnified && mode != DiffModeWord {
+		return "", fmt.Errorf("unknown diff mode: %s", mode)
+	}
+
+	gitService := v.syncManager.gitService
+
+	// A version that doesn't contain the file diffs as empty, e.g. before it was created
+	oldContent, oldPath, err := gitService.FileContentAt(relPath, fromCommit)
+	if err != nil && !errors.Is(err, ErrFileNotInRevision) {
+		return "", err
+	}
+	if oldPath == "" {
+		oldPath = relPath
+	}
+
+	var newContent []byte
+	newPath := relPath
+	if toCommit == "" {
+		notePath, err := v.fileService.resolvePath(relPath)
+		if err != nil {
+			return "", err
+		}
+		newContent, err = os.ReadFile(notePath)
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("error reading file: %w", err)
+		}
+	} else {
+		newContent, newPath, err = gitService.FileContentAt(relPath, toCommit)
+		if err != nil && !errors.Is(err, ErrFileNotInRevision) {
+			return "", err
+		}
+		if newPath == "" {
+			newPath = relPath
+		}
+	}
+
+	result := FileDiff{Path: relPath, From: fromCommit, To: toCommit, Mode: mode}
+	if isBinaryContent(oldContent) || isBinaryContent(newContent) {
+		result.Binary = true
+	} else if mode == DiffModeWord {
+		result.Segments, result.Added, result.Removed = wordDiff(string(oldContent), string(newContent))
+	} else {
+		result.Unified, result.Added, result.Removed = unifiedDiff("a/"+oldPath, "b/"+newPath, string(oldContent), string(newContent))
+	}
+
+	diffJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling diff: %w", err)
+	}
+
+	return string(diffJSON), nil
+}
+
+// RestoreFileVersion writes the content a note had in a commit to its current path.
+// The restored version is a new change that the next sync commits.
+func (gns *GitNotesService) RestoreFileVersion(vaultID string, filePath string, commit string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return err
+	}
+	if v.syncManager.IsSyncing() {
+		return errors.New("cannot restore a version while a sync is in progress")
+	}
+
+	content, _, err := v.syncManager.gitService.FileContentAt(relPath, commit)
+	if err != nil {
+		return err
+	}
+
+	return v.fileService.WriteFileContent(filepath.Join(v.config.LocalPath, filepath.FromSlash(relPath)), string(content))
+}
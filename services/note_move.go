@@ -0,0 +1,329 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileMove is a file moved within a vault
+type FileMove struct {
+	OldPath string `json:"oldPath"` // Slash-separated paths relative to the vault root
+	NewPath string `json:"newPath"`
+}
+
+// MoveReport describes a rename or move and the notes whose links were rewritten
+type MoveReport struct {
+	Moved        []FileMove `json:"moved"`
+	UpdatedFiles []string   `json:"updatedFiles"`       // Notes with rewritten links, by their new path
+	Warnings     []string   `json:"warnings,omitempty"` // Notes that couldn't be updated
+}
+
+// linkRewrite is the new text of a link, keyed by where the link appears
+type linkRewrite struct {
+	Line int
+	Kind string
+	Raw  string
+}
+
+// RenameFile renames a note within its directory, stages the rename and rewrites
+// links to it in other notes. It returns a MoveReport as JSON.
+func (gns *GitNotesService) RenameFile(vaultID string, filePath string, newName string) (string, error) {
+	if !isValidFileName(newName) {
+		return "", errors.New("invalid file name")
+	}
+
+	return gns.MoveFile(vaultID, filePath, filepath.Join(filepath.Dir(filePath), newName))
+}
+
+// MoveFile moves a note, stages the move as a rename and rewrites links to it in
+// other notes. It returns a MoveReport as JSON.
+func (gns *GitNotesService) MoveFile(vaultID string, oldPath string, newPath string) (string, error) {
+	return gns.moveInVault(vaultID, oldPath, newPath, false)
+}
+
+// MoveDirectory moves a directory of notes, stages the moves as renames and rewrites
+// links to the moved notes in other notes. It returns a MoveReport as JSON.
+func (gns *GitNotesService) MoveDirectory(vaultID string, oldDir string, newDir string) (string, error) {
+	return gns.moveInVault(vaultID, oldDir, newDir, true)
+}
+
+// moveInVault moves a file or directory and updates git and the links that pointed at it
+func (gns *GitNotesService) moveInVault(vaultID, oldPath, newPath string, isDir bool) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	if v.syncManager.IsSyncing() {
+		return "", errors.New("cannot move files while a sync is in progress")
+	}
+
+	// Check both paths before the source is walked to plan the moves
+	oldPath, err = v.fileService.resolveEntry(oldPath)
+	if err != nil {
+		return "", err
+	}
+	newPath, err = v.fileService.resolveEntry(newPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Keep sync out until the moves are staged and the links rewritten. The file
+	// operations below run under this write.
+	release, err := v.lock.BeginWrite()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	root := v.linkGraph.root
+	moves, err := plannedMoves(root, oldPath, newPath, isDir)
+	if err != nil {
+		return "", err
+	}
+
+	// Collect the links to rewrite while the graph still knows the old locations
+	moved := make(map[string]string, len(moves))
+	movedOld := make(map[string]bool, len(moves))
+	for _, move := range moves {
+		moved[move.OldPath] = move.NewPath
+		movedOld[move.OldPath] = true
+	}
+	links, err := v.linkGraph.linksAffectedBy(movedOld)
+	if err != nil {
+		return "", fmt.Errorf("error reading links: %w", err)
+	}
+
+	if err := v.fileService.moveLocked(oldPath, newPath, isDir); err != nil {
+		return "", err
+	}
+
+	report := MoveReport{Moved: moves, UpdatedFiles: make([]string, 0)}
+
+	// The files are already moved, so staging problems only leave the rename for the next sync
+	if err := v.syncManager.gitService.StageMoves(moves); err != nil {
+		fmt.Printf("Warning: Unable to stage moved files: %v\n", err)
+		report.Warnings = append(report.Warnings, fmt.Sprintf("moves were not staged: %v", err))
+	}
+
+	updated, warnings := gns.rewriteMovedLinks(v, links, moved)
+	report.UpdatedFiles = append(report.UpdatedFiles, updated...)
+	report.Warnings = append(report.Warnings, warnings...)
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling move report: %w", err)
+	}
+
+	return string(reportJSON), nil
+}
+
+// plannedMoves lists the files a move will relocate, as vault-relative paths
+func plannedMoves(root, oldPath, newPath string, isDir bool) ([]FileMove, error) {
+	oldRel, ok := relativeVaultPath(root, oldPath)
+	if !ok {
+		return nil, errors.New("invalid file path")
+	}
+	newRel, ok := relativeVaultPath(root, newPath)
+	if !ok {
+		return nil, errors.New("invalid file path")
+	}
+
+	if !isDir {
+		return []FileMove{{OldPath: oldRel, NewPath: newRel}}, nil
+	}
+
+	moves := make([]FileMove, 0)
+	err := filepath.WalkDir(oldPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relPath, ok := relativeVaultPath(root, filePath)
+		if !ok {
+			return nil
+		}
+		moves = append(moves, FileMove{
+			OldPath: relPath,
+			NewPath: newRel + strings.TrimPrefix(relPath, oldRel),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing directory: %w", err)
+	}
+
+	return moves, nil
+}
+
+// rewriteMovedLinks updates links whose source or target moved so they point at the
+// target's new location. It returns the updated notes and any notes it couldn't update.
+// The caller holds a vault write.
+func (gns *GitNotesService) rewriteMovedLinks(v *vault, links []NoteLink, moved map[string]string) ([]string, []string) {
+	newLocation := func(relPath string) string {
+		if newPath, ok := moved[relPath]; ok {
+			return newPath
+		}
+		return relPath
+	}
+
+	// Work out the new text of every affected link, grouped by the note it's in
+	rewrites := make(map[string]map[linkRewrite]string)
+	for _, link := range links {
+		source, target := newLocation(link.Source), newLocation(link.Target)
+
+		var text string
+		switch link.Kind {
+		case LinkKindWiki:
+			if link.Raw == "" {
+				// Heading links within the same note don't name it
+				continue
+			}
+			text = v.linkGraph.wikiLinkName(source, target)
+			if strings.Contains(link.Raw, "/") {
+				text = strings.TrimSuffix(target, path.Ext(target))
+			}
+			if isMarkdownPath(link.Raw) {
+				text += path.Ext(target)
+			}
+		case LinkKindMarkdown:
+			text = markdownLinkTarget(source, target, link.Raw)
+		}
+		if text == link.Raw {
+			continue
+		}
+
+		if rewrites[source] == nil {
+			rewrites[source] = make(map[linkRewrite]string)
+		}
+		rewrites[source][linkRewrite{Line: link.Line, Kind: link.Kind, Raw: link.Raw}] = text
+	}
+
+	updated := make([]string, 0, len(rewrites))
+	warnings := make([]string, 0)
+	for source, sourceRewrites := range rewrites {
+		notePath, err := v.fileService.resolveEntry(source)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		content, err := os.ReadFile(notePath)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+
+		lines := strings.Split(string(content), "\n")
+		for key, text := range sourceRewrites {
+			if key.Line < 1 || key.Line > len(lines) {
+				continue
+			}
+			lines[key.Line-1] = rewriteLinkInLine(lines[key.Line-1], key, text)
+		}
+
+		newContent := strings.Join(lines, "\n")
+		if newContent == string(content) {
+			continue
+		}
+		if _, err := v.fileService.writeFileLocked(notePath, newContent, nil); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		updated = append(updated, source)
+	}
+
+	sort.Strings(updated)
+	sort.Strings(warnings)
+	return updated, warnings
+}
+
+// markdownLinkTarget returns the path a Markdown link in the source note should use for
+// the target, keeping the root-relative and extensionless styles of the original link
+func markdownLinkTarget(source, target, oldRaw string) string {
+	var text string
+	if strings.HasPrefix(oldRaw, "/") {
+		text = "/" + target
+	} else {
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(source)), filepath.FromSlash(target))
+		if err != nil {
+			rel = target
+		}
+		text = filepath.ToSlash(rel)
+	}
+
+	if path.Ext(oldRaw) == "" {
+		text = strings.TrimSuffix(text, path.Ext(text))
+	}
+	return text
+}
+
+// rewriteLinkInLine replaces the target of every link in the line that matches the
+// key with the new text, keeping headings, aliases and link titles
+func rewriteLinkInLine(line string, key linkRewrite, text string) string {
+	// Find links in a copy with inline code blanked out, so offsets stay the same
+	masked := inlineCodePattern.ReplaceAllStringFunc(line, func(code string) string {
+		return strings.Repeat(" ", len(code))
+	})
+
+	var b strings.Builder
+	last := 0
+	switch key.Kind {
+	case LinkKindWiki:
+		for _, match := range wikiLinkPattern.FindAllStringSubmatchIndex(masked, -1) {
+			if strings.TrimSpace(line[match[2]:match[3]]) != key.Raw {
+				continue
+			}
+			b.WriteString(line[last:match[2]])
+			b.WriteString(text)
+			last = match[3]
+		}
+	case LinkKindMarkdown:
+		for _, match := range markdownLinkPattern.FindAllStringSubmatchIndex(masked, -1) {
+			written := line[match[2]:match[3]]
+			bracketed := strings.HasPrefix(written, "<")
+			target := strings.TrimSuffix(strings.TrimPrefix(written, "<"), ">")
+
+			fragment := ""
+			if idx := strings.Index(target, "#"); idx >= 0 {
+				target, fragment = target[:idx], target[idx:]
+			}
+			escaped := false
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				escaped = unescaped != target
+				target = unescaped
+			}
+			if target != key.Raw {
+				continue
+			}
+
+			// Write the new target the way the old one was written
+			var newTarget string
+			switch {
+			case bracketed:
+				newTarget = "<" + text + fragment + ">"
+			case escaped:
+				newTarget = (&url.URL{Path: text}).EscapedPath() + fragment
+			case strings.ContainsAny(text, " \t"):
+				newTarget = "<" + text + fragment + ">"
+			default:
+				newTarget = text + fragment
+			}
+
+			b.WriteString(line[last:match[2]])
+			b.WriteString(newTarget)
+			last = match[3]
+		}
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinkHops bounds the symlinks followed while resolving a path, like ELOOP
+const maxSymlinkHops = 40
+
+// ErrPathOutsideVault is returned for paths that are, or lead through symlinks, outside the vault
+var ErrPathOutsideVault = errors.New("path is outside the vault")
+
+// ErrProtectedPath is returned for paths the file service must not touch
+var ErrProtectedPath = errors.New("path is protected")
+
+// ErrVaultRoot is returned when an operation that changes an entry is given the vault
+// root itself
+var ErrVaultRoot = errors.New("path is the vault root")
+
+// PathSandbox confines file access to a vault. Paths are absolute or relative to the
+// vault root and are checked after following every symlink, so a link inside the vault
+// can't reach files outside it. Any .git directory, the trash and the configured
+// protected paths are denied, with everything below them.
+type PathSandbox struct {
+	root      string   // Absolute vault path as configured
+	realRoot  string   // root with symlinks resolved
+	protected []string // Slash-separated paths relative to the root
+}
+
+// NewPathSandbox creates a sandbox for the vault at root that also denies the
+// protected vault-relative paths
+func NewPathSandbox(root string, protected []string) (*PathSandbox, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving vault path: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving vault path: %w", err)
+	}
+
+	sandbox := &PathSandbox{root: absRoot, realRoot: realRoot, protected: []string{trashDirName}}
+	for _, protectedPath := range protected {
+		relPath, err := cleanProtectedPath(protectedPath)
+		if err != nil {
+			return nil, err
+		}
+		sandbox.protected = append(sandbox.protected, relPath)
+	}
+
+	return sandbox, nil
+}
+
+// Root returns the absolute vault path
+func (s *PathSandbox) Root() string {
+	return s.root
+}
+
+// Resolve checks an absolute or vault-relative path and returns it as an absolute
+// path under the vault root. The path itself is returned rather than its symlink
+// target, so deleting or moving a link doesn't touch what it points to.
+func (s *PathSandbox) Resolve(filePath string) (string, error) {
+	relPath, err := s.Rel(filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(relPath)), nil
+}
+
+// ResolveEntry is Resolve for paths naming an entry in the vault, which rejects the
+// vault root. Operations that create, change, move or delete a path use it.
+func (s *PathSandbox) ResolveEntry(filePath string) (string, error) {
+	relPath, err := s.Rel(filePath)
+	if err != nil {
+		return "", err
+	}
+	if relPath == "." {
+		return "", ErrVaultRoot
+	}
+	return filepath.Join(s.root, filepath.FromSlash(relPath)), nil
+}
+
+// Rel checks an absolute or vault-relative path and returns it relative to the vault
+// root, slash-separated. The root itself is ".".
+func (s *PathSandbox) Rel(filePath string) (string, error) {
+	relPath, err := s.lexicalRel(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkProtected(relPath); err != nil {
+		return "", err
+	}
+
+	// Where the path really leads must be inside the vault and not protected either
+	realPath, err := s.realPath(relPath)
+	if err != nil {
+		return "", err
+	}
+	realRel, ok := containedPath(s.realRoot, realPath)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPathOutsideVault, relPath)
+	}
+	if err := s.checkProtected(realRel); err != nil {
+		return "", err
+	}
+
+	return relPath, nil
+}
+
+// lexicalRel returns the cleaned path relative to the root, without following
+// symlinks. Absolute paths may use the configured or the resolved root.
+func (s *PathSandbox) lexicalRel(filePath string) (string, error) {
+	if filePath == "" || strings.ContainsRune(filePath, 0) {
+		return "", errors.New("invalid file path")
+	}
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(s.root, filePath)
+	}
+	filePath = filepath.Clean(filePath)
+
+	if relPath, ok := containedPath(s.root, filePath); ok {
+		return relPath, nil
+	}
+	if relPath, ok := containedPath(s.realRoot, filePath); ok {
+		return relPath, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrPathOutsideVault, filePath)
+}
+
+// realPath follows the symlinks of a vault-relative path, including dangling ones,
+// and returns the absolute path it leads to. Components that don't exist yet are
+// taken as they are.
+func (s *PathSandbox) realPath(relPath string) (string, error) {
+	current := s.realRoot
+	pending := splitPath(relPath)
+	hops := 0
+
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		info, err := os.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				current = next
+				continue
+			}
+			return "", fmt.Errorf("error checking path: %w", err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", relPath)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", fmt.Errorf("error reading symbolic link: %w", err)
+		}
+
+		// Continue with the link target in place of the link
+		if filepath.IsAbs(target) {
+			volume := filepath.VolumeName(target)
+			current = volume + string(filepath.Separator)
+			target = target[len(volume):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
+
+	return current, nil
+}
+
+// checkProtected returns ErrProtectedPath for a .git directory, the trash or a
+// configured protected path. Matching ignores case, like some file systems do.
+func (s *PathSandbox) checkProtected(relPath string) error {
+	for _, part := range strings.Split(relPath, "/") {
+		if strings.EqualFold(part, ".git") {
+			return fmt.Errorf("%w: %s", ErrProtectedPath, relPath)
+		}
+	}
+	for _, protectedPath := range s.protected {
+		if len(relPath) >= len(protectedPath) && strings.EqualFold(relPath[:len(protectedPath)], protectedPath) &&
+			(len(relPath) == len(protectedPath) || relPath[len(protectedPath)] == '/') {
+			return fmt.Errorf("%w: %s", ErrProtectedPath, relPath)
+		}
+	}
+	return nil
+}
+
+// containedPath returns the slash-separated path of filePath relative to root, if
+// it's root or inside it
+func containedPath(root, filePath string) (string, bool) {
+	relPath, err := filepath.Rel(root, filePath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(relPath), true
+}
+
+// splitPath splits a path into its components
+func splitPath(filePath string) []string {
+	return strings.FieldsFunc(filePath, func(r rune) bool {
+		return r == '/' || r == filepath.Separator
+	})
+}
+
+// cleanProtectedPath validates a configured protected path and returns it relative
+// to the vault root, slash-separated
+func cleanProtectedPath(protectedPath string) (string, error) {
+	relPath := path.Clean(strings.Trim(filepath.ToSlash(strings.TrimSpace(protectedPath)), "/"))
+	if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("invalid protected path: %q", protectedPath)
+	}
+	return relPath, nil
+}
+
+// protectedPathsFromSettings returns the valid configured protected paths
+func protectedPathsFromSettings(settings map[string]interface{}) []string {
+	stored, _ := settings["protectedPaths"].([]interface{})
+	paths := make([]string, 0, len(stored))
+	for _, value := range stored {
+		protectedPath, ok := value.(string)
+		if !ok {
+			continue
+		}
+		relPath, err := cleanProtectedPath(protectedPath)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring protected path: %v\n", err)
+			continue
+		}
+		paths = append(paths, relPath)
+	}
+	return paths
+}
+
+// GetProtectedPaths returns the vault-relative paths the file operations of every
+// vault refuse to touch, besides .git and the trash, as a JSON array
+func (gns *GitNotesService) GetProtectedPaths() (string, error) {
+	pathsJSON, err := json.Marshal(protectedPathsFromSettings(gns.loadSettingsMap()))
+	if err != nil {
+		return "", fmt.Errorf("error marshaling protected paths: %w", err)
+	}
+
+	return string(pathsJSON), nil
+}
+
+// SetProtectedPaths sets the vault-relative paths, with everything below them, that
+// file operations refuse to read or change in every vault
+func (gns *GitNotesService) SetProtectedPaths(paths []string) error {
+	cleaned := make([]string, 0, len(paths))
+	for _, protectedPath := range paths {
+		relPath, err := cleanProtectedPath(protectedPath)
+		if err != nil {
+			return err
+		}
+		cleaned = append(cleaned, relPath)
+	}
+
+	err := gns.updateSettings(func(settings map[string]interface{}) error {
+		settings["protectedPaths"] = cleaned
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		v.fileService.SetProtectedPaths(cleaned)
+	}
+
+	return nil
+}
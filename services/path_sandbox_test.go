@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestSandbox creates a vault with notes, a protected directory and symlinks
+// leading inside and outside it, next to a sibling directory sharing its prefix
+func newTestSandbox(t *testing.T) (*PathSandbox, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	root := filepath.Join(base, "vault")
+	outside := filepath.Join(base, "outside")
+	writeTestFile(t, filepath.Join(root, "notes", "note.md"), "# Note\n")
+	writeTestFile(t, filepath.Join(root, "private", "secret.md"), "secret")
+	writeTestFile(t, filepath.Join(root, ".git", "config"), "")
+	writeTestFile(t, filepath.Join(base, "vault-evil", "note.md"), "evil")
+	writeTestFile(t, filepath.Join(outside, "dir", "note.md"), "outside")
+
+	links := map[string]string{
+		"file-link":    filepath.Join(outside, "dir", "note.md"),
+		"dir-link":     filepath.Join("..", "outside", "dir"),
+		"dangling":     filepath.Join(outside, "missing.md"),
+		"inside-link":  filepath.Join("notes", "note.md"),
+		"git-link":     ".git",
+		"private-link": "private",
+		"loop-a":       "loop-b",
+		"loop-b":       "loop-a",
+	}
+	// A chain one hop longer than allowed, ending at a note
+	for i := 0; i < maxSymlinkHops; i++ {
+		links["chain-"+strconv.Itoa(i)] = "chain-" + strconv.Itoa(i+1)
+	}
+	links["chain-"+strconv.Itoa(maxSymlinkHops)] = filepath.Join("notes", "note.md")
+
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+
+	sandbox, err := NewPathSandbox(root, []string{"private", "Archive/old/"})
+	if err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+	return sandbox, root
+}
+
+func TestPathSandboxRel(t *testing.T) {
+	sandbox, root := newTestSandbox(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error  // Sentinel the error must match
+		errText string // Text the error must contain, for errors without a sentinel
+	}{
+		{name: "relative note", path: "notes/note.md", want: "notes/note.md"},
+		{name: "absolute note", path: filepath.Join(root, "notes", "note.md"), want: "notes/note.md"},
+		{name: "new file", path: "notes/new/draft.md", want: "notes/new/draft.md"},
+		{name: "dot dot inside", path: "notes/../notes/note.md", want: "notes/note.md"},
+		{name: "vault root", path: ".", want: "."},
+		{name: "absolute vault root", path: root, want: "."},
+		{name: "link inside", path: "inside-link", want: "inside-link"},
+
+		{name: "dot dot escape", path: "../outside/dir/note.md", wantErr: ErrPathOutsideVault},
+		{name: "nested dot dot escape", path: "notes/../../outside", wantErr: ErrPathOutsideVault},
+		{name: "same-prefix sibling", path: filepath.Join(root+"-evil", "note.md"), wantErr: ErrPathOutsideVault},
+		{name: "relative same-prefix sibling", path: "../vault-evil/note.md", wantErr: ErrPathOutsideVault},
+		{name: "absolute outside", path: filepath.Join(filepath.Dir(root), "outside", "dir"), wantErr: ErrPathOutsideVault},
+		{name: "file link outside", path: "file-link", wantErr: ErrPathOutsideVault},
+		{name: "directory link outside", path: "dir-link", wantErr: ErrPathOutsideVault},
+		{name: "through directory link outside", path: "dir-link/note.md", wantErr: ErrPathOutsideVault},
+		{name: "new file through directory link", path: "dir-link/new.md", wantErr: ErrPathOutsideVault},
+		{name: "dangling link outside", path: "dangling", wantErr: ErrPathOutsideVault},
+
+		{name: "symlink loop", path: "loop-a", errText: "too many levels of symbolic links"},
+		{name: "symlink chain too long", path: "chain-0", errText: "too many levels of symbolic links"},
+		{name: "symlink chain at limit", path: "chain-1", want: "chain-1"},
+
+		{name: "git directory", path: ".git", wantErr: ErrProtectedPath},
+		{name: "git config", path: ".git/config", wantErr: ErrProtectedPath},
+		{name: "git directory upper case", path: ".GIT/config", wantErr: ErrProtectedPath},
+		{name: "nested git directory", path: "notes/.git/HEAD", wantErr: ErrProtectedPath},
+		{name: "link to git directory", path: "git-link/config", wantErr: ErrProtectedPath},
+		{name: "trash", path: trashDirName, wantErr: ErrProtectedPath},
+		{name: "trash item", path: trashDirName + "/item/note.md", wantErr: ErrProtectedPath},
+		{name: "protected path", path: "private", wantErr: ErrProtectedPath},
+		{name: "protected path child", path: "private/secret.md", wantErr: ErrProtectedPath},
+		{name: "protected path other case", path: "PRIVATE/secret.md", wantErr: ErrProtectedPath},
+		{name: "nested protected path", path: "archive/old/note.md", wantErr: ErrProtectedPath},
+		{name: "link to protected path", path: "private-link/secret.md", wantErr: ErrProtectedPath},
+		{name: "protected path prefix", path: "private-notes/note.md", want: "private-notes/note.md"},
+		{name: "protected path parent", path: "Archive/new.md", want: "Archive/new.md"},
+
+		{name: "empty path", path: "", errText: "invalid file path"},
+		{name: "nul byte", path: "notes/note.md\x00.txt", errText: "invalid file path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sandbox.Rel(tt.path)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Rel(%q) = %q, %v, want %v", tt.path, got, err, tt.wantErr)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("Rel(%q) = %q, %v, want an error containing %q", tt.path, got, err, tt.errText)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Rel(%q): %v", tt.path, err)
+				}
+				if got != tt.want {
+					t.Fatalf("Rel(%q) = %q, want %q", tt.path, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPathSandboxResolveEntry(t *testing.T) {
+	sandbox, root := newTestSandbox(t)
+
+	for _, rootPath := range []string{".", "./", root, root + string(filepath.Separator), "notes/.."} {
+		if got, err := sandbox.ResolveEntry(rootPath); !errors.Is(err, ErrVaultRoot) {
+			t.Errorf("ResolveEntry(%q) = %q, %v, want ErrVaultRoot", rootPath, got, err)
+		}
+	}
+
+	// Resolve still accepts the root, which is listed like any directory
+	if got, err := sandbox.Resolve("."); err != nil || got != root {
+		t.Errorf("Resolve(\".\") = %q, %v, want the root", got, err)
+	}
+
+	// The link itself is returned, not its target
+	got, err := sandbox.ResolveEntry("inside-link")
+	if err != nil {
+		t.Fatalf("ResolveEntry: %v", err)
+	}
+	if want := filepath.Join(root, "inside-link"); got != want {
+		t.Errorf("ResolveEntry(\"inside-link\") = %q, want %q", got, want)
+	}
+}
+
+func TestFileServiceRejectsVaultRoot(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "notes", "note.md"), "# Note\n")
+	fileService := NewFileService(&RepositoryService{localRepoPath: root, isConnected: true})
+
+	tests := []struct {
+		name    string
+		op      func() error
+		wantErr error
+	}{
+		{name: "delete root", op: func() error { return fileService.DeleteFile(".") }, wantErr: ErrVaultRoot},
+		{name: "delete absolute root", op: func() error { return fileService.DeleteFile(root) }, wantErr: ErrVaultRoot},
+		{name: "write root", op: func() error { return fileService.WriteFileContent(root, "content") }, wantErr: ErrVaultRoot},
+		{name: "create root", op: func() error { return fileService.CreateFile(".", "content") }, wantErr: ErrVaultRoot},
+		{name: "create root directory", op: func() error { return fileService.CreateDirectory(root) }, wantErr: ErrVaultRoot},
+		{name: "move root", op: func() error { return fileService.MoveDirectory(root, filepath.Join(root, "moved")) }, wantErr: ErrVaultRoot},
+		{name: "move onto root", op: func() error { return fileService.MoveDirectory("notes", root) }, wantErr: ErrVaultRoot},
+		{name: "move file onto root", op: func() error { return fileService.MoveFile("notes/note.md", ".") }, wantErr: ErrVaultRoot},
+		{name: "rename root", op: func() error {
+			_, err := fileService.RenameFile(root, "renamed")
+			return err
+		}, wantErr: ErrVaultRoot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got := readTestFile(t, filepath.Join(root, "notes", "note.md")); got != "# Note\n" {
+				t.Fatalf("note = %q after the rejected operation", got)
+			}
+		})
+	}
+
+	if err := fileService.DeleteFile(""); err == nil {
+		t.Error("deleting an empty path succeeded")
+	}
+	if _, err := fileService.GetChildrenOfPath("."); err != nil {
+		t.Errorf("listing the root: %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Fuzzy match scoring, modelled on fzf: every matched character scores, gaps cost,
+// and characters at word, path segment and camelCase boundaries get a bonus
+const (
+	fuzzyScoreMatch        = 16
+	fuzzyScoreGapStart     = -3
+	fuzzyScoreGapExtension = -1
+	fuzzyBonusBoundary     = 8
+	fuzzyBonusSegment      = 9 // After a path separator
+	fuzzyBonusCamel        = 7
+	fuzzyBonusConsecutive  = 4
+	fuzzyBonusFirstChar    = 2 // Multiplier for the bonus of the first pattern character
+	fuzzyBonusFileName     = 20
+)
+
+// Quick open limits
+const (
+	defaultQuickOpenLimit = 50
+	maxRecentNotes        = 50
+	maxRecencyBonus       = 40
+)
+
+// QuickOpenResult is a file matching a quick open query
+type QuickOpenResult struct {
+	Path         string `json:"path"`         // Absolute path of the file
+	RelativePath string `json:"relativePath"` // Slash-separated path relative to the vault root
+	Score        int    `json:"score"`
+	Positions    []int  `json:"positions"` // Byte offsets of the matched characters in RelativePath
+}
+
+// recentNote is a note opened in the vault
+type recentNote struct {
+	Path     string    `json:"path"` // Slash-separated path relative to the vault root
+	OpenedAt time.Time `json:"openedAt"`
+}
+
+// QuickOpenIndex finds files in a vault by fuzzy matching their paths.
+// The file list is cached and only rescanned when files are added or removed.
+type QuickOpenIndex struct {
+	root       string
+	recentPath string
+	mu         sync.Mutex
+	paths      []string        // Sorted relative paths, nil when a rescan is needed
+	known      map[string]bool // Set of paths for change detection
+	recent     []recentNote    // Most recently opened first
+}
+
+// NewQuickOpenIndex creates a quick open index for the files under root, keeping the
+// recently opened notes in recentPath
+func NewQuickOpenIndex(root, recentPath string) *QuickOpenIndex {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+
+	qi := &QuickOpenIndex{
+		root:       absRoot,
+		recentPath: recentPath,
+	}
+	qi.loadRecent()
+	return qi
+}
+
+// NewVaultQuickOpenIndex creates the quick open index for a repository
+func NewVaultQuickOpenIndex(repoPath string) (*QuickOpenIndex, error) {
+	dataDir, err := repoDataDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewQuickOpenIndex(repoPath, filepath.Join(dataDir, "recent_notes.json")), nil
+}
+
+// FilesChanged invalidates the file list when files were added or removed.
+// Edits to files already in the list keep it.
+func (qi *QuickOpenIndex) FilesChanged(paths []string) {
+	qi.mu.Lock()
+	defer qi.mu.Unlock()
+
+	if qi.paths == nil {
+		return
+	}
+
+	for _, changed := range paths {
+		relPath, ok := relativeVaultPath(qi.root, changed)
+		if !ok {
+			continue
+		}
+
+		// Deleted directories aren't in the list themselves, so any removal rescans
+		if _, err := os.Stat(changed); err != nil || !qi.known[relPath] {
+			qi.paths = nil
+			return
+		}
+	}
+}
+
+// RecordOpened moves a note to the front of the recently opened list
+func (qi *QuickOpenIndex) RecordOpened(filePath string) {
+	relPath, ok := relativeVaultPath(qi.root, filePath)
+	if !ok {
+		return
+	}
+
+	qi.mu.Lock()
+	recent := make([]recentNote, 0, len(qi.recent)+1)
+	recent = append(recent, recentNote{Path: relPath, OpenedAt: time.Now()})
+	for _, note := range qi.recent {
+		if note.Path != relPath && len(recent) < maxRecentNotes {
+			recent = append(recent, note)
+		}
+	}
+	qi.recent = recent
+	qi.mu.Unlock()
+
+	if err := qi.saveRecent(recent); err != nil {
+		fmt.Printf("Warning: Unable to save recent notes: %v\n", err)
+	}
+}
+
+// Search returns the files whose paths fuzzy match the query, best match first.
+// Space-separated terms must all match. An empty query lists recent notes first.
+func (qi *QuickOpenIndex) Search(query string, limit int) ([]QuickOpenResult, error) {
+	if limit <= 0 {
+		limit = defaultQuickOpenLimit
+	}
+
+	paths, recentRank, err := qi.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	results := make([]QuickOpenResult, 0, limit)
+	for _, relPath := range paths {
+		result := QuickOpenResult{
+			Path:         filepath.Join(qi.root, filepath.FromSlash(relPath)),
+			RelativePath: relPath,
+			Positions:    []int{},
+		}
+
+		matched := true
+		for _, term := range terms {
+			score, positions, ok := fuzzyMatchPath([]rune(term), relPath)
+			if !ok {
+				matched = false
+				break
+			}
+			result.Score += score
+			result.Positions = append(result.Positions, positions...)
+		}
+		if !matched {
+			continue
+		}
+
+		if rank, ok := recentRank[relPath]; ok {
+			result.Score += maxRecencyBonus * (maxRecentNotes - rank) / maxRecentNotes
+		}
+		sort.Ints(result.Positions)
+		results = append(results, result)
+	}
+
+	// Ties go to shorter paths, which are usually the more specific match
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].RelativePath) != len(results[j].RelativePath) {
+			return len(results[i].RelativePath) < len(results[j].RelativePath)
+		}
+		return results[i].RelativePath < results[j].RelativePath
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// snapshot returns the file list, rescanning it if needed, and the rank of each recent note
+func (qi *QuickOpenIndex) snapshot() ([]string, map[string]int, error) {
+	qi.mu.Lock()
+	defer qi.mu.Unlock()
+
+	if qi.paths == nil {
+		paths, err := listVaultFiles(qi.root)
+		if err != nil {
+			return nil, nil, err
+		}
+		qi.paths = paths
+		qi.known = make(map[string]bool, len(paths))
+		for _, relPath := range paths {
+			qi.known[relPath] = true
+		}
+	}
+
+	recentRank := make(map[string]int, len(qi.recent))
+	for rank, note := range qi.recent {
+		recentRank[note.Path] = rank
+	}
+
+	return qi.paths, recentRank, nil
+}
+
+// loadRecent reads the recently opened notes
+func (qi *QuickOpenIndex) loadRecent() {
+	data, err := os.ReadFile(qi.recentPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("Warning: Unable to read recent notes: %v\n", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &qi.recent); err != nil {
+		fmt.Printf("Warning: Ignoring invalid recent notes: %v\n", err)
+		qi.recent = nil
+	}
+}
+
+// saveRecent writes the recently opened notes
+func (qi *QuickOpenIndex) saveRecent(recent []recentNote) error {
+	data, err := json.Marshal(recent)
+	if err != nil {
+		return fmt.Errorf("error marshaling recent notes: %w", err)
+	}
+
+	return os.WriteFile(qi.recentPath, data, 0600)
+}
+
+// listVaultFiles returns the sorted relative paths of the files under root,
+// skipping hidden files and directories such as .git
+func listVaultFiles(root string) ([]string, error) {
+	paths := make([]string, 0, 1024)
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if filePath == root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		if relPath, ok := relativeVaultPath(root, filePath); ok {
+			paths = append(paths, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing vault files: %w", err)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// relativeVaultPath converts an absolute or root-relative path into a slash-separated
+// path within root
+func relativeVaultPath(root, filePath string) (string, bool) {
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(root, filePath)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", false
+	}
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(relPath), true
+}
+
+// fuzzyMatchPath matches a lowercased pattern against a relative path. A match
+// entirely within the file name is preferred over one spread across directories.
+func fuzzyMatchPath(pattern []rune, relPath string) (int, []int, bool) {
+	base := path.Base(relPath)
+	offset := len(relPath) - len(base)
+	if score, positions, ok := fuzzyMatch(pattern, base); ok {
+		for i := range positions {
+			positions[i] += offset
+		}
+		return score + fuzzyBonusFileName, positions, true
+	}
+
+	return fuzzyMatch(pattern, relPath)
+}
+
+// fuzzyMatch finds the pattern characters in order in text, case-insensitively.
+// Like fzf it takes the first match, narrows it to the shortest window ending there
+// and scores that window. It returns the byte offsets of the matched characters.
+func fuzzyMatch(pattern []rune, text string) (int, []int, bool) {
+	if len(pattern) == 0 {
+		return 0, []int{}, true
+	}
+
+	runes := make([]rune, 0, len(text))
+	offsets := make([]int, 0, len(text))
+	for offset, r := range text {
+		runes = append(runes, r)
+		offsets = append(offsets, offset)
+	}
+
+	// Forward scan for the first position where the whole pattern has matched
+	start, end, pi := -1, -1, 0
+	for i, r := range runes {
+		if unicode.ToLower(r) != pattern[pi] {
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		pi++
+		if pi == len(pattern) {
+			end = i + 1
+			break
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Backward scan for the shortest window ending at the same position
+	pi = len(pattern) - 1
+	for i := end - 1; i >= start; i-- {
+		if unicode.ToLower(runes[i]) == pattern[pi] {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	// Score the window
+	score := 0
+	positions := make([]int, 0, len(pattern))
+	pi = 0
+	inGap := false
+	consecutive := 0
+	firstBonus := 0
+	for i := start; i < end; i++ {
+		if pi < len(pattern) && unicode.ToLower(runes[i]) == pattern[pi] {
+			bonus := fuzzyBonusAt(runes, i)
+			if consecutive == 0 {
+				firstBonus = bonus
+			} else {
+				// A consecutive run keeps the bonus of its first character
+				if bonus < firstBonus {
+					bonus = firstBonus
+				}
+				if bonus < fuzzyBonusConsecutive {
+					bonus = fuzzyBonusConsecutive
+				}
+			}
+			if pi == 0 {
+				bonus *= fuzzyBonusFirstChar
+			}
+
+			score += fuzzyScoreMatch + bonus
+			positions = append(positions, offsets[i])
+			consecutive++
+			inGap = false
+			pi++
+			continue
+		}
+
+		if inGap {
+			score += fuzzyScoreGapExtension
+		} else {
+			score += fuzzyScoreGapStart
+		}
+		inGap = true
+		consecutive = 0
+	}
+
+	return score, positions, true
+}
+
+// fuzzyBonusAt returns the boundary bonus for matching the character at index i
+func fuzzyBonusAt(runes []rune, i int) int {
+	if i == 0 {
+		return fuzzyBonusBoundary
+	}
+
+	prev, cur := runes[i-1], runes[i]
+	switch {
+	case prev == '/':
+		return fuzzyBonusSegment
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(cur) || unicode.IsDigit(cur)):
+		return fuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return fuzzyBonusCamel
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return fuzzyBonusCamel
+	}
+	return 0
+}
@@ -7,7 +7,8 @@ import (
 	"path/filepath"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 // RepositoryService handles Git repository operations
@@ -15,23 +16,39 @@ type RepositoryService struct {
 	localRepoPath string
 	repoURL       string
 	credService   *CredentialService
+	authProvider  *DefaultAuthProvider
 	repository    *git.Repository
 	isConnected   bool
+	emitter       EventEmitter
 }
 
 // NewRepositoryService creates a new RepositoryService instance
 func NewRepositoryService() *RepositoryService {
+	credService := NewCredentialService()
 	return &RepositoryService{
-		credService: NewCredentialService(),
-		isConnected: false,
+		credService:  credService,
+		authProvider: NewAuthProvider(credService),
+		isConnected:  false,
 	}
 }
 
+// SetEventEmitter sets the emitter used to report clone and pull progress
+func (rs *RepositoryService) SetEventEmitter(emitter EventEmitter) {
+	rs.emitter = emitter
+}
+
+// AuthProvider returns the provider used to authenticate against the remote
+func (rs *RepositoryService) AuthProvider() *DefaultAuthProvider {
+	return rs.authProvider
+}
+
 // ConnectRepository connects to the specified Git repository
 // If the repository is not already cloned, it will clone it.
 // If it is already cloned, it will open the existing repository.
 // If token is empty, it will attempt to use a previously stored token.
-func (rs *RepositoryService) ConnectRepository(repoURL, localPath, token string) error {
+// branch and remoteName select what is cloned, empty values use the remote's
+// default branch and origin.
+func (rs *RepositoryService) ConnectRepository(repoURL, localPath, token, branch, remoteName string) error {
 	// Check if inputs are valid
 	if repoURL == "" {
 		return errors.New("repository URL is required")
@@ -41,7 +58,8 @@ func (rs *RepositoryService) ConnectRepository(repoURL, localPath, token string)
 	}
 
 	// Store the credentials securely if token is provided
-	if token != "" {
+	// SSH remotes authenticate with keys, so a token only applies to HTTPS
+	if token != "" && !IsSSHURL(repoURL) {
 		err := rs.credService.StoreCredential(repoURL, token)
 		if err != nil {
 			return fmt.Errorf("failed to store credentials: %w", err)
@@ -65,42 +83,36 @@ func (rs *RepositoryService) ConnectRepository(repoURL, localPath, token string)
 	}
 
 	// Repository does not exist, clone it
-	return rs.cloneRepository()
+	return rs.cloneRepository(branch, remoteName)
 }
 
 // cloneRepository clones the remote repository to the local path
-func (rs *RepositoryService) cloneRepository() error {
+func (rs *RepositoryService) cloneRepository(branch, remoteName string) error {
 	// Ensure the parent directory exists
 	if err := os.MkdirAll(filepath.Dir(rs.localRepoPath), 0755); err != nil {
 		return fmt.Errorf("error creating parent directories: %w", err)
 	}
 
-	// Get stored credentials for this repository
-	token, err := rs.credService.GetCredential(rs.repoURL)
+	// Resolve authentication for the remote URL scheme
+	auth, mode, err := rs.authProvider.AuthMethod(rs.repoURL)
 	if err != nil {
-		// If token not found in keychain, we'll try to proceed anyway
-		// Git operations will still work for public repos or if a token is provided at runtime
-		fmt.Printf("Warning: Unable to retrieve credentials from keychain: %v\n", err)
-		token = "" // Ensure token is empty if not found
-	}
-
-	// Setup auth if we have a token
-	var auth *http.BasicAuth
-	if token != "" {
-		auth = &http.BasicAuth{
-			Username: "github-token", // This can be any string when using a token
-			Password: token,
-		}
+		return err
+	}
+
+	cloneOptions := &git.CloneOptions{
+		URL:        rs.repoURL,
+		Auth:       auth,
+		RemoteName: remoteName,
+		Progress:   newProgressWriter("clone", rs.emitter),
+	}
+	if branch != "" {
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(branch)
 	}
 
 	// Clone the repository
-	repo, err := git.PlainClone(rs.localRepoPath, false, &git.CloneOptions{
-		URL:      rs.repoURL,
-		Auth:     auth,
-		Progress: os.Stdout, // Show progress
-	})
+	repo, err := git.PlainClone(rs.localRepoPath, false, cloneOptions)
 	if err != nil {
-		return fmt.Errorf("error cloning repository: %w", err)
+		return classifyGitError("clone_repository", mode, err)
 	}
 
 	rs.repository = repo
@@ -117,10 +129,16 @@ func (rs *RepositoryService) ValidateConnection(repoURL, token string) error {
 	}
 	defer os.RemoveAll(tempDir) // Clean up after test
 
-	// Setup auth
-	auth := &http.BasicAuth{
-		Username: "github-token", // This can be any string when using a token
-		Password: token,
+	// Setup auth, an explicit token takes precedence for HTTPS remotes
+	var auth transport.AuthMethod
+	mode := AuthModeToken
+	if token != "" && !IsSSHURL(repoURL) {
+		auth = TokenAuth(token)
+	} else {
+		auth, mode, err = rs.authProvider.AuthMethod(repoURL)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Try to clone the repository
@@ -137,7 +155,7 @@ func (rs *RepositoryService) ValidateConnection(repoURL, token string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("connection validation failed: %w", err)
+		return classifyGitError("validate_connection", mode, err)
 	}
 
 	return nil
@@ -159,22 +177,10 @@ func (rs *RepositoryService) UpdateRemote() error {
 		return errors.New("not connected to a repository")
 	}
 
-	// Get stored credentials for this repository
-	token, err := rs.credService.GetCredential(rs.repoURL)
+	// Resolve authentication for the remote URL scheme
+	auth, mode, err := rs.authProvider.AuthMethod(rs.repoURL)
 	if err != nil {
-		// If token not found in keychain, we'll try to proceed anyway
-		// This can still work for public repos or those with SSH keys
-		fmt.Printf("Warning: Unable to retrieve credentials from keychain: %v\n", err)
-		token = "" // Ensure token is empty if not found
-	}
-
-	// Setup auth if we have a token
-	var auth *http.BasicAuth
-	if token != "" {
-		auth = &http.BasicAuth{
-			Username: "github-token", // This can be any string when using a token
-			Password: token,
-		}
+		return err
 	}
 
 	// Get the worktree
@@ -183,13 +189,18 @@ func (rs *RepositoryService) UpdateRemote() error {
 		return fmt.Errorf("error getting worktree: %w", err)
 	}
 
+	// Pull the branch tracked by the checked out branch
+	upstream, err := resolveUpstream(rs.repository)
+	if err != nil {
+		return err
+	}
+
+	pullOptions := upstream.pullOptions()
+	pullOptions.Auth = auth
+	pullOptions.Progress = newProgressWriter("pull", rs.emitter)
+
 	// Pull the latest changes
-	err = w.Pull(&git.PullOptions{
-		Auth:          auth,
-		RemoteName:    "origin",
-		ReferenceName: "", // Use default branch
-		Progress:      os.Stdout,
-	})
+	err = w.Pull(pullOptions)
 
 	// Handle already up-to-date case
 	if err == git.NoErrAlreadyUpToDate {
@@ -197,7 +208,7 @@ func (rs *RepositoryService) UpdateRemote() error {
 	}
 
 	if err != nil {
-		return fmt.Errorf("error pulling latest changes: %w", err)
+		return classifyGitError("update_remote", mode, err)
 	}
 
 	return nil
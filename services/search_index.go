@@ -0,0 +1,767 @@
+package services
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchIndexVersion is bumped when tokenization or the file format changes so
+// persisted indexes are rebuilt
+const searchIndexVersion = 1
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Search result limits
+const (
+	defaultSearchLimit    = 20
+	maxMatchesPerResult   = 3
+	maxSnippetLength      = 200
+	searchIndexSaveDelay  = 2 * time.Second
+	titleMatchScoreFactor = 1.5
+)
+
+// SearchResult is a note matching a search query
+type SearchResult struct {
+	Path         string        `json:"path"`         // Absolute path of the note
+	RelativePath string        `json:"relativePath"` // Path relative to the vault root
+	Title        string        `json:"title"`
+	Score        float64       `json:"score"`
+	Matches      []SearchMatch `json:"matches"`
+}
+
+// SearchMatch is a line of a note containing query terms
+type SearchMatch struct {
+	Line       int      `json:"line"` // 1-based line number
+	Snippet    string   `json:"snippet"`
+	Highlights [][2]int `json:"highlights"` // Byte ranges of matched words within the snippet
+}
+
+// indexedDoc is the indexed form of a note
+type indexedDoc struct {
+	ModTime int64
+	Size    int64
+	Title   string
+	Terms   []string // Stemmed terms in document order
+	Lines   []int    // Line number of each term
+}
+
+// searchIndexFile is the persisted form of the index
+type searchIndexFile struct {
+	Version int
+	Docs    map[string]*indexedDoc
+}
+
+// searchClause is one part of a query that every result must match
+type searchClause struct {
+	terms  []string // Stemmed terms, several for a phrase
+	prefix bool     // The single unstemmed term matches as a prefix
+}
+
+// SearchIndex is an inverted index of the Markdown notes in a vault.
+// It is updated incrementally as files change and persisted between runs.
+type SearchIndex struct {
+	root       string // Absolute vault path
+	path       string // File the index is persisted to
+	mu         sync.RWMutex
+	docs       map[string]*indexedDoc      // Notes by slash-separated relative path
+	postings   map[string]map[string][]int // Term -> note -> term positions
+	totalTerms int
+	saveTimer  *time.Timer
+	saveMu     sync.Mutex
+}
+
+// NewSearchIndex creates an empty index of the notes under root, persisted to path
+func NewSearchIndex(root, path string) *SearchIndex {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+
+	return &SearchIndex{
+		root:     absRoot,
+		path:     path,
+		docs:     make(map[string]*indexedDoc),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// NewVaultSearchIndex creates the index for a repository in its data directory
+func NewVaultSearchIndex(repoPath string) (*SearchIndex, error) {
+	dataDir, err := repoDataDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSearchIndex(repoPath, filepath.Join(dataDir, "search_index.gob")), nil
+}
+
+// Load reads the persisted index. A missing or outdated index file is not an error,
+// Refresh rebuilds what is needed.
+func (si *SearchIndex) Load() error {
+	file, err := os.Open(si.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening search index: %w", err)
+	}
+	defer file.Close()
+
+	var stored searchIndexFile
+	if err := gob.NewDecoder(file).Decode(&stored); err != nil {
+		return fmt.Errorf("error reading search index: %w", err)
+	}
+	if stored.Version != searchIndexVersion {
+		return nil
+	}
+
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.docs = make(map[string]*indexedDoc)
+	si.postings = make(map[string]map[string][]int)
+	si.totalTerms = 0
+	for relPath, doc := range stored.Docs {
+		si.putDocLocked(relPath, doc)
+	}
+
+	return nil
+}
+
+// Refresh brings the index up to date with the notes on disk. Only notes whose size
+// or modification time changed since they were indexed are read.
+func (si *SearchIndex) Refresh() error {
+	changed, err := si.refreshTree(si.root)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		return si.Save()
+	}
+	return nil
+}
+
+// refreshTree updates the index for the notes under dir, like Refresh does for the
+// whole vault, and reports whether the index changed
+func (si *SearchIndex) refreshTree(dir string) (bool, error) {
+	relDir, ok := si.relativePath(dir)
+	if !ok && dir != si.root {
+		return false, nil
+	}
+	// Hidden directories aren't indexed, like Refresh skips them
+	for _, part := range strings.Split(relDir, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false, nil
+		}
+	}
+	inTree := func(relPath string) bool {
+		return dir == si.root || strings.HasPrefix(relPath, relDir+"/")
+	}
+
+	si.mu.RLock()
+	known := make(map[string]*indexedDoc)
+	for relPath, doc := range si.docs {
+		if inTree(relPath) {
+			known[relPath] = doc
+		}
+	}
+	si.mu.RUnlock()
+
+	seen := make(map[string]bool, len(known))
+	updated := make(map[string]*indexedDoc)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable entries rather than failing the whole walk
+			return nil
+		}
+		if entry.IsDir() {
+			if path != si.root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownPath(path) {
+			return nil
+		}
+
+		relPath, ok := si.relativePath(path)
+		if !ok {
+			return nil
+		}
+		seen[relPath] = true
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if doc, ok := known[relPath]; ok && doc.ModTime == info.ModTime().UnixNano() && doc.Size == info.Size() {
+			return nil
+		}
+
+		doc, err := indexNote(path)
+		if err != nil {
+			fmt.Printf("Warning: Unable to index %s: %v\n", relPath, err)
+			return nil
+		}
+		updated[relPath] = doc
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error scanning vault: %w", err)
+	}
+
+	si.mu.Lock()
+	changed := len(updated) > 0
+	for relPath, doc := range updated {
+		si.putDocLocked(relPath, doc)
+	}
+	for relPath := range known {
+		if !seen[relPath] {
+			si.removeDocLocked(relPath)
+			changed = true
+		}
+	}
+	si.mu.Unlock()
+
+	return changed, nil
+}
+
+// Rebuild discards the index and indexes every note again
+func (si *SearchIndex) Rebuild() error {
+	si.mu.Lock()
+	si.docs = make(map[string]*indexedDoc)
+	si.postings = make(map[string]map[string][]int)
+	si.totalTerms = 0
+	si.mu.Unlock()
+
+	if err := si.Refresh(); err != nil {
+		return err
+	}
+	return si.Save()
+}
+
+// FilesChanged updates the index for written, created and deleted files
+func (si *SearchIndex) FilesChanged(paths []string) {
+	for _, path := range paths {
+		relPath, ok := si.relativePath(path)
+		if !ok {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			si.removePath(relPath)
+		case err != nil:
+			fmt.Printf("Warning: Unable to index %s: %v\n", relPath, err)
+		case info.IsDir():
+			// A directory appeared, e.g. restored by a pull. Pick up its notes.
+			if _, err := si.refreshTree(path); err != nil {
+				fmt.Printf("Warning: Unable to refresh search index: %v\n", err)
+			}
+		case isMarkdownPath(path):
+			doc, err := indexNote(path)
+			if err != nil {
+				fmt.Printf("Warning: Unable to index %s: %v\n", relPath, err)
+				continue
+			}
+			si.mu.Lock()
+			si.putDocLocked(relPath, doc)
+			si.mu.Unlock()
+		}
+	}
+
+	si.scheduleSave()
+}
+
+// removePath removes a note, or every note under a deleted directory
+func (si *SearchIndex) removePath(relPath string) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.removeDocLocked(relPath)
+	dirPrefix := relPath + "/"
+	for docPath := range si.docs {
+		if strings.HasPrefix(docPath, dirPrefix) {
+			si.removeDocLocked(docPath)
+		}
+	}
+}
+
+// Search returns the notes matching the query, best match first.
+// Words must all appear in a note, "quoted phrases" must appear in order and
+// a trailing * matches words starting with the prefix.
+func (si *SearchIndex) Search(query string, limit int) ([]SearchResult, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	clauses := parseSearchQuery(query)
+	results := make([]SearchResult, 0)
+	if len(clauses) == 0 {
+		return results, nil
+	}
+
+	type candidate struct {
+		relPath   string
+		score     float64
+		positions []int
+	}
+
+	si.mu.RLock()
+	docCount := len(si.docs)
+	if docCount == 0 {
+		si.mu.RUnlock()
+		return results, nil
+	}
+	avgLength := float64(si.totalTerms) / float64(docCount)
+
+	// Intersect the clause matches, scoring each with BM25
+	var candidates map[string]*candidate
+	for i, clause := range clauses {
+		matches := si.matchClauseLocked(clause)
+		df := float64(len(matches))
+		idf := math.Log(1 + (float64(docCount)-df+0.5)/(df+0.5))
+
+		next := make(map[string]*candidate, len(matches))
+		for relPath, occurrence := range matches {
+			previous := candidates[relPath]
+			if i > 0 && previous == nil {
+				continue
+			}
+			if previous == nil {
+				previous = &candidate{relPath: relPath}
+			}
+
+			doc := si.docs[relPath]
+			tf := float64(occurrence.count)
+			norm := 1 - bm25B + bm25B*float64(len(doc.Terms))/avgLength
+			score := idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			if clause.matchesTitle(doc.Title) {
+				score *= titleMatchScoreFactor
+			}
+
+			previous.score += score
+			previous.positions = append(previous.positions, occurrence.positions...)
+			next[relPath] = previous
+		}
+		candidates = next
+	}
+
+	ranked := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].relPath < ranked[j].relPath
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	// Collect the matched lines while holding the lock, snippets are read afterwards
+	matchLines := make([][]int, len(ranked))
+	for i, c := range ranked {
+		doc := si.docs[c.relPath]
+		results = append(results, SearchResult{
+			Path:         filepath.Join(si.root, filepath.FromSlash(c.relPath)),
+			RelativePath: c.relPath,
+			Title:        doc.Title,
+			Score:        c.score,
+			Matches:      []SearchMatch{},
+		})
+		matchLines[i] = linesOfPositions(doc, c.positions)
+	}
+	si.mu.RUnlock()
+
+	for i := range results {
+		results[i].Matches = buildSnippets(results[i].Path, matchLines[i], clauses)
+	}
+
+	return results, nil
+}
+
+// clauseOccurrence is where a clause matched in a note
+type clauseOccurrence struct {
+	count     int   // Number of times the clause matched
+	positions []int // Positions of every matched term
+}
+
+// matchClauseLocked returns the notes matching a clause
+func (si *SearchIndex) matchClauseLocked(clause searchClause) map[string]*clauseOccurrence {
+	matches := make(map[string]*clauseOccurrence)
+
+	switch {
+	case clause.prefix:
+		for term, docs := range si.postings {
+			if !strings.HasPrefix(term, clause.terms[0]) {
+				continue
+			}
+			for relPath, positions := range docs {
+				occurrence := matches[relPath]
+				if occurrence == nil {
+					occurrence = &clauseOccurrence{}
+					matches[relPath] = occurrence
+				}
+				occurrence.count += len(positions)
+				occurrence.positions = append(occurrence.positions, positions...)
+			}
+		}
+
+	case len(clause.terms) == 1:
+		for relPath, positions := range si.postings[clause.terms[0]] {
+			matches[relPath] = &clauseOccurrence{count: len(positions), positions: positions}
+		}
+
+	default:
+		// Phrases match where the remaining terms follow the first one
+		for relPath, positions := range si.postings[clause.terms[0]] {
+			doc := si.docs[relPath]
+			for _, start := range positions {
+				if start+len(clause.terms) > len(doc.Terms) {
+					continue
+				}
+				matched := true
+				for k, term := range clause.terms[1:] {
+					if doc.Terms[start+k+1] != term {
+						matched = false
+						break
+					}
+				}
+				if !matched {
+					continue
+				}
+
+				occurrence := matches[relPath]
+				if occurrence == nil {
+					occurrence = &clauseOccurrence{}
+					matches[relPath] = occurrence
+				}
+				occurrence.count++
+				for k := range clause.terms {
+					occurrence.positions = append(occurrence.positions, start+k)
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// matchesTitle reports whether the title contains the clause terms
+func (c searchClause) matchesTitle(title string) bool {
+	titleTerms := searchTerms(title)
+	for _, term := range c.terms {
+		found := false
+		for _, titleTerm := range titleTerms {
+			if titleTerm == term || (c.prefix && strings.HasPrefix(titleTerm, term)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesWord reports whether a lowercased word from a note matches the clause
+func (c searchClause) matchesWord(word string) bool {
+	if c.prefix {
+		return strings.HasPrefix(stem(word), c.terms[0]) || strings.HasPrefix(word, c.terms[0])
+	}
+
+	term := stem(word)
+	for _, clauseTerm := range c.terms {
+		if term == clauseTerm {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSearchQuery splits a query into words, "quoted phrases" and prefix* terms
+func parseSearchQuery(query string) []searchClause {
+	clauses := make([]searchClause, 0)
+
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+
+		if query[0] == '"' {
+			phrase := query[1:]
+			query = ""
+			if end := strings.IndexByte(phrase, '"'); end >= 0 {
+				phrase, query = phrase[:end], phrase[end+1:]
+			}
+			if terms := searchTerms(phrase); len(terms) > 0 {
+				clauses = append(clauses, searchClause{terms: terms})
+			}
+			continue
+		}
+
+		word := query
+		query = ""
+		if end := strings.IndexFunc(word, unicode.IsSpace); end >= 0 {
+			word, query = word[:end], word[end:]
+		}
+
+		if strings.HasSuffix(word, "*") {
+			word = strings.TrimRight(word, "*")
+			if tokens := tokenize(word); len(tokens) == 1 {
+				clauses = append(clauses, searchClause{terms: []string{tokens[0].Word}, prefix: true})
+				continue
+			}
+		}
+
+		// Words joined by punctuation, like "follow-up", match as a phrase
+		if terms := searchTerms(word); len(terms) > 0 {
+			clauses = append(clauses, searchClause{terms: terms})
+		}
+	}
+
+	return clauses
+}
+
+// linesOfPositions returns the sorted distinct lines of term positions
+func linesOfPositions(doc *indexedDoc, positions []int) []int {
+	seen := make(map[int]bool)
+	lines := make([]int, 0, maxMatchesPerResult)
+	for _, position := range positions {
+		if position < len(doc.Lines) && !seen[doc.Lines[position]] {
+			seen[doc.Lines[position]] = true
+			lines = append(lines, doc.Lines[position])
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// buildSnippets reads the matched lines of a note and highlights the matched words
+func buildSnippets(path string, lines []int, clauses []searchClause) []SearchMatch {
+	matches := make([]SearchMatch, 0, maxMatchesPerResult)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return matches
+	}
+	fileLines := strings.Split(string(content), "\n")
+
+	for _, lineNumber := range lines {
+		if len(matches) == maxMatchesPerResult {
+			break
+		}
+		if lineNumber < 1 || lineNumber > len(fileLines) {
+			continue
+		}
+
+		line := strings.TrimRight(fileLines[lineNumber-1], "\r")
+		highlights := make([][2]int, 0)
+		for _, token := range tokenize(line) {
+			for _, clause := range clauses {
+				if clause.matchesWord(token.Word) {
+					highlights = append(highlights, [2]int{token.Start, token.End})
+					break
+				}
+			}
+		}
+		if len(highlights) == 0 {
+			// The note changed since it was indexed
+			continue
+		}
+
+		snippet, highlights := trimSnippet(line, highlights)
+		matches = append(matches, SearchMatch{
+			Line:       lineNumber,
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+
+	return matches
+}
+
+// trimSnippet trims surrounding whitespace and shortens long lines to a window
+// around the first highlight, shifting the highlights to match
+func trimSnippet(line string, highlights [][2]int) (string, [][2]int) {
+	start := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+	end := len(strings.TrimRightFunc(line, unicode.IsSpace))
+
+	if end-start > maxSnippetLength {
+		start = highlights[0][0] - maxSnippetLength/4
+		if start < 0 {
+			start = 0
+		}
+		for start > 0 && !utf8.RuneStart(line[start]) {
+			start--
+		}
+		if start+maxSnippetLength < end {
+			end = start + maxSnippetLength
+			for end > start && !utf8.RuneStart(line[end]) {
+				end--
+			}
+		}
+	}
+
+	shifted := make([][2]int, 0, len(highlights))
+	for _, h := range highlights {
+		if h[0] >= start && h[1] <= end {
+			shifted = append(shifted, [2]int{h[0] - start, h[1] - start})
+		}
+	}
+
+	return line[start:end], shifted
+}
+
+// indexNote reads and tokenizes a note
+func indexNote(path string) (*indexedDoc, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := tokenize(string(content))
+	doc := &indexedDoc{
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		Title:   firstHeading(content),
+		Terms:   make([]string, len(tokens)),
+		Lines:   make([]int, len(tokens)),
+	}
+	if doc.Title == "" {
+		doc.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i, token := range tokens {
+		doc.Terms[i] = stem(token.Word)
+		doc.Lines[i] = token.Line
+	}
+
+	return doc, nil
+}
+
+// putDocLocked adds or replaces a note in the index
+func (si *SearchIndex) putDocLocked(relPath string, doc *indexedDoc) {
+	si.removeDocLocked(relPath)
+
+	si.docs[relPath] = doc
+	si.totalTerms += len(doc.Terms)
+	for position, term := range doc.Terms {
+		docs := si.postings[term]
+		if docs == nil {
+			docs = make(map[string][]int)
+			si.postings[term] = docs
+		}
+		docs[relPath] = append(docs[relPath], position)
+	}
+}
+
+// removeDocLocked removes a note from the index
+func (si *SearchIndex) removeDocLocked(relPath string) {
+	doc, ok := si.docs[relPath]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		if docs, ok := si.postings[term]; ok {
+			delete(docs, relPath)
+			if len(docs) == 0 {
+				delete(si.postings, term)
+			}
+		}
+	}
+	si.totalTerms -= len(doc.Terms)
+	delete(si.docs, relPath)
+}
+
+// relativePath converts an absolute path into a slash-separated path within the vault
+func (si *SearchIndex) relativePath(path string) (string, bool) {
+	return relativeVaultPath(si.root, path)
+}
+
+// scheduleSave persists the index once updates settle
+func (si *SearchIndex) scheduleSave() {
+	si.saveMu.Lock()
+	defer si.saveMu.Unlock()
+
+	if si.saveTimer != nil {
+		si.saveTimer.Stop()
+	}
+	si.saveTimer = time.AfterFunc(searchIndexSaveDelay, func() {
+		if err := si.Save(); err != nil {
+			fmt.Printf("Warning: Unable to save search index: %v\n", err)
+		}
+	})
+}
+
+// Save writes the index to disk, replacing the previous file atomically
+func (si *SearchIndex) Save() error {
+	si.mu.RLock()
+	stored := searchIndexFile{
+		Version: searchIndexVersion,
+		Docs:    make(map[string]*indexedDoc, len(si.docs)),
+	}
+	for relPath, doc := range si.docs {
+		stored.Docs[relPath] = doc
+	}
+	si.mu.RUnlock()
+
+	tempFile, err := os.CreateTemp(filepath.Dir(si.path), "search_index-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating search index file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if err := gob.NewEncoder(tempFile).Encode(&stored); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("error writing search index: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("error writing search index: %w", err)
+	}
+
+	if err := os.Rename(tempFile.Name(), si.path); err != nil {
+		return fmt.Errorf("error saving search index: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes pending changes to disk
+func (si *SearchIndex) Close() error {
+	si.saveMu.Lock()
+	pending := si.saveTimer != nil && si.saveTimer.Stop()
+	si.saveTimer = nil
+	si.saveMu.Unlock()
+
+	if pending {
+		return si.Save()
+	}
+	return nil
+}
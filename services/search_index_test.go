@@ -0,0 +1,92 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// newTestSearchIndex writes the notes into a temp vault and indexes them
+func newTestSearchIndex(t *testing.T, notes map[string]string) (*SearchIndex, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range notes {
+		writeTestFile(t, filepath.Join(root, filepath.FromSlash(name)), content)
+	}
+	index := NewSearchIndex(root, filepath.Join(t.TempDir(), "search_index.gob"))
+	if err := index.Refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	return index, root
+}
+
+// indexedPaths returns the sorted relative paths of the indexed notes
+func indexedPaths(index *SearchIndex) []string {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	paths := make([]string, 0, len(index.docs))
+	for relPath := range index.docs {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestFilesChangedDirectoryRefreshesSubtree(t *testing.T) {
+	index, root := newTestSearchIndex(t, map[string]string{
+		"a/old.md":     "# Old\n",
+		"b/stale.md":   "# Stale\n",
+		"c/keep.md":    "# Keep\n",
+		"top-level.md": "# Top\n",
+	})
+
+	// Changes outside the reported directory are left for their own events
+	if err := os.Remove(filepath.Join(root, "b", "stale.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "a", "old.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, "a", "nested", "new.md"), "# New\n")
+	writeTestFile(t, filepath.Join(root, "a", ".hidden", "skip.md"), "# Hidden\n")
+	writeTestFile(t, filepath.Join(root, ".obsidian", "plugins", "skip.md"), "# Hidden\n")
+
+	index.FilesChanged([]string{filepath.Join(root, "a"), filepath.Join(root, ".obsidian", "plugins")})
+
+	want := []string{"a/nested/new.md", "b/stale.md", "c/keep.md", "top-level.md"}
+	got := indexedPaths(index)
+	if len(got) != len(want) {
+		t.Fatalf("indexed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("indexed %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCloseSavesPendingUpdates(t *testing.T) {
+	index, root := newTestSearchIndex(t, map[string]string{"note.md": "# Note\n"})
+
+	notePath := filepath.Join(root, "added.md")
+	writeTestFile(t, notePath, "# Added\n\nquokka\n")
+	index.FilesChanged([]string{notePath})
+	if err := index.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	reloaded := NewSearchIndex(root, index.path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	results, err := reloaded.Search("quokka", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RelativePath != "added.md" {
+		t.Fatalf("results = %+v, want the note added before closing", results)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchToken is a word in a note with its location
+type searchToken struct {
+	Word  string // Lowercased word
+	Line  int    // 1-based line number
+	Start int    // Byte offset of the word within its line
+	End   int
+}
+
+// tokenize splits text into lowercased words of letters and digits
+func tokenize(text string) []searchToken {
+	tokens := make([]searchToken, 0, len(text)/6)
+	line := 1
+	lineStart := 0
+	wordStart := -1
+
+	flush := func(end int) {
+		if wordStart >= 0 {
+			tokens = append(tokens, searchToken{
+				Word:  strings.ToLower(text[wordStart:end]),
+				Line:  line,
+				Start: wordStart - lineStart,
+				End:   end - lineStart,
+			})
+			wordStart = -1
+		}
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+
+		flush(i)
+		if r == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// stem reduces an English word to its stem with a light suffix-stripping stemmer,
+// so "notes", "noted" and "noting" all match "note"
+func stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+
+	// Derivational suffixes
+	for _, rule := range [][2]string{
+		{"ational", "ate"},
+		{"ization", "ize"},
+		{"iveness", "ive"},
+		{"fulness", "ful"},
+		{"ousness", "ous"},
+		{"alism", "al"},
+		{"ation", "ate"},
+		{"ness", ""},
+	} {
+		if strings.HasSuffix(word, rule[0]) && len(word)-len(rule[0]) >= 3 {
+			word = word[:len(word)-len(rule[0])] + rule[1]
+			break
+		}
+	}
+
+	// Plurals
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		word = word[:len(word)-1]
+	}
+
+	// Past tense and gerunds, only when a vowel remains in the stem
+	for _, suffix := range []string{"ing", "ed"} {
+		base := strings.TrimSuffix(word, suffix)
+		if base == word || len(base) < 3 || !strings.ContainsAny(base, "aeiouy") {
+			continue
+		}
+
+		word = base
+		last := word[len(word)-1]
+		switch {
+		case strings.HasSuffix(word, "at") || strings.HasSuffix(word, "bl") || strings.HasSuffix(word, "iz"):
+			word += "e"
+		case len(word) >= 2 && word[len(word)-2] == last && !strings.ContainsRune("aeiouylsz", rune(last)):
+			// Undouble the final consonant: "stopped" -> "stop"
+			word = word[:len(word)-1]
+		case len(word) == 3 && isConsonant(word[0]) && !isConsonant(word[1]) &&
+			isConsonant(last) && !strings.ContainsRune("wxy", rune(last)):
+			// Restore the e of short words: "noted" -> "note"
+			word += "e"
+		}
+		break
+	}
+
+	// A trailing silent e is dropped so "note" and "noted" share a stem
+	if strings.HasSuffix(word, "e") && len(word) > 4 {
+		word = word[:len(word)-1]
+	}
+
+	return word
+}
+
+// isConsonant reports whether the ASCII letter is a consonant
+func isConsonant(c byte) bool {
+	return !strings.ContainsRune("aeiou", rune(c))
+}
+
+// searchTerms returns the stemmed terms of text in order
+func searchTerms(text string) []string {
+	tokens := tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = stem(token.Word)
+	}
+	return terms
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event names emitted to the frontend
+const (
+	EventSyncStatus   = "gitnotes:sync:status"   // Every sync status transition
+	EventSyncConflict = "gitnotes:sync:conflict" // Conflicts detected after a pull
+	EventSyncProgress = "gitnotes:sync:progress" // Object/byte progress of clone, pull and push
+	EventSyncError    = "gitnotes:sync:error"    // A sync step failed
+)
+
+// EventEmitter publishes backend events to the frontend
+type EventEmitter interface {
+	Emit(name string, data interface{})
+}
+
+// EventEmitterFunc adapts a function to the EventEmitter interface
+type EventEmitterFunc func(name string, data interface{})
+
+// Emit calls the function
+func (f EventEmitterFunc) Emit(name string, data interface{}) {
+	f(name, data)
+}
+
+// SyncStatusEvent is the payload of EventSyncStatus and EventSyncError
+type SyncStatusEvent struct {
+	Status    SyncStatus `json:"status"`
+	Message   string     `json:"message"`
+	Error     string     `json:"error,omitempty"`
+	Details   string     `json:"details,omitempty"` // Human-readable hint for errors
+	Timestamp time.Time  `json:"timestamp"`
+	VaultID   string     `json:"vaultId,omitempty"`
+}
+
+// SyncConflictEvent is the payload of EventSyncConflict
+type SyncConflictEvent struct {
+	Files     []string       `json:"files"`
+	Conflicts []ConflictInfo `json:"conflicts,omitempty"` // Per-file index stages
+	Strategy  string         `json:"strategy"`
+	Timestamp time.Time      `json:"timestamp"`
+	VaultID   string         `json:"vaultId,omitempty"`
+}
+
+// SyncProgressEvent is the payload of EventSyncProgress
+type SyncProgressEvent struct {
+	Operation string `json:"operation"` // clone, pull or push
+	Phase     string `json:"phase"`     // e.g. "Receiving objects"
+	Percent   int    `json:"percent"`
+	Current   int64  `json:"current"`
+	Total     int64  `json:"total"`
+	Bytes     int64  `json:"bytes,omitempty"` // Bytes transferred so far, when reported
+	Done      bool   `json:"done"`
+	VaultID   string `json:"vaultId,omitempty"`
+}
+
+// progressLinePattern matches git sideband progress lines such as
+// "Receiving objects:  45% (45/100), 1.20 MiB | 2.00 MiB/s"
+var progressLinePattern = regexp.MustCompile(`^(?:remote:\s*)?([A-Za-z ]+):\s+(\d+)% \((\d+)/(\d+)\)(?:,\s*([\d.]+) ([KMG]i)?B)?`)
+
+// progressWriter parses git sideband progress output into progress events
+type progressWriter struct {
+	operation string
+	emitter   EventEmitter
+	buffer    []byte
+	mu        sync.Mutex
+}
+
+// newProgressWriter returns a writer that emits progress events for the operation,
+// or nil when there is no emitter so go-git skips progress reporting entirely
+func newProgressWriter(operation string, emitter EventEmitter) io.Writer {
+	if emitter == nil {
+		return nil
+	}
+	return &progressWriter{
+		operation: operation,
+		emitter:   emitter,
+	}
+}
+
+// Write buffers output and emits an event for every complete progress line.
+// Git terminates in-place updates with \r and finished phases with \n.
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.buffer = append(pw.buffer, p...)
+	for {
+		idx := strings.IndexAny(string(pw.buffer), "\r\n")
+		if idx < 0 {
+			break
+		}
+
+		line := string(pw.buffer[:idx])
+		pw.buffer = pw.buffer[idx+1:]
+
+		if event, ok := parseProgressLine(pw.operation, line); ok {
+			pw.emitter.Emit(EventSyncProgress, event)
+		}
+	}
+
+	return len(p), nil
+}
+
+// parseProgressLine parses a single sideband progress line
+func parseProgressLine(operation, line string) (SyncProgressEvent, bool) {
+	match := progressLinePattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return SyncProgressEvent{}, false
+	}
+
+	percent, _ := strconv.Atoi(match[2])
+	current, _ := strconv.ParseInt(match[3], 10, 64)
+	total, _ := strconv.ParseInt(match[4], 10, 64)
+
+	event := SyncProgressEvent{
+		Operation: operation,
+		Phase:     strings.TrimSpace(match[1]),
+		Percent:   percent,
+		Current:   current,
+		Total:     total,
+		Done:      strings.Contains(line, "done"),
+	}
+
+	if match[5] != "" {
+		size, err := strconv.ParseFloat(match[5], 64)
+		if err == nil {
+			switch match[6] {
+			case "Ki":
+				size *= 1 << 10
+			case "Mi":
+				size *= 1 << 20
+			case "Gi":
+				size *= 1 << 30
+			}
+			event.Bytes = int64(size)
+		}
+	}
+
+	return event, true
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncHistoryQuery filters persisted sync history
+type SyncHistoryQuery struct {
+	Since    time.Time    // Only entries at or after this time (zero means unbounded)
+	Until    time.Time    // Only entries before this time (zero means unbounded)
+	Statuses []SyncStatus // Only entries with one of these statuses (empty means any)
+	File     string       // Only entries that changed or conflicted on this file
+	Offset   int          // Number of matching entries to skip, newest first
+	Limit    int          // Maximum number of entries to return (0 means 50)
+}
+
+// SyncHistoryPage is a page of sync history, newest first
+type SyncHistoryPage struct {
+	Entries []SyncHistoryEntry `json:"entries"`
+	Total   int                `json:"total"` // Total number of matching entries
+	Offset  int                `json:"offset"`
+	Limit   int                `json:"limit"`
+}
+
+// SyncHistoryStore persists sync history entries to an append-only JSON lines file
+type SyncHistoryStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewSyncHistoryStore creates a new SyncHistoryStore writing to the given file
+func NewSyncHistoryStore(path string) *SyncHistoryStore {
+	return &SyncHistoryStore{
+		path: path,
+	}
+}
+
+// NewRepositoryHistoryStore creates the history store for a repository
+func NewRepositoryHistoryStore(repoPath string) (*SyncHistoryStore, error) {
+	dataDir, err := repoDataDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSyncHistoryStore(filepath.Join(dataDir, "sync_history.jsonl")), nil
+}
+
+// Append writes an entry to the end of the log
+func (hs *SyncHistoryStore) Append(entry SyncHistoryEntry) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshaling history entry: %w", err)
+	}
+
+	file, err := os.OpenFile(hs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening history log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing history log: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns the entries matching the query, newest first
+func (hs *SyncHistoryStore) Query(query SyncHistoryQuery) (SyncHistoryPage, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	page := SyncHistoryPage{
+		Entries: []SyncHistoryEntry{},
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+	}
+
+	file, err := os.Open(hs.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return page, nil
+	}
+	if err != nil {
+		return page, fmt.Errorf("error opening history log: %w", err)
+	}
+	defer file.Close()
+
+	// The log is in chronological order, collect matches and reverse afterwards
+	matches := make([]SyncHistoryEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry SyncHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a torn line from a crash mid-append
+			continue
+		}
+		if query.matches(entry) {
+			matches = append(matches, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return page, fmt.Errorf("error reading history log: %w", err)
+	}
+
+	page.Total = len(matches)
+	for i := len(matches) - 1 - query.Offset; i >= 0 && len(page.Entries) < query.Limit; i-- {
+		page.Entries = append(page.Entries, matches[i])
+	}
+
+	return page, nil
+}
+
+// matches reports whether the entry passes the query filters
+func (q SyncHistoryQuery) matches(entry SyncHistoryEntry) bool {
+	if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !entry.Timestamp.Before(q.Until) {
+		return false
+	}
+
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, status := range q.Statuses {
+			if entry.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.File != "" {
+		found := false
+		for _, files := range [][]string{entry.FilesChanged, entry.ConflictFiles, entry.ConflictCopies} {
+			for _, file := range files {
+				if file == q.File {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isTerminalStatus reports whether a status ends a sync operation
+func isTerminalStatus(status SyncStatus) bool {
+	return status == SyncStatusSuccess ||
+		status == SyncStatusError ||
+		status == SyncStatusConflict
+}
+
+// errorClass returns a short classification of a sync error for history filtering
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrAuthenticationFailed):
+		return "authentication"
+	case errors.Is(err, ErrNetworkIssue):
+		return "network"
+	case errors.Is(err, ErrMergeConflict):
+		return "conflict"
+	case errors.Is(err, ErrRemoteNotFound):
+		return "remote_not_found"
+	case errors.Is(err, ErrLocalChanges):
+		return "local_changes"
+	default:
+		return "other"
+	}
+}
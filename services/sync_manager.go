@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 // We don't need to redeclare ErrMergeConflict since it's already defined in git_service.go
@@ -39,15 +40,30 @@ const (
 	ConflictStrategyOurs   = "ours"   // Use our/local changes
 	ConflictStrategyTheirs = "theirs" // Use their/remote changes
 	ConflictStrategyBoth   = "both"   // Keep both changes with conflict markers
+	ConflictStrategyCopy   = "copy"   // Keep their changes and save ours as a conflicted copy
 )
 
 // SyncHistoryEntry represents a single sync operation in the history
 type SyncHistoryEntry struct {
-	Timestamp     time.Time  `json:"timestamp"`
-	Status        SyncStatus `json:"status"`
-	Message       string     `json:"message"`
-	Error         string     `json:"error,omitempty"`
-	ConflictFiles []string   `json:"conflictFiles,omitempty"` // List of files with conflicts
+	Timestamp      time.Time  `json:"timestamp"`
+	Status         SyncStatus `json:"status"`
+	Message        string     `json:"message"`
+	Error          string     `json:"error,omitempty"`
+	ConflictFiles  []string   `json:"conflictFiles,omitempty"`  // List of files with conflicts
+	RestoredHead   string     `json:"restoredHead,omitempty"`   // Commit restored when a sync was aborted
+	HeadBefore     string     `json:"headBefore,omitempty"`     // Local HEAD when the sync started
+	HeadAfter      string     `json:"headAfter,omitempty"`      // Local HEAD when the sync finished
+	FilesChanged   []string   `json:"filesChanged,omitempty"`   // Files changed between HeadBefore and HeadAfter
+	DurationMs     int64      `json:"durationMs,omitempty"`     // Duration of the sync operation
+	ErrorClass     string     `json:"errorClass,omitempty"`     // Error category (authentication, network, ...)
+	ConflictCopies []string   `json:"conflictCopies,omitempty"` // Conflicted copies created for local versions
+}
+
+// syncRun tracks a sync operation in progress for its history entry
+type syncRun struct {
+	start          time.Time
+	headBefore     plumbing.Hash
+	conflictCopies []string // Conflicted copies created during the sync
 }
 
 // SyncManager handles Git synchronization operations and maintains status
@@ -61,8 +77,18 @@ type SyncManager struct {
 	syncHistory      []SyncHistoryEntry
 	maxHistorySize   int
 	conflictStrategy ConflictStrategy
-	currentConflicts []string // Current detected conflicts
+	currentConflicts []string       // Current detected conflicts
+	conflictInfo     []ConflictInfo // Index stages of the current conflicts
 	lastError        error
+	prePullHead      plumbing.Hash     // Local HEAD recorded before the last pull
+	prePullUntracked map[string][]byte // Untracked files captured before the last pull
+	emitter          EventEmitter      // Publishes status, conflict and progress events
+	historyStore     *SyncHistoryStore // Persists history across restarts
+	activeRun        *syncRun          // Sync operation in progress, if any
+	messageGenerator *CommitMessageGenerator
+	mergeDriver      MergeDriver   // Merges conflicted files before the conflict strategy applies
+	lock             *VaultLock    // Keeps file writes out of the steps that change the working tree
+	observers        fileObservers // Notified of files changed by pulls and aborts
 }
 
 // NewSyncManager creates a new SyncManager to manage Git synchronization
@@ -78,21 +104,87 @@ func NewSyncManager(gitService *GitService) *SyncManager {
 		maxHistorySize:   100,                    // Keep last 100 sync operations
 		conflictStrategy: ConflictStrategyManual, // Default to manual conflict resolution
 		currentConflicts: nil,
+		mergeDriver:      NewMarkdownMergeDriver(),
 	}
 }
 
-// updateStatus updates the current sync status and adds an entry to history
-func (sm *SyncManager) updateStatus(status SyncStatus, message string, err error) {
+// SetVaultLock sets the lock shared with file writes. Staging waits for writes in
+// progress and writes wait until the pull is done.
+func (sm *SyncManager) SetVaultLock(lock *VaultLock) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	sm.currentStatus = status
+	sm.lock = lock
+}
 
-	// Only update last sync time when operation is successful
-	if status == SyncStatusSuccess {
-		sm.lastSyncTime = time.Now()
+// vaultLock returns the lock shared with file writes
+func (sm *SyncManager) vaultLock() *VaultLock {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return sm.lock
+}
+
+// SetMergeDriver sets the driver that merges conflicted files, nil disables automatic merging
+func (sm *SyncManager) SetMergeDriver(driver MergeDriver) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.mergeDriver = driver
+}
+
+// SetCommitMessageGenerator sets the generator used for auto-commit messages
+func (sm *SyncManager) SetCommitMessageGenerator(generator *CommitMessageGenerator) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.messageGenerator = generator
+}
+
+// autoCommitMessage describes the staged changes, falling back to a generic message
+func (sm *SyncManager) autoCommitMessage() string {
+	sm.mu.Lock()
+	generator := sm.messageGenerator
+	sm.mu.Unlock()
+
+	if generator == nil {
+		generator, _ = NewCommitMessageGenerator("")
 	}
 
+	changes, err := sm.gitService.StagedChanges()
+	if err != nil {
+		fmt.Printf("Warning: Could not list staged changes: %v\n", err)
+		return "Auto-commit by GitNotes"
+	}
+
+	message, err := generator.Generate(changes)
+	if err != nil {
+		fmt.Printf("Warning: Could not generate commit message: %v\n", err)
+		return "Auto-commit by GitNotes"
+	}
+
+	return message
+}
+
+// SetEventEmitter sets the emitter used to push sync events to the frontend
+func (sm *SyncManager) SetEventEmitter(emitter EventEmitter) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.emitter = emitter
+	sm.gitService.SetEventEmitter(emitter)
+}
+
+// SetHistoryStore sets the store used to persist sync history
+func (sm *SyncManager) SetHistoryStore(store *SyncHistoryStore) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.historyStore = store
+}
+
+// updateStatus updates the current sync status and adds an entry to history
+func (sm *SyncManager) updateStatus(status SyncStatus, message string, err error) {
 	// Create history entry
 	entry := SyncHistoryEntry{
 		Timestamp: time.Now(),
@@ -102,6 +194,83 @@ func (sm *SyncManager) updateStatus(status SyncStatus, message string, err error
 
 	if err != nil {
 		entry.Error = err.Error()
+		entry.ErrorClass = errorClass(err)
+	}
+
+	sm.mu.Lock()
+	record := sm.recordStatusLocked(entry)
+
+	// Errors get a dedicated event so the UI can show a toast
+	if status == SyncStatusError {
+		event := SyncStatusEvent{
+			Status:    status,
+			Message:   message,
+			Error:     entry.Error,
+			Timestamp: entry.Timestamp,
+		}
+		var gitErr *GitError
+		if errors.As(err, &gitErr) {
+			event.Details = gitErr.Details
+		}
+		record.emit(EventSyncError, event)
+	}
+	sm.mu.Unlock()
+
+	record.publish()
+}
+
+// statusRecord is the history entry to persist and the events to emit for a status
+// change. It is built while holding sm.mu and published after releasing it, so slow
+// history writes and event handlers don't block the sync manager.
+type statusRecord struct {
+	store   *SyncHistoryStore
+	emitter EventEmitter
+	persist *SyncHistoryEntry
+	events  []emittedEvent
+}
+
+// emittedEvent is an event waiting to be emitted
+type emittedEvent struct {
+	name string
+	data interface{}
+}
+
+// newStatusRecordLocked starts a record for the current store and emitter. The caller
+// must hold sm.mu.
+func (sm *SyncManager) newStatusRecordLocked() *statusRecord {
+	return &statusRecord{store: sm.historyStore, emitter: sm.emitter}
+}
+
+// emit queues an event
+func (r *statusRecord) emit(name string, data interface{}) {
+	r.events = append(r.events, emittedEvent{name: name, data: data})
+}
+
+// publish persists the entry and emits the events. The caller must not hold sm.mu.
+func (r *statusRecord) publish() {
+	if r.persist != nil && r.store != nil {
+		if err := r.store.Append(*r.persist); err != nil {
+			fmt.Printf("Warning: Unable to persist sync history: %v\n", err)
+		}
+	}
+
+	if r.emitter != nil {
+		for _, event := range r.events {
+			r.emitter.Emit(event.name, event.data)
+		}
+	}
+}
+
+// recordStatusLocked sets the current status from the entry and appends it to history.
+// The caller must hold sm.mu and publish the returned record after releasing it.
+func (sm *SyncManager) recordStatusLocked(entry SyncHistoryEntry) *statusRecord {
+	record := sm.newStatusRecordLocked()
+	status := entry.Status
+	sm.currentStatus = status
+
+	// Only update last sync time when operation is successful
+	if status == SyncStatusSuccess {
+		sm.lastSyncTime = time.Now()
 	}
 
 	// Add conflict info if available
@@ -115,6 +284,20 @@ func (sm *SyncManager) updateStatus(status SyncStatus, message string, err error
 	if len(sm.syncHistory) > sm.maxHistorySize {
 		sm.syncHistory = sm.syncHistory[len(sm.syncHistory)-sm.maxHistorySize:]
 	}
+
+	// Outside of a sync run, persist terminal entries right away;
+	// during a run the final entry is persisted with the run details
+	if sm.activeRun == nil && (isTerminalStatus(status) || entry.RestoredHead != "") {
+		record.persist = &entry
+	}
+
+	record.emit(EventSyncStatus, SyncStatusEvent{
+		Status:    status,
+		Message:   entry.Message,
+		Error:     entry.Error,
+		Timestamp: entry.Timestamp,
+	})
+	return record
 }
 
 // GetSyncStatus returns the current sync status information
@@ -149,6 +332,43 @@ func (sm *SyncManager) GetSyncStatus() string {
 	return status
 }
 
+// QuerySyncHistory returns persisted history entries matching the query, newest first.
+// Without a history store, the in-memory history is queried instead.
+func (sm *SyncManager) QuerySyncHistory(query SyncHistoryQuery) (SyncHistoryPage, error) {
+	sm.mu.Lock()
+	store := sm.historyStore
+	sm.mu.Unlock()
+
+	if store != nil {
+		return store.Query(query)
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = 50
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	history := sm.GetSyncHistory()
+	page := SyncHistoryPage{
+		Entries: []SyncHistoryEntry{},
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if !query.matches(history[i]) {
+			continue
+		}
+		if page.Total >= query.Offset && len(page.Entries) < query.Limit {
+			page.Entries = append(page.Entries, history[i])
+		}
+		page.Total++
+	}
+
+	return page, nil
+}
+
 // GetSyncHistory returns the sync operation history
 func (sm *SyncManager) GetSyncHistory() []SyncHistoryEntry {
 	sm.mu.Lock()
@@ -186,8 +406,98 @@ func (sm *SyncManager) TriggerManualSync() (string, error) {
 	}
 }
 
-// performSync executes the actual synchronization process
+// performSync executes the synchronization process and records it in the history
 func (sm *SyncManager) performSync(ctx context.Context) error {
+	sm.beginRun()
+	err := sm.runSyncSteps(ctx)
+	sm.finishRun(err)
+	return err
+}
+
+// AddFileObserver registers an observer notified of files changed by a sync or abort
+func (sm *SyncManager) AddFileObserver(observer FileObserver) {
+	sm.observers.add(observer)
+}
+
+// IsSyncing reports whether a sync operation is in progress
+func (sm *SyncManager) IsSyncing() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return sm.activeRun != nil
+}
+
+// beginRun starts tracking a sync operation
+func (sm *SyncManager) beginRun() {
+	// An unborn branch has no HEAD yet, which is fine for the history
+	headBefore, _ := sm.gitService.HeadCommit()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.activeRun = &syncRun{
+		start:      time.Now(),
+		headBefore: headBefore,
+	}
+}
+
+// finishRun completes the last history entry of the sync operation with the
+// commits before and after, changed files and duration, and persists it
+func (sm *SyncManager) finishRun(err error) {
+	headAfter, _ := sm.gitService.HeadCommit()
+
+	sm.mu.Lock()
+	run := sm.activeRun
+	sm.activeRun = nil
+	sm.mu.Unlock()
+
+	if run == nil {
+		return
+	}
+
+	filesChanged, changedErr := sm.gitService.ChangedFiles(run.headBefore, headAfter)
+	if changedErr != nil {
+		fmt.Printf("Warning: Unable to list changed files: %v\n", changedErr)
+	}
+
+	// Conflicted files were rewritten with markers even though HEAD didn't move
+	sm.mu.Lock()
+	touched := append(append([]string(nil), filesChanged...), sm.currentConflicts...)
+	sm.mu.Unlock()
+	sm.observers.notify(absolutePaths(sm.gitService.repoPath, touched)...)
+
+	sm.mu.Lock()
+	if len(sm.syncHistory) == 0 {
+		sm.mu.Unlock()
+		return
+	}
+
+	record := sm.newStatusRecordLocked()
+	entry := &sm.syncHistory[len(sm.syncHistory)-1]
+	if !run.headBefore.IsZero() {
+		entry.HeadBefore = run.headBefore.String()
+	}
+	if !headAfter.IsZero() {
+		entry.HeadAfter = headAfter.String()
+	}
+	entry.FilesChanged = filesChanged
+	if len(run.conflictCopies) > 0 {
+		entry.ConflictCopies = run.conflictCopies
+	}
+	entry.DurationMs = time.Since(run.start).Milliseconds()
+	if err != nil && entry.ErrorClass == "" {
+		entry.Error = err.Error()
+		entry.ErrorClass = errorClass(err)
+	}
+	finished := *entry
+	record.persist = &finished
+	sm.mu.Unlock()
+
+	record.publish()
+}
+
+// runSyncSteps executes the actual synchronization process
+func (sm *SyncManager) runSyncSteps(ctx context.Context) error {
 	// Check for local changes
 	sm.updateStatus(SyncStatusChecking, "Checking for local changes", nil)
 
@@ -195,6 +505,14 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 		return ctx.Err()
 	}
 
+	// Hold the vault from staging through the pull so no note is committed half-written
+	// and no write races the pull. Pushing doesn't touch the working tree.
+	release, err := sm.vaultLock().BeginSync(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	hasChanges, err := sm.gitService.HasLocalChanges()
 	if err != nil {
 		sm.updateStatus(SyncStatusError, "Failed to check for local changes", err)
@@ -221,7 +539,7 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 			return ctx.Err()
 		}
 
-		err = sm.gitService.CommitChanges("Auto-commit by GitNotes")
+		err = sm.gitService.CommitChanges(sm.autoCommitMessage())
 		if err != nil {
 			sm.updateStatus(SyncStatusError, "Failed to commit changes", err)
 			return err
@@ -235,12 +553,25 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 		return ctx.Err()
 	}
 
+	// Record the pre-pull state so an aborted merge can be rolled back
+	if err := sm.recordPrePullState(); err != nil {
+		sm.updateStatus(SyncStatusError, "Failed to record pre-pull state", err)
+		return err
+	}
+
 	// Pull changes from remote
 	err = sm.gitService.PullChanges()
 	if err != nil {
 		// For Git errors, unwrap to get the specific error type
 		var gitErr *GitError
 		if errors.As(err, &gitErr) && errors.Is(gitErr.Err, ErrMergeConflict) {
+			// Merge the files the driver can merge, the strategy only applies to the rest
+			merged, mergeErr := sm.autoMergeConflicts()
+			if mergeErr != nil {
+				sm.updateStatus(SyncStatusError, "Failed to merge conflicted files", mergeErr)
+				return mergeErr
+			}
+
 			// Detect and record the conflicted files
 			conflicts, detectErr := sm.DetectConflicts()
 			if detectErr != nil {
@@ -248,15 +579,23 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 				return detectErr
 			}
 
+			if len(conflicts) == 0 && merged > 0 {
+				sm.updateStatus(SyncStatusCommit, fmt.Sprintf("Committing %d automatically merged files", merged), nil)
+				if err := sm.gitService.CommitMerge(""); err != nil {
+					sm.updateStatus(SyncStatusError, "Failed to commit merged files", err)
+					return err
+				}
+			}
+
 			// If conflicts were found, handle based on strategy
 			if len(conflicts) > 0 {
 				switch sm.conflictStrategy {
 				case ConflictStrategyManual:
 					// Manual strategy requires user intervention, so stop here
 					return fmt.Errorf("merge conflicts detected: %w", err)
-				case ConflictStrategyOurs, ConflictStrategyTheirs, ConflictStrategyBoth:
+				case ConflictStrategyOurs, ConflictStrategyTheirs, ConflictStrategyBoth, ConflictStrategyCopy:
 					// Attempt to resolve with the selected strategy
-					resolveErr := sm.ResolveConflictWithStrategy(sm.conflictStrategy)
+					resolveErr := sm.resolveConflictWithStrategy(ctx, sm.conflictStrategy)
 					if resolveErr != nil {
 						sm.updateStatus(SyncStatusError, "Failed to auto-resolve conflicts", resolveErr)
 						return resolveErr
@@ -279,6 +618,8 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 		return fmt.Errorf("unresolved merge conflicts detected in %d files", len(conflicts))
 	}
 
+	release()
+
 	// Push changes
 	sm.updateStatus(SyncStatusPushing, "Pushing local changes to remote", nil)
 
@@ -297,6 +638,49 @@ func (sm *SyncManager) performSync(ctx context.Context) error {
 	return nil
 }
 
+// autoMergeConflicts merges conflicted files with the merge driver and marks the ones it
+// merged resolved. It returns how many files were merged.
+func (sm *SyncManager) autoMergeConflicts() (int, error) {
+	sm.mu.Lock()
+	driver := sm.mergeDriver
+	sm.mu.Unlock()
+
+	if driver == nil {
+		return 0, nil
+	}
+
+	conflicts, err := sm.gitService.Conflicts()
+	if err != nil {
+		return 0, err
+	}
+
+	merged := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		if conflict.Binary || conflict.Type != ConflictTypeContent {
+			continue
+		}
+
+		base, ours, theirs, err := sm.gitService.ConflictVersions(conflict)
+		if err != nil {
+			return len(merged), err
+		}
+		content, ok := driver.Merge(conflict.Path, base, ours, theirs)
+		if !ok {
+			continue
+		}
+
+		if err := sm.gitService.MarkResolved(conflict.Path, content); err != nil {
+			return len(merged), err
+		}
+		merged = append(merged, conflict.Path)
+	}
+
+	if len(merged) > 0 {
+		sm.observers.notify(absolutePaths(sm.gitService.repoPath, merged)...)
+	}
+	return len(merged), nil
+}
+
 // CancelSync cancels the current sync operation
 func (sm *SyncManager) CancelSync() {
 	sm.cancel()
@@ -305,60 +689,146 @@ func (sm *SyncManager) CancelSync() {
 	sm.updateStatus(SyncStatusIdle, "Sync operation cancelled", nil)
 }
 
-// DetectConflicts checks for merge conflicts and updates status if conflicts are found
-func (sm *SyncManager) DetectConflicts() ([]string, error) {
+// DetectConflicts checks the index for unmerged files and updates status if conflicts are found
+func (sm *SyncManager) DetectConflicts() ([]ConflictInfo, error) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
 
-	conflicts, err := sm.gitService.DetectConflicts()
+	conflicts, err := sm.gitService.Conflicts()
 	if err != nil {
+		sm.mu.Unlock()
 		return nil, err
 	}
 
 	// Update conflict status if conflicts were found
-	if len(conflicts) > 0 {
-		sm.currentConflicts = conflicts
-		sm.updateStatus(SyncStatusConflict, fmt.Sprintf("Detected %d files with conflicts", len(conflicts)), nil)
-	} else {
+	if len(conflicts) == 0 {
 		sm.currentConflicts = nil
+		sm.conflictInfo = nil
+		sm.mu.Unlock()
+		return conflicts, nil
 	}
 
+	files := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		files = append(files, conflict.Path)
+	}
+
+	sm.currentConflicts = files
+	sm.conflictInfo = conflicts
+	record := sm.recordStatusLocked(SyncHistoryEntry{
+		Timestamp: time.Now(),
+		Status:    SyncStatusConflict,
+		Message:   fmt.Sprintf("Detected %d files with conflicts", len(conflicts)),
+	})
+	record.emit(EventSyncConflict, SyncConflictEvent{
+		Files:     files,
+		Conflicts: conflicts,
+		Strategy:  string(sm.conflictStrategy),
+		Timestamp: time.Now(),
+	})
+	sm.mu.Unlock()
+
+	record.publish()
 	return conflicts, nil
 }
 
+// recordPrePullState remembers the local HEAD and untracked files before a pull. The
+// HEAD is also kept in ORIG_HEAD for aborting after a restart.
+func (sm *SyncManager) recordPrePullState() error {
+	head, err := sm.gitService.HeadCommit()
+	if err != nil {
+		return err
+	}
+	if err := sm.gitService.SetOrigHead(head); err != nil {
+		return err
+	}
+
+	untracked, err := sm.gitService.SnapshotUntrackedFiles()
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.prePullHead = head
+	sm.prePullUntracked = untracked
+	return nil
+}
+
 // AbortSync aborts the current sync operation if there are conflicts or errors
+// When conflicts are present the repository is restored to the local HEAD recorded
+// before the pull, the equivalent of git merge --abort. After a restart the merge
+// left in the repository is aborted, back to ORIG_HEAD.
 func (sm *SyncManager) AbortSync() error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
 
 	// Cancel any ongoing sync operation
 	sm.cancel()
 	// Create a new context for future operations
 	sm.ctx, sm.cancel = context.WithCancel(context.Background())
 
+	status := sm.currentStatus
+	conflicts := sm.currentConflicts
+	prePullHead := sm.prePullHead
+	prePullUntracked := sm.prePullUntracked
+	sm.mu.Unlock()
+
+	// The in-memory state is gone after a restart, the merge in progress isn't
+	mergeInProgress := sm.gitService.MergeInProgress()
+	if len(conflicts) == 0 && mergeInProgress {
+		conflicts, _ = sm.gitService.DetectConflicts()
+	}
+
 	// Only proceed with abort if we're in conflict or error state
-	if sm.currentStatus != SyncStatusConflict && sm.currentStatus != SyncStatusError {
+	if status != SyncStatusConflict && status != SyncStatusError && !mergeInProgress {
 		return fmt.Errorf("cannot abort sync: no conflict or error to resolve")
 	}
 
-	// If there are conflicts, reset the repository state
-	if len(sm.currentConflicts) > 0 {
-		// In a real implementation, we would use git operations to abort
-		// the merge or rebase operation. For simplicity, we're just using
-		// git.PlainOpen to get access to the repository and then would use
-		// the repository object to abort the merge.
-		//
-		// In practice, this would involve running git commands like:
-		// git merge --abort or git rebase --abort
-
-		// Here we'll just update status assuming the abort was successful
-		sm.updateStatus(SyncStatusIdle, "Sync aborted and conflicts cleared", nil)
-		sm.currentConflicts = nil
+	// If we're in error state without conflicts, reset to idle
+	if len(conflicts) == 0 && !mergeInProgress {
+		sm.updateStatus(SyncStatusIdle, "Sync operation aborted", nil)
 		return nil
 	}
 
-	// If we're in error state, reset to idle
-	sm.updateStatus(SyncStatusIdle, "Sync operation aborted", nil)
+	// An uncommitted merge leaves HEAD at the local commit, so that's the fallback
+	// when ORIG_HEAD is missing too
+	if prePullHead.IsZero() {
+		prePullHead = sm.gitService.OrigHead()
+	}
+	if prePullHead.IsZero() && mergeInProgress {
+		prePullHead, _ = sm.gitService.HeadCommit()
+	}
+
+	// Roll back the merge to the pre-pull state
+	headBeforeAbort, _ := sm.gitService.HeadCommit()
+	release, err := sm.vaultLock().BeginSync(context.Background())
+	if err != nil {
+		return err
+	}
+	err = sm.gitService.AbortMerge(prePullHead, prePullUntracked)
+	release()
+	if err != nil {
+		sm.updateStatus(SyncStatusError, "Failed to abort merge", err)
+		return fmt.Errorf("failed to abort merge: %w", err)
+	}
+
+	restored, _ := sm.gitService.ChangedFiles(prePullHead, headBeforeAbort)
+	sm.observers.notify(absolutePaths(sm.gitService.repoPath, append(restored, conflicts...))...)
+
+	sm.mu.Lock()
+	sm.currentConflicts = nil
+	sm.conflictInfo = nil
+	sm.prePullHead = plumbing.ZeroHash
+	sm.prePullUntracked = nil
+	record := sm.recordStatusLocked(SyncHistoryEntry{
+		Timestamp:    time.Now(),
+		Status:       SyncStatusIdle,
+		Message:      fmt.Sprintf("Sync aborted and conflicts cleared, restored %s", prePullHead.String()[:7]),
+		RestoredHead: prePullHead.String(),
+	})
+	sm.mu.Unlock()
+
+	record.publish()
 	return nil
 }
 
@@ -385,36 +855,46 @@ func (sm *SyncManager) GetConflictDetails() map[string]interface{} {
 		"hasConflicts":      true,
 		"conflictCount":     len(sm.currentConflicts),
 		"conflictFiles":     sm.currentConflicts,
+		"conflicts":         sm.conflictInfo,
 		"currentStrategy":   string(sm.conflictStrategy),
-		"resolutionOptions": []string{"manual", "ours", "theirs", "both"},
+		"resolutionOptions": []string{"manual", "ours", "theirs", "both", "copy"},
 	}
 }
 
 // ResolveConflictWithStrategy resolves conflicts using the specified strategy
 func (sm *SyncManager) ResolveConflictWithStrategy(strategy ConflictStrategy) error {
+	release, err := sm.vaultLock().BeginSync(sm.ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return sm.resolveConflictWithStrategy(sm.ctx, strategy)
+}
+
+// resolveConflictWithStrategy resolves conflicts using the specified strategy, stopping
+// when the context is cancelled
+func (sm *SyncManager) resolveConflictWithStrategy(ctx context.Context, strategy ConflictStrategy) error {
 	if strategy != ConflictStrategyOurs &&
 		strategy != ConflictStrategyTheirs &&
-		strategy != ConflictStrategyBoth {
+		strategy != ConflictStrategyBoth &&
+		strategy != ConflictStrategyCopy {
 		return fmt.Errorf("invalid conflict resolution strategy: %s", strategy)
 	}
 
 	sm.updateStatus(SyncStatusResolving, "Resolving conflicts with strategy: "+string(strategy), nil)
 
+	if strategy == ConflictStrategyCopy {
+		return sm.resolveWithConflictedCopies()
+	}
+
 	// If using the "both" strategy, create copies of the conflicted files with conflict markers
 	if strategy == ConflictStrategyBoth {
-		// Get list of conflicted files using git command
-		cmdList := exec.Command("git", "diff", "--name-only", "--diff-filter=U")
-		cmdList.Dir = sm.gitService.repoPath
-		output, err := cmdList.Output()
+		// Stage the conflicted files with their conflict markers as is
+		conflictedFiles, err := sm.gitService.StageConflictedFiles(ctx)
 		if err != nil {
-			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to list conflicted files: %v", err), err)
-			return fmt.Errorf("failed to list conflicted files: %w", err)
-		}
-
-		// Parse the output to get the list of conflicted files
-		conflictedFiles := []string{}
-		if len(output) > 0 {
-			conflictedFiles = strings.Split(strings.TrimSpace(string(output)), "\n")
+			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to stage conflicted files: %v", err), err)
+			return fmt.Errorf("failed to stage conflicted files: %w", err)
 		}
 
 		if len(conflictedFiles) == 0 {
@@ -422,20 +902,8 @@ func (sm *SyncManager) ResolveConflictWithStrategy(strategy ConflictStrategy) er
 			return nil // No conflicts to resolve
 		}
 
-		// For each conflicted file, add it to git with conflict markers
-		for _, file := range conflictedFiles {
-			// We just add the file with conflict markers as is
-			cmdAdd := exec.Command("git", "add", "-f", file)
-			cmdAdd.Dir = sm.gitService.repoPath
-			addOutput, err := cmdAdd.CombinedOutput()
-			if err != nil {
-				sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to stage conflicted file %s: %v", file, err), err)
-				return fmt.Errorf("failed to stage conflicted file %s: %w\nOutput: %s", file, err, string(addOutput))
-			}
-		}
-
-		// Create a commit with the conflict markers
-		err = sm.gitService.CommitChanges(fmt.Sprintf("Keep both changes (conflict markers preserved)"))
+		// Create the merge commit with the conflict markers
+		err = sm.gitService.CommitMerge("Keep both changes (conflict markers preserved)")
 		if err != nil {
 			sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to commit conflict markers: %v", err), err)
 			return fmt.Errorf("failed to commit conflict markers: %w", err)
@@ -446,13 +914,13 @@ func (sm *SyncManager) ResolveConflictWithStrategy(strategy ConflictStrategy) er
 	}
 
 	// For "ours" or "theirs" strategy, use the git service method
-	err := sm.gitService.ResolveConflictsWithStrategy(string(strategy))
+	err := sm.gitService.ResolveConflictsWithStrategy(ctx, string(strategy))
 	if err != nil {
 		sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to resolve conflicts using strategy %s: %v", strategy, err), err)
 		return fmt.Errorf("failed to resolve conflicts using strategy %s: %w", strategy, err)
 	}
 
-	err = sm.gitService.CommitChanges(fmt.Sprintf("Resolve conflicts using '%s' strategy", strategy))
+	err = sm.gitService.CommitMerge(fmt.Sprintf("Resolve conflicts using '%s' strategy", strategy))
 	if err != nil {
 		sm.updateStatus(SyncStatusError, fmt.Sprintf("Failed to commit resolved conflicts: %v", err), err)
 		return fmt.Errorf("failed to commit resolved conflicts: %w", err)
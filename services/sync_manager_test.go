@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newConflictedMerge returns a repository left mid-merge by the git CLI with a
+// conflict in note.md, and the local HEAD before the merge
+func newConflictedMerge(t *testing.T) (string, string) {
+	t.Helper()
+	requireGit(t)
+
+	dir := initTestRepo(t, map[string]string{"note.md": "base\n"})
+	mustRunGit(t, dir, "checkout", "-q", "-b", "remote")
+	writeTestFile(t, filepath.Join(dir, "note.md"), "theirs\n")
+	mustRunGit(t, dir, "commit", "-q", "-am", "Remote change")
+	mustRunGit(t, dir, "checkout", "-q", "master")
+	writeTestFile(t, filepath.Join(dir, "note.md"), "ours\n")
+	mustRunGit(t, dir, "commit", "-q", "-am", "Local change")
+	head := mustRunGit(t, dir, "rev-parse", "HEAD")
+
+	if output, err := runGit(t, dir, "merge", "remote"); err == nil {
+		t.Fatalf("merge succeeded without a conflict:\n%s", output)
+	}
+	return dir, head
+}
+
+func TestAbortSyncAfterRestart(t *testing.T) {
+	tests := []struct {
+		name           string
+		removeOrigHead bool
+	}{
+		{name: "from ORIG_HEAD"},
+		{name: "from HEAD of the uncommitted merge", removeOrigHead: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, head := newConflictedMerge(t)
+			if tt.removeOrigHead {
+				if err := os.Remove(filepath.Join(dir, ".git", "ORIG_HEAD")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A new sync manager has no conflict state in memory, like after a restart
+			gitService, err := NewGitService(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sm := NewSyncManager(gitService)
+			if err := sm.AbortSync(); err != nil {
+				t.Fatalf("abort sync: %v", err)
+			}
+
+			if got := mustRunGit(t, dir, "rev-parse", "HEAD"); got != head {
+				t.Errorf("HEAD = %s, want %s", got, head)
+			}
+			if got := readTestFile(t, filepath.Join(dir, "note.md")); got != "ours\n" {
+				t.Errorf("note.md = %q, want the local version", got)
+			}
+			if gitService.MergeInProgress() {
+				t.Error("merge still in progress")
+			}
+			if conflicts, _ := gitService.DetectConflicts(); len(conflicts) != 0 {
+				t.Errorf("conflicts left: %v", conflicts)
+			}
+		})
+	}
+}
+
+func TestAbortSyncWithoutMerge(t *testing.T) {
+	gitService, err := NewGitService(initTestRepo(t, map[string]string{"note.md": "base\n"}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSyncManager(gitService).AbortSync(); err == nil {
+		t.Fatal("abort sync succeeded without a conflict")
+	}
+}
+
+// divergedClone returns a clone whose note edits diverge from edits another clone
+// already pushed to the shared remote
+func divergedClone(t *testing.T, base string, pushed, local map[string]string) (*GitService, string) {
+	t.Helper()
+
+	remoteDir := newTestRemote(t, map[string]string{"note.md": base})
+	gitService := cloneTestRemote(t, remoteDir)
+	other := cloneTestRemote(t, remoteDir)
+	for name, content := range pushed {
+		writeTestFile(t, filepath.Join(other.repoPath, name), content)
+	}
+	if _, err := NewSyncManager(other).TriggerManualSync(); err != nil {
+		t.Fatalf("sync other clone: %v", err)
+	}
+
+	for name, content := range local {
+		writeTestFile(t, filepath.Join(gitService.repoPath, name), content)
+	}
+	return gitService, remoteDir
+}
+
+// testExecutors returns the executors merges are tested with
+func testExecutors(t *testing.T) map[string]func(gitService *GitService) GitExecutor {
+	executors := map[string]func(gitService *GitService) GitExecutor{
+		"go-git": func(*GitService) GitExecutor { return nil },
+	}
+	if _, err := exec.LookPath("git"); err == nil {
+		executors["cli"] = func(gitService *GitService) GitExecutor {
+			executor, err := NewCLIGitExecutor(gitService.repoPath, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return executor
+		}
+	}
+	return executors
+}
+
+func TestSyncDivergedConflict(t *testing.T) {
+	for name, newExecutor := range testExecutors(t) {
+		t.Run(name, func(t *testing.T) {
+			gitService, _ := divergedClone(t, "# Note\n\nfirst\n",
+				map[string]string{"note.md": "# Note\n\nremote edit\n"},
+				map[string]string{"note.md": "# Note\n\nlocal edit\n"})
+			gitService.SetExecutor(newExecutor(gitService))
+			sm := NewSyncManager(gitService)
+
+			_, err := sm.TriggerManualSync()
+			if !errors.Is(err, ErrMergeConflict) {
+				t.Fatalf("sync error = %v, want a merge conflict", err)
+			}
+
+			conflicts, err := gitService.Conflicts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conflicts) != 1 || conflicts[0].Path != "note.md" || conflicts[0].Type != ConflictTypeContent {
+				t.Fatalf("conflicts = %+v, want a content conflict in note.md", conflicts)
+			}
+			content := readTestFile(t, filepath.Join(gitService.repoPath, "note.md"))
+			if !strings.Contains(content, "<<<<<<< HEAD\nlocal edit\n=======\nremote edit\n>>>>>>> ") {
+				t.Errorf("note.md = %q, want conflict markers", content)
+			}
+			if !gitService.MergeInProgress() {
+				t.Error("no merge in progress")
+			}
+			if unmerged := mustRunGit(t, gitService.repoPath, "diff", "--name-only", "--diff-filter=U"); unmerged != "note.md" {
+				t.Errorf("git reports unmerged %q, want note.md", unmerged)
+			}
+
+			// Aborting goes back to the local commit
+			if err := sm.AbortSync(); err != nil {
+				t.Fatalf("abort sync: %v", err)
+			}
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, "note.md")); got != "# Note\n\nlocal edit\n" {
+				t.Errorf("note.md after abort = %q, want the local edit", got)
+			}
+		})
+	}
+}
+
+func TestSyncDivergedCleanMerge(t *testing.T) {
+	for name, newExecutor := range testExecutors(t) {
+		t.Run(name, func(t *testing.T) {
+			gitService, remoteDir := divergedClone(t, "# Note\n\nfirst\n\nsecond\n\nthird\n",
+				map[string]string{"note.md": "# Note\n\nfirst, edited remotely\n\nsecond\n\nthird\n", "remote.md": "remote\n"},
+				map[string]string{"note.md": "# Note\n\nfirst\n\nsecond\n\nthird, edited locally\n", "local.md": "local\n"})
+			gitService.SetExecutor(newExecutor(gitService))
+
+			if _, err := NewSyncManager(gitService).TriggerManualSync(); err != nil {
+				t.Fatalf("sync: %v", err)
+			}
+
+			want := "# Note\n\nfirst, edited remotely\n\nsecond\n\nthird, edited locally\n"
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, "note.md")); got != want {
+				t.Errorf("note.md = %q, want %q", got, want)
+			}
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, "remote.md")); got != "remote\n" {
+				t.Errorf("remote.md = %q", got)
+			}
+
+			// The merge commit was pushed with both histories as parents
+			head, err := gitService.HeadCommit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			commit, err := gitService.repository.CommitObject(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit.NumParents() != 2 || strings.Contains(commit.Message, "#") {
+				t.Errorf("head commit has %d parents and message %q, want a merge commit", commit.NumParents(), commit.Message)
+			}
+			if got := mustRunGit(t, remoteDir, "rev-parse", "master"); got != head.String() {
+				t.Errorf("remote master = %s, want %s", got, head)
+			}
+			if status := mustRunGit(t, gitService.repoPath, "status", "--porcelain"); status != "" {
+				t.Errorf("working tree not clean:\n%s", status)
+			}
+		})
+	}
+}
+
+func TestSyncDivergedConflictStrategies(t *testing.T) {
+	tests := []struct {
+		strategy ConflictStrategy
+		want     string
+	}{
+		{strategy: ConflictStrategyOurs, want: "# Note\n\nlocal edit\n"},
+		{strategy: ConflictStrategyTheirs, want: "# Note\n\nremote edit\n"},
+		{strategy: ConflictStrategyBoth, want: "<<<<<<< HEAD\nlocal edit\n=======\nremote edit\n>>>>>>> "},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			gitService, remoteDir := divergedClone(t, "# Note\n\nfirst\n",
+				map[string]string{"note.md": "# Note\n\nremote edit\n"},
+				map[string]string{"note.md": "# Note\n\nlocal edit\n"})
+			sm := NewSyncManager(gitService)
+			sm.SetConflictStrategy(tt.strategy)
+
+			if _, err := sm.TriggerManualSync(); err != nil {
+				t.Fatalf("sync: %v", err)
+			}
+
+			if got := readTestFile(t, filepath.Join(gitService.repoPath, "note.md")); !strings.Contains(got, tt.want) {
+				t.Errorf("note.md = %q, want %q", got, tt.want)
+			}
+
+			// The resolution is a merge commit, so the remote history is kept and pushed
+			head, err := gitService.HeadCommit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			commit, err := gitService.repository.CommitObject(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit.NumParents() != 2 {
+				t.Errorf("resolution commit has %d parents, want a merge commit", commit.NumParents())
+			}
+			if got := mustRunGit(t, remoteDir, "rev-parse", "master"); got != head.String() {
+				t.Errorf("remote master = %s, want %s", got, head)
+			}
+			if gitService.MergeInProgress() {
+				t.Error("merge still in progress")
+			}
+		})
+	}
+}
+
+func TestStatusEventsEmittedAfterUnlocking(t *testing.T) {
+	gitService, _ := divergedClone(t, "# Note\n\nfirst\n",
+		map[string]string{"note.md": "# Note\n\nremote edit\n"},
+		map[string]string{"note.md": "# Note\n\nlocal edit\n"})
+	sm := NewSyncManager(gitService)
+
+	// A handler reading the sync state would deadlock if events were emitted under sm.mu
+	var mu sync.Mutex
+	events := make(map[string]int)
+	sm.SetEventEmitter(EventEmitterFunc(func(name string, data interface{}) {
+		sm.GetSyncStatus()
+		sm.GetSyncHistory()
+		mu.Lock()
+		events[name]++
+		mu.Unlock()
+	}))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := sm.TriggerManualSync()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrMergeConflict) {
+			t.Fatalf("sync error = %v, want a merge conflict", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("sync blocked on an event handler")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if events[EventSyncStatus] == 0 || events[EventSyncConflict] == 0 {
+		t.Fatalf("events = %v, want status and conflict events", events)
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Default intervals for watcher driven sync
+const (
+	DefaultSyncDebounce      = 5 * time.Second
+	DefaultSyncFetchInterval = 5 * time.Minute
+)
+
+// SyncIntervals configures when automatic sync runs for a repository
+type SyncIntervals struct {
+	DebounceSeconds      int `json:"debounceSeconds"`      // Quiet period after the last write before committing
+	FetchIntervalSeconds int `json:"fetchIntervalSeconds"` // How often to check the remote for changes
+}
+
+// DefaultSyncIntervals returns the default automatic sync intervals
+func DefaultSyncIntervals() SyncIntervals {
+	return SyncIntervals{
+		DebounceSeconds:      int(DefaultSyncDebounce / time.Second),
+		FetchIntervalSeconds: int(DefaultSyncFetchInterval / time.Second),
+	}
+}
+
+// SyncWatcher watches the repository for file changes and syncs once writes settle.
+// A slower periodic sync picks up remote changes while the vault is idle.
+type SyncWatcher struct {
+	repoPath      string
+	syncManager   *SyncManager
+	debounce      time.Duration
+	fetchInterval time.Duration
+	watcher       *fsnotify.Watcher
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewSyncWatcher creates a new SyncWatcher for the repository
+func NewSyncWatcher(repoPath string, syncManager *SyncManager, intervals SyncIntervals) *SyncWatcher {
+	defaults := DefaultSyncIntervals()
+	if intervals.DebounceSeconds <= 0 {
+		intervals.DebounceSeconds = defaults.DebounceSeconds
+	}
+	if intervals.FetchIntervalSeconds <= 0 {
+		intervals.FetchIntervalSeconds = defaults.FetchIntervalSeconds
+	}
+
+	return &SyncWatcher{
+		repoPath:      repoPath,
+		syncManager:   syncManager,
+		debounce:      time.Duration(intervals.DebounceSeconds) * time.Second,
+		fetchInterval: time.Duration(intervals.FetchIntervalSeconds) * time.Second,
+	}
+}
+
+// Start begins watching the repository
+func (sw *SyncWatcher) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %w", err)
+	}
+	sw.watcher = watcher
+
+	// fsnotify isn't recursive, so every directory is watched individually
+	if err := sw.addRecursive(sw.repoPath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching repository: %w", err)
+	}
+
+	sw.stop = make(chan struct{})
+	sw.done = make(chan struct{})
+	go sw.run()
+
+	return nil
+}
+
+// Stop stops watching and waits for any running sync to finish. Writes still
+// waiting for the debounce are synced before it returns.
+func (sw *SyncWatcher) Stop() {
+	if sw.stop == nil {
+		return
+	}
+
+	close(sw.stop)
+	<-sw.done
+	sw.watcher.Close()
+	sw.stop = nil
+}
+
+// run is the event loop. Syncs triggered by writes and by the fetch ticker run
+// on this goroutine, so they never overlap.
+func (sw *SyncWatcher) run() {
+	defer close(sw.done)
+
+	fetchTicker := time.NewTicker(sw.fetchInterval)
+	defer fetchTicker.Stop()
+
+	// The debounce timer is created stopped and re-armed on every write
+	debounceTimer := time.NewTimer(sw.debounce)
+	if !debounceTimer.Stop() {
+		<-debounceTimer.C
+	}
+	defer debounceTimer.Stop()
+	pending := false // Writes are waiting for the debounce timer
+
+	for {
+		select {
+		case event, ok := <-sw.watcher.Events:
+			if !ok {
+				return
+			}
+			if sw.isIgnored(event.Name) {
+				continue
+			}
+
+			// Start watching directories created after the watcher started
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := sw.addRecursive(event.Name); err != nil {
+						fmt.Printf("Watcher error: %v\n", err)
+					}
+				}
+			}
+
+			debounceTimer.Reset(sw.debounce)
+			pending = true
+
+		case err, ok := <-sw.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("Watcher error: %v\n", err)
+
+		case <-debounceTimer.C:
+			pending = false
+			sw.syncLocalChanges()
+
+		case <-fetchTicker.C:
+			sw.sync("Periodic sync")
+
+		case <-sw.stop:
+			// Don't drop an edit made right before stopping
+			if pending {
+				sw.syncLocalChanges()
+			}
+			return
+		}
+	}
+}
+
+// syncLocalChanges syncs after a burst of writes, skipping the sync if the writes
+// didn't leave any changes (e.g. files written by a pull)
+func (sw *SyncWatcher) syncLocalChanges() {
+	hasChanges, err := sw.syncManager.gitService.HasLocalChanges()
+	if err != nil {
+		fmt.Printf("Auto-sync error: %v\n", err)
+		return
+	}
+	if !hasChanges {
+		return
+	}
+
+	sw.sync("Auto-sync")
+}
+
+// sync runs a full sync and logs any error
+func (sw *SyncWatcher) sync(label string) {
+	_, err := sw.syncManager.TriggerManualSync()
+	if err != nil {
+		// Log the error but keep watching
+		fmt.Printf("%s error: %v\n", label, err)
+	}
+}
+
+// addRecursive watches dir and all directories below it, skipping .git
+func (sw *SyncWatcher) addRecursive(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return sw.watcher.Add(path)
+	})
+}
+
+// isIgnored reports whether a path is inside the .git directory or the trash
+func (sw *SyncWatcher) isIgnored(path string) bool {
+	relPath, err := filepath.Rel(sw.repoPath, path)
+	if err != nil {
+		return true
+	}
+
+	// The rename that completes a write is reported for the note itself
+	if isTempWriteFile(path) {
+		return true
+	}
+
+	parts := strings.Split(relPath, string(filepath.Separator))
+	if parts[0] == trashDirName {
+		return true
+	}
+	for _, part := range parts {
+		if part == ".git" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// eventDelay is how long a test waits for the watcher to see a write
+const eventDelay = 200 * time.Millisecond
+
+// hourIntervals keeps the debounce and fetch timers from firing during a test
+var hourIntervals = SyncIntervals{DebounceSeconds: 3600, FetchIntervalSeconds: 3600}
+
+func TestStopSyncsPendingEdit(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gitService := cloneTestRemote(t, remoteDir)
+	watcher := NewSyncWatcher(gitService.repoPath, NewSyncManager(gitService), hourIntervals)
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("start watcher: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(gitService.repoPath, "note.md"), "# Note\n\nlast edit\n")
+	time.Sleep(eventDelay)
+	watcher.Stop()
+
+	if got := mustRunGit(t, remoteDir, "show", "master:note.md"); got != "# Note\n\nlast edit" {
+		t.Fatalf("remote note.md = %q, want the edit made before stopping", got)
+	}
+}
+
+func TestStopWithoutPendingEdit(t *testing.T) {
+	remoteDir := newTestRemote(t, map[string]string{"note.md": "# Note\n"})
+	gitService := cloneTestRemote(t, remoteDir)
+	head := mustRunGit(t, remoteDir, "rev-parse", "master")
+	watcher := NewSyncWatcher(gitService.repoPath, NewSyncManager(gitService), hourIntervals)
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("start watcher: %v", err)
+	}
+
+	watcher.Stop()
+	watcher.Stop()
+
+	if got := mustRunGit(t, remoteDir, "rev-parse", "master"); got != head {
+		t.Fatalf("remote master moved to %s without edits", got)
+	}
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Diff modes
+const (
+	DiffModeUnified = "unified" // Line-based unified diff text
+	DiffModeWord    = "word"    // Word-level segments
+)
+
+// Diff segment operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// diffContextLines is the number of unchanged lines shown around each unified diff hunk
+const diffContextLines = 3
+
+// wordDiffTimeout bounds the time spent on a word diff of very different texts
+const wordDiffTimeout = 5 * time.Second
+
+// wordPattern splits text into words, runs of whitespace and single punctuation characters
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+|\s+|[^\p{L}\p{N}_\s]`)
+
+// DiffSegment is a run of text that is unchanged, inserted or deleted
+type DiffSegment struct {
+	Op   string `json:"op"` // DiffEqual, DiffInsert or DiffDelete
+	Text string `json:"text"`
+}
+
+// FileDiff is the difference between two versions of a file
+type FileDiff struct {
+	Path     string        `json:"path"`
+	From     string        `json:"from"` // Commit hash of the old version
+	To       string        `json:"to"`   // Commit hash of the new version, empty for the working copy
+	Mode     string        `json:"mode"`
+	Binary   bool          `json:"binary"`             // Binary files are not diffed
+	Unified  string        `json:"unified,omitempty"`  // Unified diff, for DiffModeUnified
+	Segments []DiffSegment `json:"segments,omitempty"` // Word diff, for DiffModeWord
+	Added    int           `json:"added"`              // Lines, or words for a word diff
+	Removed  int           `json:"removed"`
+}
+
+// diffLine is a line of a line-based diff
+type diffLine struct {
+	Op   string
+	Text string // Without the trailing newline
+}
+
+// isBinaryContent reports whether content looks binary, like git it checks for a NUL byte
+func isBinaryContent(content []byte) bool {
+	const sniffLen = 8000
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// diffLines compares two texts line by line
+func diffLines(oldText, newText string) []diffLine {
+	lines := make([]diffLine, 0)
+	for _, d := range diff.Do(oldText, newText) {
+		op := DiffEqual
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = DiffInsert
+		case diffmatchpatch.DiffDelete:
+			op = DiffDelete
+		}
+
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line == "" {
+				continue
+			}
+			lines = append(lines, diffLine{Op: op, Text: strings.TrimSuffix(line, "\n")})
+		}
+	}
+	return lines
+}
+
+// unifiedDiff formats the line differences between two texts as a unified diff with
+// the usual ---/+++ header and @@ hunks. It returns the diff and the added and removed line counts.
+func unifiedDiff(oldName, newName, oldText, newText string) (string, int, int) {
+	lines := diffLines(oldText, newText)
+
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch line.Op {
+		case DiffInsert:
+			added++
+		case DiffDelete:
+			removed++
+		}
+	}
+	if added == 0 && removed == 0 {
+		return "", 0, 0
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Line numbers before each diff line, in the old and new text
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, line := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if line.Op != DiffInsert {
+			oldLine[i+1]++
+		}
+		if line.Op != DiffDelete {
+			newLine[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		// Find the next change and extend the hunk while changes are close together
+		first := start
+		for first < len(lines) && lines[first].Op == DiffEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		last := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].Op != DiffEqual {
+				last = i
+			} else if i-last > 2*diffContextLines {
+				break
+			}
+		}
+
+		hunkStart := max(first-diffContextLines, start)
+		hunkEnd := min(last+diffContextLines+1, len(lines))
+
+		oldCount, newCount := oldLine[hunkEnd]-oldLine[hunkStart], newLine[hunkEnd]-newLine[hunkStart]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldLine[hunkStart], oldCount), hunkRange(newLine[hunkStart], newCount))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			prefix := " "
+			switch line.Op {
+			case DiffInsert:
+				prefix = "+"
+			case DiffDelete:
+				prefix = "-"
+			}
+			b.WriteString(prefix + line.Text + "\n")
+		}
+
+		start = hunkEnd
+	}
+
+	return b.String(), added, removed
+}
+
+// hunkRange formats the start,count range of a unified diff hunk
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range refers to the line before the hunk
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// wordDiff compares two texts word by word. It returns the segments and the added and
+// removed word counts.
+func wordDiff(oldText, newText string) ([]DiffSegment, int, int) {
+	// Map every distinct token to a rune so the diff runs on tokens, not characters
+	tokens := make([]string, 0)
+	tokenRunes := make(map[string]rune)
+	encode := func(text string) []rune {
+		words := wordPattern.FindAllString(text, -1)
+		encoded := make([]rune, len(words))
+		for i, word := range words {
+			r, ok := tokenRunes[word]
+			if !ok {
+				r = tokenRune(len(tokens))
+				tokens = append(tokens, word)
+				tokenRunes[word] = r
+			}
+			encoded[i] = r
+		}
+		return encoded
+	}
+	oldRunes, newRunes := encode(oldText), encode(newText)
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = wordDiffTimeout
+	diffs := dmp.DiffMainRunes(oldRunes, newRunes, false)
+
+	segments := make([]DiffSegment, 0, len(diffs))
+	added, removed := 0, 0
+	for _, d := range diffs {
+		var text strings.Builder
+		words := 0
+		for _, r := range d.Text {
+			token := tokens[tokenIndex(r)]
+			text.WriteString(token)
+			if strings.TrimSpace(token) != "" {
+				words++
+			}
+		}
+
+		segment := DiffSegment{Op: DiffEqual, Text: text.String()}
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			segment.Op = DiffInsert
+			added += words
+		case diffmatchpatch.DiffDelete:
+			segment.Op = DiffDelete
+			removed += words
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, added, removed
+}
+
+// tokenRune encodes a token index as a rune, skipping the UTF-16 surrogate range
+// which isn't valid in strings
+func tokenRune(index int) rune {
+	r := rune(index + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+// tokenIndex decodes a rune from tokenRune back into the token index
+func tokenIndex(r rune) int {
+	if r >= 0xE000 {
+		r -= 0x800
+	}
+	return int(r) - 1
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trashDirName is the vault directory holding deleted files. It's listed in
+// .git/info/exclude so deleted files are never committed.
+const trashDirName = ".gitnotes-trash"
+
+// DefaultTrashRetentionDays is how long deleted files are kept when no retention is set
+const DefaultTrashRetentionDays = 30
+
+// ErrTrashItemNotFound is returned for an ID that isn't in the trash
+var ErrTrashItemNotFound = errors.New("item not found in trash")
+
+// TrashItem is a deleted file or directory that can be restored
+type TrashItem struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"originalPath"` // Slash-separated path relative to the vault root
+	IsDir        bool      `json:"isDir"`
+	Size         int64     `json:"size"` // Total size of the files, in bytes
+	DeletedAt    time.Time `json:"deletedAt"`
+}
+
+// Trash keeps deleted files and directories inside the vault, outside git tracking,
+// until they are restored, the trash is emptied or the retention expires. Each item
+// is stored as <id>/<name> with its TrashItem in <id>.json.
+type Trash struct {
+	root      string
+	dir       string
+	retention time.Duration // Zero keeps items until the trash is emptied
+	mu        sync.Mutex
+}
+
+// NewTrash creates the trash of the vault at root. Items older than retention are
+// purged, zero keeps them forever.
+func NewTrash(root string, retention time.Duration) *Trash {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+	return &Trash{root: absRoot, dir: filepath.Join(absRoot, trashDirName), retention: retention}
+}
+
+// SetRetention sets how long deleted items are kept, zero keeps them forever
+func (t *Trash) SetRetention(retention time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.retention = retention
+}
+
+// Move moves a file or directory of the vault into the trash
+func (t *Trash) Move(fullPath string) (TrashItem, error) {
+	relPath, ok := relativeVaultPath(t.root, fullPath)
+	if !ok {
+		return TrashItem{}, errors.New("invalid file path")
+	}
+	if top, _, _ := strings.Cut(relPath, "/"); top == ".git" || top == trashDirName {
+		return TrashItem{}, fmt.Errorf("cannot delete %s", relPath)
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return TrashItem{}, errors.New("file does not exist")
+		}
+		return TrashItem{}, fmt.Errorf("error checking file: %w", err)
+	}
+
+	id, err := newTrashID()
+	if err != nil {
+		return TrashItem{}, err
+	}
+	item := TrashItem{
+		ID:           id,
+		OriginalPath: relPath,
+		IsDir:        info.IsDir(),
+		Size:         diskUsage(fullPath),
+		DeletedAt:    time.Now(),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureExcluded(); err != nil {
+		return TrashItem{}, fmt.Errorf("error excluding trash from git: %w", err)
+	}
+	if err := os.MkdirAll(t.itemDir(id), 0755); err != nil {
+		return TrashItem{}, fmt.Errorf("error creating trash directory: %w", err)
+	}
+
+	// The metadata is written first, so an interrupted move never leaves a file in the
+	// trash that can't be restored
+	if err := t.writeItem(item); err != nil {
+		os.RemoveAll(t.itemDir(id))
+		return TrashItem{}, err
+	}
+	if err := os.Rename(fullPath, t.itemPath(item)); err != nil {
+		t.removeLocked(id)
+		return TrashItem{}, fmt.Errorf("error moving file to trash: %w", err)
+	}
+
+	return item, nil
+}
+
+// List purges expired items and returns the rest, most recently deleted first
+func (t *Trash) List() ([]TrashItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	items, err := t.itemsLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]TrashItem, 0, len(items))
+	for _, item := range items {
+		if t.expiredLocked(item) {
+			t.removeLocked(item.ID)
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].DeletedAt.After(kept[j].DeletedAt) })
+	return kept, nil
+}
+
+// Restore moves an item back to its original path and returns the path it was
+// restored to. When the original path has been taken since, the item is restored
+// next to it, e.g. "Note (restored).md".
+func (t *Trash) Restore(id string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, err := t.readItem(id)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(t.root, filepath.FromSlash(item.OriginalPath))
+	if _, err := os.Lstat(fullPath); !os.IsNotExist(err) {
+		fullPath = filepath.Join(t.root, filepath.FromSlash(freeLabeledPath(t.root, item.OriginalPath, "restored")))
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := os.Rename(t.itemPath(item), fullPath); err != nil {
+		return "", fmt.Errorf("error restoring file: %w", err)
+	}
+	t.removeLocked(id)
+
+	return fullPath, nil
+}
+
+// Empty permanently deletes everything in the trash
+func (t *Trash) Empty() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.RemoveAll(t.dir); err != nil {
+		return fmt.Errorf("error emptying trash: %w", err)
+	}
+	return nil
+}
+
+// Purge permanently deletes the items kept longer than the retention and returns
+// how many were deleted
+func (t *Trash) Purge() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	items, err := t.itemsLocked()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, item := range items {
+		if t.expiredLocked(item) {
+			t.removeLocked(item.ID)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// itemsLocked reads the metadata of every item. Metadata left by an interrupted
+// move, without its file, is removed. The caller must hold t.mu.
+func (t *Trash) itemsLocked() ([]TrashItem, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading trash: %w", err)
+	}
+
+	items := make([]TrashItem, 0)
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		item, err := t.readItem(id)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring unreadable trash item %s: %v\n", id, err)
+			continue
+		}
+		if _, err := os.Lstat(t.itemPath(item)); os.IsNotExist(err) {
+			t.removeLocked(id)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// expiredLocked reports whether an item was kept longer than the retention. The
+// caller must hold t.mu.
+func (t *Trash) expiredLocked(item TrashItem) bool {
+	return t.retention > 0 && time.Since(item.DeletedAt) > t.retention
+}
+
+// readItem reads the metadata of an item
+func (t *Trash) readItem(id string) (TrashItem, error) {
+	// IDs come from the frontend, they must not point outside the trash
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return TrashItem{}, ErrTrashItemNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(t.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return TrashItem{}, ErrTrashItemNotFound
+	}
+	if err != nil {
+		return TrashItem{}, fmt.Errorf("error reading trash item: %w", err)
+	}
+
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return TrashItem{}, fmt.Errorf("error decoding trash item: %w", err)
+	}
+	item.ID = id
+	return item, nil
+}
+
+// writeItem writes the metadata of an item
+func (t *Trash) writeItem(item TrashItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling trash item: %w", err)
+	}
+	if err := atomicWriteFile(filepath.Join(t.dir, item.ID+".json"), data, 0644); err != nil {
+		return fmt.Errorf("error writing trash item: %w", err)
+	}
+	return nil
+}
+
+// removeLocked permanently deletes an item and its metadata. The caller must hold t.mu.
+func (t *Trash) removeLocked(id string) {
+	if err := os.RemoveAll(t.itemDir(id)); err != nil {
+		fmt.Printf("Warning: Unable to remove trash item %s: %v\n", id, err)
+		return
+	}
+	os.Remove(filepath.Join(t.dir, id+".json"))
+}
+
+// itemDir returns the directory holding an item
+func (t *Trash) itemDir(id string) string {
+	return filepath.Join(t.dir, id)
+}
+
+// itemPath returns where an item's file or directory is kept, under its original name
+func (t *Trash) itemPath(item TrashItem) string {
+	return filepath.Join(t.itemDir(item.ID), path.Base(item.OriginalPath))
+}
+
+// ensureExcluded adds the trash directory to .git/info/exclude, so the vault's
+// .gitignore doesn't need to change
+func (t *Trash) ensureExcluded() error {
+	return addGitExclude(t.root, "/"+trashDirName+"/")
+}
+
+// newTrashID returns a unique item ID that sorts by deletion time
+func newTrashID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("error generating trash ID: %w", err)
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// diskUsage returns the total size of a file or of the files in a directory
+func diskUsage(fullPath string) int64 {
+	var size int64
+	filepath.WalkDir(fullPath, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// trashRetention returns the configured trash retention, DefaultTrashRetentionDays when unset
+func trashRetention(settings map[string]interface{}) time.Duration {
+	days := DefaultTrashRetentionDays
+	if stored, ok := settings["trashRetentionDays"].(float64); ok && stored >= 0 {
+		days = int(stored)
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// trash returns the trash of a vault
+func (v *vault) trash() (*Trash, error) {
+	if v.trashBin == nil {
+		return nil, errors.New("trash is unavailable")
+	}
+	return v.trashBin, nil
+}
+
+// ListTrash returns the deleted files and directories as a JSON array of TrashItem,
+// most recently deleted first. Items past the retention are purged first.
+func (gns *GitNotesService) ListTrash(vaultID string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	trash, err := v.trash()
+	if err != nil {
+		return "", err
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		return "", err
+	}
+
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling trash: %w", err)
+	}
+
+	return string(itemsJSON), nil
+}
+
+// RestoreFromTrash moves a deleted file or directory back to its original path, or
+// next to it when the path was taken since, and returns the path it was restored to
+func (gns *GitNotesService) RestoreFromTrash(vaultID string, id string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	return v.fileService.RestoreFromTrash(id)
+}
+
+// EmptyTrash permanently deletes everything in the trash
+func (gns *GitNotesService) EmptyTrash(vaultID string) error {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return err
+	}
+	trash, err := v.trash()
+	if err != nil {
+		return err
+	}
+
+	return trash.Empty()
+}
+
+// GetTrashRetentionDays returns how many days deleted files are kept, zero keeps them
+// until the trash is emptied
+func (gns *GitNotesService) GetTrashRetentionDays() int {
+	return int(trashRetention(gns.loadSettingsMap()) / (24 * time.Hour))
+}
+
+// SetTrashRetentionDays sets how many days deleted files are kept in the trash of every
+// vault, zero keeps them until the trash is emptied. Older items are purged right away.
+func (gns *GitNotesService) SetTrashRetentionDays(days int) error {
+	if days < 0 {
+		return errors.New("trash retention must not be negative")
+	}
+
+	err := gns.updateSettings(func(settings map[string]interface{}) error {
+		settings["trashRetentionDays"] = days
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range gns.openVaults() {
+		if v.trashBin == nil {
+			continue
+		}
+		v.trashBin.SetRetention(time.Duration(days) * 24 * time.Hour)
+		if _, err := v.trashBin.Purge(); err != nil {
+			fmt.Printf("Warning: Unable to purge trash: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// ListDeletedNotes returns the files deleted in the vault's git history, including
+// on other machines, that can be recovered. It returns a JSON array of DeletedFile,
+// most recent deletion first.
+func (gns *GitNotesService) ListDeletedNotes(vaultID string, limit int) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+
+	deleted, err := v.syncManager.gitService.DeletedFiles(limit)
+	if err != nil {
+		return "", err
+	}
+
+	deletedJSON, err := json.Marshal(deleted)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling deleted files: %w", err)
+	}
+
+	return string(deletedJSON), nil
+}
+
+// RecoverDeletedNote writes a file deleted by a commit back with the content it had
+// before the commit, and returns the path it was recovered to. The recovered file is
+// a new change that the next sync commits.
+func (gns *GitNotesService) RecoverDeletedNote(vaultID string, filePath string, commit string) (string, error) {
+	v, err := gns.vault(vaultID)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := v.relativePath(filePath)
+	if err != nil {
+		return "", err
+	}
+	if v.syncManager.IsSyncing() {
+		return "", errors.New("cannot recover a file while a sync is in progress")
+	}
+
+	content, _, err := v.syncManager.gitService.FileContentAt(relPath, commit+"^")
+	if err != nil {
+		return "", err
+	}
+
+	root, err := filepath.Abs(v.config.LocalPath)
+	if err != nil {
+		return "", fmt.Errorf("error resolving vault path: %w", err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(relPath))); !os.IsNotExist(err) {
+		relPath = freeLabeledPath(root, relPath, "restored")
+	}
+
+	fullPath := filepath.Join(root, filepath.FromSlash(relPath))
+	if err := v.fileService.WriteFileContent(fullPath, string(content)); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
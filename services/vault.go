@@ -0,0 +1,423 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// ErrVaultNotFound is returned for an unknown vault ID
+var ErrVaultNotFound = errors.New("vault not found")
+
+// ErrServiceShutDown is returned for vaults connected after the application started quitting
+var ErrServiceShutDown = errors.New("service is shut down")
+
+// VaultConfig is a notes repository registered with GitNotes
+type VaultConfig struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	RepoURL      string `json:"repoURL"`
+	LocalPath    string `json:"localPath"`
+	Branch       string `json:"branch,omitempty"`       // Notes branch, empty for the checked out branch
+	Remote       string `json:"remote,omitempty"`       // Remote the branch syncs with, empty for origin
+	AutoSync     bool   `json:"autoSync,omitempty"`     // Automatic sync is on and restarts with the app
+	SyncInterval int    `json:"syncInterval,omitempty"` // Fetch interval automatic sync was started with, 0 for the configured one
+}
+
+// VaultInfo is a registered vault with its runtime state
+type VaultInfo struct {
+	VaultConfig
+	Active     bool   `json:"active"`
+	Connected  bool   `json:"connected"`
+	SyncActive bool   `json:"syncActive"`
+	SyncStatus string `json:"syncStatus,omitempty"`
+}
+
+// vault holds the services of a connected vault
+type vault struct {
+	config       VaultConfig
+	repoService  *RepositoryService
+	fileService  *FileService
+	syncManager  *SyncManager
+	syncMu       sync.Mutex // Guards syncWatcher, syncActive and closed
+	syncWatcher  *SyncWatcher
+	syncActive   bool
+	closed       bool            // Set by close, automatic sync can't start again
+	background   sync.WaitGroup  // Work started on connect, e.g. the index refresh
+	lock         *VaultLock      // Shared by file writes and sync
+	searchIndex  *SearchIndex    // nil when the index couldn't be created
+	quickOpen    *QuickOpenIndex // nil when the index couldn't be created
+	linkGraph    *LinkGraph
+	draftJournal *DraftJournal // nil when drafts can't be stored
+	trashBin     *Trash
+}
+
+// isSyncActive reports whether automatic sync is running for the vault
+func (v *vault) isSyncActive() bool {
+	v.syncMu.Lock()
+	defer v.syncMu.Unlock()
+
+	return v.syncActive
+}
+
+// goBackground runs work in a goroutine that close waits for
+func (v *vault) goBackground(work func()) {
+	v.background.Add(1)
+	go func() {
+		defer v.background.Done()
+		work()
+	}()
+}
+
+// stopSyncLocked stops the watcher and waits for a running sync. The caller must
+// hold v.syncMu.
+func (v *vault) stopSyncLocked() {
+	if v.syncActive {
+		v.syncWatcher.Stop()
+		v.syncWatcher = nil
+		v.syncActive = false
+	}
+}
+
+// close stops automatic sync, after the watcher synced pending edits, waits for the
+// background work and saves the search index. The vault's services aren't used after.
+func (v *vault) close() {
+	v.syncMu.Lock()
+	v.stopSyncLocked()
+	v.closed = true
+	v.syncMu.Unlock()
+
+	v.background.Wait()
+
+	if v.searchIndex != nil {
+		if err := v.searchIndex.Close(); err != nil {
+			fmt.Printf("Warning: Unable to save search index: %v\n", err)
+		}
+	}
+}
+
+// newVaultID returns a random vault ID
+func newVaultID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating vault ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// vaultName returns the default display name for a vault at the local path
+func vaultName(localPath string) string {
+	return filepath.Base(filepath.Clean(localPath))
+}
+
+// vaultsFromSettings returns the vault registry and the active vault ID from the settings map
+func vaultsFromSettings(settings map[string]interface{}) ([]VaultConfig, string) {
+	vaults := make([]VaultConfig, 0)
+	if raw, ok := settings["vaults"]; ok {
+		// Round-trip through JSON to decode the generic map into configs
+		if data, err := json.Marshal(raw); err == nil {
+			if err := json.Unmarshal(data, &vaults); err != nil {
+				fmt.Printf("Warning: Ignoring invalid vault registry: %v\n", err)
+				vaults = make([]VaultConfig, 0)
+			}
+		}
+	}
+
+	activeID, _ := settings["activeVault"].(string)
+	return vaults, activeID
+}
+
+// findVaultConfig returns the index of the vault with the ID, or -1
+func findVaultConfig(vaults []VaultConfig, vaultID string) int {
+	for i, config := range vaults {
+		if config.ID == vaultID {
+			return i
+		}
+	}
+	return -1
+}
+
+// vaultEmitter tags sync events with the vault they belong to
+type vaultEmitter struct {
+	vaultID string
+	emitter EventEmitter
+}
+
+// Emit sets the vault ID on sync event payloads and forwards them
+func (e vaultEmitter) Emit(name string, data interface{}) {
+	switch event := data.(type) {
+	case SyncStatusEvent:
+		event.VaultID = e.vaultID
+		data = event
+	case SyncConflictEvent:
+		event.VaultID = e.vaultID
+		data = event
+	case SyncProgressEvent:
+		event.VaultID = e.vaultID
+		data = event
+	}
+	e.emitter.Emit(name, data)
+}
+
+// eventEmitterFor returns the emitter for a vault's events, or nil when there is no emitter
+func (gns *GitNotesService) eventEmitterFor(vaultID string) EventEmitter {
+	if gns.emitter == nil {
+		return nil
+	}
+	return vaultEmitter{vaultID: vaultID, emitter: gns.emitter}
+}
+
+// connectVault clones or opens the vault repository and creates its services
+func (gns *GitNotesService) connectVault(config VaultConfig, token string) (*vault, error) {
+	settings := gns.loadSettingsMap()
+
+	repoService := NewRepositoryService()
+	repoService.SetEventEmitter(gns.eventEmitterFor(config.ID))
+
+	// Apply SSH options for git@host: and ssh:// remotes
+	repoService.AuthProvider().SetSSHOptions(sshOptionsFromSettings(settings))
+
+	// Connect to the repository
+	err := repoService.ConnectRepository(config.RepoURL, config.LocalPath, token, config.Branch, config.Remote)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create GitService and SyncManager after successful connection
+	gitService, err := NewGitService(config.LocalPath, config.RepoURL)
+	if err != nil {
+		return nil, err
+	}
+	gitService.SetAuthProvider(repoService.AuthProvider())
+
+	// Check out the requested branch in an existing clone and track the chosen remote
+	if config.Branch != "" {
+		if upstream, err := gitService.Upstream(); err != nil || upstream.Branch != config.Branch {
+			if err := gitService.SwitchBranch(config.Branch); err != nil {
+				return nil, fmt.Errorf("failed to switch to branch %s: %w", config.Branch, err)
+			}
+		}
+	}
+	if config.Remote != "" {
+		if err := gitService.SetUpstream(config.Remote, config.Branch); err != nil {
+			return nil, fmt.Errorf("failed to set upstream: %w", err)
+		}
+	}
+
+	// Use the configured commit author and signing key for every commit
+	if err := gns.applyCommitSettings(gitService, settings); err != nil {
+		return nil, fmt.Errorf("failed to configure commit signing: %w", err)
+	}
+
+	// Merge pulls and resolve conflicts with the chosen executor
+	if name, _ := settings["gitExecutor"].(string); name != "" {
+		if err := gitService.UseExecutor(name); err != nil {
+			fmt.Printf("Warning: Using the go-git executor: %v\n", err)
+		}
+	}
+
+	// Initialize SyncManager
+	syncManager := NewSyncManager(gitService)
+	syncManager.SetEventEmitter(gns.eventEmitterFor(config.ID))
+
+	// Describe auto-commits with the configured template
+	template, _ := settings["commitMessageTemplate"].(string)
+	generator, err := NewCommitMessageGenerator(template)
+	if err != nil {
+		fmt.Printf("Warning: Using the default commit message template: %v\n", err)
+		generator, _ = NewCommitMessageGenerator("")
+	}
+	syncManager.SetCommitMessageGenerator(generator)
+
+	// Persist sync history per repository
+	historyStore, err := NewRepositoryHistoryStore(config.LocalPath)
+	if err != nil {
+		fmt.Printf("Warning: Sync history will not be persisted: %v\n", err)
+	} else {
+		syncManager.SetHistoryStore(historyStore)
+	}
+
+	v := &vault{
+		config:      config,
+		repoService: repoService,
+		fileService: NewFileService(repoService),
+		syncManager: syncManager,
+		lock:        NewVaultLock(0),
+	}
+
+	// Writes and the sync steps that change the working tree take turns
+	v.fileService.SetVaultLock(v.lock)
+	v.syncManager.SetVaultLock(v.lock)
+
+	// File operations stay inside the vault and away from the protected paths
+	v.fileService.SetProtectedPaths(protectedPathsFromSettings(settings))
+
+	// Load the persisted search index and catch up with changes made while closed
+	searchIndex, err := NewVaultSearchIndex(config.LocalPath)
+	if err != nil {
+		fmt.Printf("Warning: Search is unavailable: %v\n", err)
+	} else {
+		if err := searchIndex.Load(); err != nil {
+			fmt.Printf("Warning: Rebuilding search index: %v\n", err)
+		}
+		v.goBackground(func() {
+			if err := searchIndex.Refresh(); err != nil {
+				fmt.Printf("Warning: Unable to refresh search index: %v\n", err)
+			}
+		})
+		v.fileService.AddFileObserver(searchIndex)
+		v.syncManager.AddFileObserver(searchIndex)
+		v.searchIndex = searchIndex
+	}
+
+	// Quick open lists files lazily, the observers tell it when the list changes
+	quickOpen, err := NewVaultQuickOpenIndex(config.LocalPath)
+	if err != nil {
+		fmt.Printf("Warning: Quick open is unavailable: %v\n", err)
+	} else {
+		v.fileService.AddFileObserver(quickOpen)
+		v.syncManager.AddFileObserver(quickOpen)
+		v.quickOpen = quickOpen
+	}
+
+	// Unsaved edits are journaled outside the repository, saving a note drops its draft
+	draftJournal, err := NewVaultDraftJournal(config.LocalPath)
+	if err != nil {
+		fmt.Printf("Warning: Drafts are unavailable: %v\n", err)
+	} else {
+		v.fileService.AddFileObserver(draftJournal)
+		v.draftJournal = draftJournal
+	}
+
+	// Deleted files go to the vault's trash, which drops them after the retention
+	v.trashBin = NewTrash(config.LocalPath, trashRetention(settings))
+	v.fileService.SetTrash(v.trashBin)
+	v.goBackground(func() {
+		if _, err := v.trashBin.Purge(); err != nil {
+			fmt.Printf("Warning: Unable to purge trash: %v\n", err)
+		}
+	})
+
+	// Temp files of interrupted writes are never committed and are cleaned up
+	if err := addGitExclude(config.LocalPath, tempWriteExcludePattern); err != nil {
+		fmt.Printf("Warning: Unable to exclude temp files from git: %v\n", err)
+	}
+	v.goBackground(func() {
+		if _, err := sweepTempWrites(config.LocalPath); err != nil {
+			fmt.Printf("Warning: Unable to remove temp files: %v\n", err)
+		}
+	})
+
+	// The link graph is built on first use and then follows every change
+	v.linkGraph = NewLinkGraph(config.LocalPath)
+	v.fileService.AddFileObserver(v.linkGraph)
+	v.syncManager.AddFileObserver(v.linkGraph)
+
+	return v, nil
+}
+
+// vault returns the vault with the ID, or the active vault when vaultID is empty.
+// Registered vaults that aren't connected yet are opened on first use.
+func (gns *GitNotesService) vault(vaultID string) (*vault, error) {
+	gns.mu.Lock()
+	if vaultID == "" {
+		vaultID = gns.activeVaultID
+	}
+	if v, ok := gns.vaults[vaultID]; ok {
+		gns.mu.Unlock()
+		return v, nil
+	}
+	gns.mu.Unlock()
+
+	if vaultID == "" {
+		return nil, errors.New("not connected to a repository")
+	}
+	if gns.isShutDown() {
+		return nil, ErrServiceShutDown
+	}
+
+	// Only one call connects the vault, the others wait for it
+	connectMu := gns.connectLock(vaultID)
+	connectMu.Lock()
+	defer connectMu.Unlock()
+
+	gns.mu.Lock()
+	existing, ok := gns.vaults[vaultID]
+	gns.mu.Unlock()
+	if ok {
+		return existing, nil
+	}
+
+	vaults, _ := vaultsFromSettings(gns.loadSettingsMap())
+	idx := findVaultConfig(vaults, vaultID)
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrVaultNotFound, vaultID)
+	}
+
+	v, err := gns.connectVault(vaults[idx], "")
+	if err != nil {
+		return nil, err
+	}
+
+	gns.mu.Lock()
+	if gns.shutDown {
+		gns.mu.Unlock()
+		v.close()
+		return nil, ErrServiceShutDown
+	}
+	gns.vaults[vaultID] = v
+	gns.mu.Unlock()
+
+	return v, nil
+}
+
+// connectLock returns the mutex held while connecting the vault with the ID
+func (gns *GitNotesService) connectLock(vaultID string) *sync.Mutex {
+	gns.mu.Lock()
+	defer gns.mu.Unlock()
+
+	connectMu, ok := gns.connecting[vaultID]
+	if !ok {
+		connectMu = &sync.Mutex{}
+		gns.connecting[vaultID] = connectMu
+	}
+	return connectMu
+}
+
+// isShutDown reports whether OnShutdown ran
+func (gns *GitNotesService) isShutDown() bool {
+	gns.mu.Lock()
+	defer gns.mu.Unlock()
+
+	return gns.shutDown
+}
+
+// openVaults returns the connected vaults
+func (gns *GitNotesService) openVaults() []*vault {
+	gns.mu.Lock()
+	defer gns.mu.Unlock()
+
+	vaults := make([]*vault, 0, len(gns.vaults))
+	for _, v := range gns.vaults {
+		vaults = append(vaults, v)
+	}
+	return vaults
+}
+
+// updateVaultRegistry applies change to the stored vault registry and active vault
+// ID and persists the result
+func (gns *GitNotesService) updateVaultRegistry(change func(vaults []VaultConfig, activeID string) ([]VaultConfig, string, error)) error {
+	return gns.updateSettings(func(settings map[string]interface{}) error {
+		vaults, activeID, err := change(vaultsFromSettings(settings))
+		if err != nil {
+			return err
+		}
+		settings["vaults"] = vaults
+		settings["activeVault"] = activeID
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultWriteWait is how long a write waits for a sync to release the vault
+const DefaultWriteWait = 10 * time.Second
+
+// ErrVaultBusy is returned for writes that couldn't wait for a sync updating the vault
+var ErrVaultBusy = errors.New("vault is being synced")
+
+// VaultLock coordinates file writes with the sync steps that read or change the
+// working tree. Any number of writes can run at once. A sync waits for in-flight
+// writes before staging, and writes started while it waits, stages, commits or
+// pulls are queued until it is done, or rejected with ErrVaultBusy after the write
+// wait, so a stream of writes can't starve a sync.
+// A nil VaultLock doesn't coordinate anything.
+type VaultLock struct {
+	mu          sync.Mutex
+	writers     int           // Writes in progress
+	syncing     bool          // A sync step holds the vault
+	syncWaiting int           // Syncs waiting for the writes in progress, new writes queue behind them
+	changed     chan struct{} // Closed and replaced whenever writers, syncing or syncWaiting change
+	writeWait   time.Duration
+}
+
+// NewVaultLock creates a lock whose writes wait up to writeWait for a sync, zero
+// uses DefaultWriteWait
+func NewVaultLock(writeWait time.Duration) *VaultLock {
+	if writeWait <= 0 {
+		writeWait = DefaultWriteWait
+	}
+	return &VaultLock{changed: make(chan struct{}), writeWait: writeWait}
+}
+
+// BeginWrite registers a write and returns the function that ends it. Writes must
+// not nest, a nested write would queue behind a sync waiting for the outer one.
+func (l *VaultLock) BeginWrite() (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(l.writeWait)
+	defer timer.Stop()
+
+	for {
+		l.mu.Lock()
+		if !l.syncing && l.syncWaiting == 0 {
+			l.writers++
+			l.mu.Unlock()
+			return l.releaser(func() { l.writers-- }), nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, ErrVaultBusy
+		}
+	}
+}
+
+// BeginSync waits for in-flight writes, then holds the vault for a sync step until
+// the returned function is called. Calling it more than once is safe.
+func (l *VaultLock) BeginSync(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	// A sync that gives up lets the writes queued behind it go
+	waiting := false
+	defer func() {
+		if waiting {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.syncWaiting--
+			l.notifyLocked()
+		}
+	}()
+
+	for {
+		l.mu.Lock()
+		if l.writers == 0 && !l.syncing {
+			l.syncing = true
+			if waiting {
+				l.syncWaiting--
+				waiting = false
+			}
+			l.mu.Unlock()
+			return l.releaser(func() { l.syncing = false }), nil
+		}
+		if !waiting {
+			waiting = true
+			l.syncWaiting++
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// releaser returns a function that applies release under the lock once and wakes
+// the waiters
+func (l *VaultLock) releaser(release func()) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			release()
+			l.notifyLocked()
+		})
+	}
+}
+
+// notifyLocked wakes everyone waiting for a change. The caller must hold l.mu.
+func (l *VaultLock) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockTimeout is how long a test waits before deciding a call is blocked
+const blockTimeout = 50 * time.Millisecond
+
+// beginSyncAsync starts BeginSync in a goroutine and returns the channel its result arrives on
+func beginSyncAsync(ctx context.Context, lock *VaultLock) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		release, err := lock.BeginSync(ctx)
+		if err == nil {
+			defer release()
+		}
+		done <- err
+	}()
+	return done
+}
+
+// beginWriteAsync starts BeginWrite in a goroutine and returns the channel its result arrives on
+func beginWriteAsync(lock *VaultLock) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		release, err := lock.BeginWrite()
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	return done
+}
+
+// requireBlocked fails the test when the call already returned
+func requireBlocked(t *testing.T, done <-chan error, call string) {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		t.Fatalf("%s returned %v, want it to wait", call, err)
+	case <-time.After(blockTimeout):
+	}
+}
+
+// requireDone waits for the call to return and returns its error
+func requireDone(t *testing.T, done <-chan error, call string) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s didn't return", call)
+		return nil
+	}
+}
+
+func TestWriteDuringSyncIsBusy(t *testing.T) {
+	lock := NewVaultLock(blockTimeout)
+	release, err := lock.BeginSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	start := time.Now()
+	if _, err := lock.BeginWrite(); !errors.Is(err, ErrVaultBusy) {
+		t.Fatalf("write during sync = %v, want ErrVaultBusy", err)
+	}
+	if waited := time.Since(start); waited < blockTimeout {
+		t.Errorf("write gave up after %v, want it to wait %v", waited, blockTimeout)
+	}
+}
+
+func TestWriteWaitsForSync(t *testing.T) {
+	lock := NewVaultLock(time.Minute)
+	release, err := lock.BeginSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := beginWriteAsync(lock)
+	requireBlocked(t, write, "write during sync")
+
+	release()
+	if err := requireDone(t, write, "write after sync"); err != nil {
+		t.Fatalf("write after sync: %v", err)
+	}
+}
+
+func TestSyncWaitsForWrites(t *testing.T) {
+	lock := NewVaultLock(time.Minute)
+	releaseFirst, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSecond, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncDone := beginSyncAsync(context.Background(), lock)
+	requireBlocked(t, syncDone, "sync during writes")
+
+	releaseFirst()
+	requireBlocked(t, syncDone, "sync during a write")
+
+	releaseSecond()
+	if err := requireDone(t, syncDone, "sync after writes"); err != nil {
+		t.Fatalf("sync after writes: %v", err)
+	}
+}
+
+func TestWritesQueueBehindWaitingSync(t *testing.T) {
+	lock := NewVaultLock(time.Minute)
+	releaseWrite, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	syncDone := beginSyncAsync(context.Background(), lock)
+	requireBlocked(t, syncDone, "sync during a write")
+
+	// A new write doesn't jump ahead of the waiting sync
+	write := beginWriteAsync(lock)
+	requireBlocked(t, write, "write while a sync waits")
+
+	releaseWrite()
+	if err := requireDone(t, syncDone, "sync"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if err := requireDone(t, write, "queued write"); err != nil {
+		t.Fatalf("queued write: %v", err)
+	}
+}
+
+func TestReleaseTwice(t *testing.T) {
+	lock := NewVaultLock(time.Minute)
+
+	releaseFirst, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSecond, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Releasing the first write twice must not end the second
+	releaseFirst()
+	releaseFirst()
+	syncDone := beginSyncAsync(context.Background(), lock)
+	requireBlocked(t, syncDone, "sync during a write")
+	releaseSecond()
+	if err := requireDone(t, syncDone, "sync"); err != nil {
+		t.Fatal(err)
+	}
+
+	releaseSync, err := lock.BeginSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSync()
+	releaseSync()
+
+	// Releasing the sync twice leaves the lock usable by both writes and syncs
+	releaseWrite, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatalf("write after sync: %v", err)
+	}
+	releaseWrite()
+	releaseSync, err = lock.BeginSync(context.Background())
+	if err != nil {
+		t.Fatalf("sync after sync: %v", err)
+	}
+	releaseSync()
+}
+
+func TestSyncCancelled(t *testing.T) {
+	lock := NewVaultLock(time.Minute)
+	releaseWrite, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseWrite()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	syncDone := beginSyncAsync(ctx, lock)
+	requireBlocked(t, syncDone, "sync during a write")
+
+	// A write queued behind the sync goes ahead once the sync gives up
+	write := beginWriteAsync(lock)
+	requireBlocked(t, write, "write while a sync waits")
+
+	cancel()
+	if err := requireDone(t, syncDone, "cancelled sync"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled sync = %v, want context.Canceled", err)
+	}
+	if err := requireDone(t, write, "write after the sync gave up"); err != nil {
+		t.Fatalf("write after the sync gave up: %v", err)
+	}
+}
+
+func TestNilVaultLock(t *testing.T) {
+	var lock *VaultLock
+
+	releaseWrite, err := lock.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSync, err := lock.BeginSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSync()
+	releaseWrite()
+}